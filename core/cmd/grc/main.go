@@ -27,8 +27,9 @@ func runApplication(ctx context.Context) error {
 	return app.Run(ctx, version, buildTime, os.Args[1:], os.Stdout, os.Stderr)
 }
 
-// handleError handles errors consistently
+// handleError handles errors consistently, exiting with a code describing
+// the kind of failure
 func handleError(err error) {
 	fmt.Fprintf(os.Stderr, "grc: %v\n", err)
-	os.Exit(1)
+	os.Exit(app.ExitCode(err))
 }
@@ -1,16 +1,19 @@
 package app
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/carlosrabelo/grc/core/internal/lsp"
 	"github.com/carlosrabelo/grc/core/internal/rules"
 )
 
@@ -18,7 +21,16 @@ import (
 type CLIFlags struct {
 	outputFile    string
 	verbose       bool
+	logLevel      string
+	logFormat     string
 	force         bool
+	backup        bool
+	redact        bool
+	policyFile    string
+	optimize      bool
+	sortBy        string
+	group         bool
+	format        string
 	showVersion   bool
 	showHelp      bool
 	remainingArgs []string
@@ -30,6 +42,12 @@ func Run(ctx context.Context, version, buildTime string, args []string, stdout,
 		return err
 	}
 
+	if len(args) > 0 {
+		if command, ok := commands[args[0]]; ok {
+			return command(ctx, args[1:], stdout)
+		}
+	}
+
 	flags, err := parseCLIArgs(args)
 	if err != nil {
 		return err
@@ -48,28 +66,447 @@ func Run(ctx context.Context, version, buildTime string, args []string, stdout,
 		return err
 	}
 
+	return withRedaction(flags.redact, stdout, stderr, func(stdout, stderr io.Writer, redactor *rules.Redactor) error {
+		return runGenerate(flags, version, buildTime, stdout, stderr, redactor)
+	})
+}
+
+// runGenerate loads the configuration and writes the feed file
+func runGenerate(flags *CLIFlags, version, buildTime string, stdout, stderr io.Writer, redactor *rules.Redactor) error {
 	yamlFile := flags.remainingArgs[0]
-	logger := createLogger(flags.verbose, stderr)
+	logger := createLogger(flags, stderr)
+
+	logApplicationInfo(logger, version, buildTime, yamlFile)
+
+	config, err := loadConfiguration(logger, yamlFile)
+	if err != nil {
+		return err
+	}
+	learnSensitiveValues(redactor, config)
+
+	if err := enforceOrgPolicy(logger, flags.policyFile, config); err != nil {
+		return err
+	}
+
+	if err := reportWarnings(logger, stderr, "validate", config.Warnings); err != nil {
+		return err
+	}
+
+	options := rules.FeedOptions{
+		Optimize: flags.optimize,
+		Sort:     rules.SortOptions{By: flags.sortBy, Group: flags.group},
+	}
+	feed, report, err := generateXMLFeed(config, options, logger)
+	if err != nil {
+		return err
+	}
+
+	if err := displayFeedReport(stdout, report); err != nil {
+		return err
+	}
 
-	logApplicationInfo(logger, flags.verbose, version, buildTime, yamlFile)
+	if err := enforceLimits(logger, stderr, feed, config.Limits); err != nil {
+		return err
+	}
+
+	encoder, err := rules.LookupEncoder(flags.format)
+	if err != nil {
+		return err
+	}
+	outputFile := resolveOutputPath(yamlFile, flags.outputFile, encoder.Extension())
+	if sameFile(outputFile, encoder.Extension(), yamlFile) {
+		return usage(fmt.Errorf("error: output file %s is the input configuration, choose another with -output", outputFile))
+	}
 
-	config, err := loadConfiguration(yamlFile)
+	// A backup keeps the replaced file, so it allows replacing it
+	saveOptions := rules.SaveOptions{Force: flags.force || flags.backup, Backup: flags.backup}
+	backupFile, err := persistFeedFile(logger, outputFile, feed, flags.format, saveOptions)
 	if err != nil {
 		return err
 	}
 
-	feed, err := generateXMLFeed(config, logger, flags.verbose)
+	if err := displaySuccessMessage(stdout, flags.format, outputFile); err != nil {
+		return err
+	}
+	return displayBackupMessage(stdout, backupFile)
+}
+
+// ============================================================================
+// Redaction
+// ============================================================================
+
+// withRedaction runs fn with output writers that hide email addresses and
+// sensitive values when redact is set, redacting the returned error as well.
+// fn receives a nil redactor when redaction is off.
+func withRedaction(redact bool, stdout, stderr io.Writer, fn func(stdout, stderr io.Writer, redactor *rules.Redactor) error) error {
+	if !redact {
+		return fn(stdout, stderr, nil)
+	}
+
+	redactor := rules.NewRedactor()
+	redactedStdout := &redactWriter{w: stdout, redactor: redactor}
+	redactedStderr := &redactWriter{w: stderr, redactor: redactor}
+	err := fn(redactedStdout, redactedStderr, redactor)
+	if flushErr := errors.Join(redactedStdout.Flush(), redactedStderr.Flush()); err == nil {
+		err = flushErr
+	}
+	if err != nil {
+		return &redactedError{err: err, redactor: redactor}
+	}
+	return nil
+}
+
+// learnSensitiveValues teaches the redactor, if any, the values the
+// configuration read from the environment, files and secrets
+func learnSensitiveValues(redactor *rules.Redactor, config rules.FiltersConfig) {
+	if redactor != nil {
+		redactor.Add(rules.SensitiveValues(config)...)
+	}
+}
+
+// redactWriter redacts whole lines before writing them, so values are never
+// split across writes
+type redactWriter struct {
+	w        io.Writer
+	redactor *rules.Redactor
+	pending  []byte
+}
+
+func (r *redactWriter) Write(p []byte) (int, error) {
+	r.pending = append(r.pending, p...)
+	if end := bytes.LastIndexByte(r.pending, '\n'); end >= 0 {
+		lines := string(r.pending[:end+1])
+		r.pending = append(r.pending[:0], r.pending[end+1:]...)
+		if _, err := io.WriteString(r.w, r.redactor.Redact(lines)); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush writes the last unterminated line
+func (r *redactWriter) Flush() error {
+	if len(r.pending) == 0 {
+		return nil
+	}
+	_, err := io.WriteString(r.w, r.redactor.Redact(string(r.pending)))
+	r.pending = r.pending[:0]
+	return err
+}
+
+// redactedError hides addresses and sensitive values in the message of an
+// error, keeping it available to errors.As for exit codes
+type redactedError struct {
+	err      error
+	redactor *rules.Redactor
+}
+
+func (e *redactedError) Error() string { return e.redactor.Redact(e.err.Error()) }
+
+func (e *redactedError) Unwrap() error { return e.err }
+
+// ============================================================================
+// Exit Codes
+// ============================================================================
+
+// Process exit codes selected by ExitCode
+const (
+	ExitOK         = 0 // success
+	ExitFailure    = 1 // I/O and other failures
+	ExitUsage      = 2 // invalid command line arguments
+	ExitParse      = 3 // configuration is not valid YAML or has unknown keys
+	ExitValidation = 4 // configuration content is invalid
+	ExitLimits     = 5 // generated filters exceed Gmail limits
+)
+
+// usageError reports invalid command line arguments
+type usageError struct {
+	err error
+}
+
+func (e *usageError) Error() string {
+	return e.err.Error()
+}
+
+func (e *usageError) Unwrap() error {
+	return e.err
+}
+
+// usage marks an error as caused by invalid command line arguments
+func usage(err error) error {
+	return &usageError{err: err}
+}
+
+// ExitCode maps an error returned by Run to the process exit code
+func ExitCode(err error) int {
+	var usageErr *usageError
+	var parseErr *rules.ParseError
+	var validationErr *rules.ValidationError
+	var limitErr *rules.LimitError
+
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.As(err, &usageErr):
+		return ExitUsage
+	case errors.As(err, &parseErr):
+		return ExitParse
+	case errors.As(err, &validationErr):
+		return ExitValidation
+	case errors.As(err, &limitErr):
+		return ExitLimits
+	default:
+		return ExitFailure
+	}
+}
+
+// ============================================================================
+// Subcommands
+// ============================================================================
+
+// stdin is the input of commands reading from standard input
+var stdin io.Reader = os.Stdin
+
+// commands maps subcommand names to their handlers
+var commands = map[string]func(ctx context.Context, args []string, stdout io.Writer) error{
+	"schema":  runSchema,
+	"lsp":     runLSP,
+	"fmt":     runFmt,
+	"explain": runExplain,
+	"import":  runImport,
+	"export":  runExport,
+	"audit":   runAudit,
+}
+
+// runSchema prints the JSON Schema of the YAML configuration
+func runSchema(_ context.Context, args []string, stdout io.Writer) error {
+	if len(args) > 0 {
+		return usage(fmt.Errorf("error: schema takes no arguments, got %v\n\nUsage: grc schema > grc.schema.json", args))
+	}
+
+	schema, err := rules.JSONSchema()
 	if err != nil {
 		return err
 	}
+	if _, err := stdout.Write(schema); err != nil {
+		return fmt.Errorf("writing schema: %w", err)
+	}
+	return nil
+}
+
+// runLSP serves the language server protocol over standard input and output
+func runLSP(ctx context.Context, args []string, stdout io.Writer) error {
+	if len(args) > 0 {
+		return usage(fmt.Errorf("error: lsp takes no arguments, got %v\n\nUsage: grc lsp", args))
+	}
+
+	if err := lsp.Serve(ctx, stdin, stdout); err != nil {
+		return fmt.Errorf("language server: %w", err)
+	}
+	return nil
+}
+
+// runFmt rewrites configuration files into the canonical layout, printing
+// the result, writing it back with -w or listing unformatted files with -check
+func runFmt(_ context.Context, args []string, stdout io.Writer) error {
+	flagSet := flag.NewFlagSet("grc fmt", flag.ContinueOnError)
+	flagSet.SetOutput(io.Discard)
+	check := flagSet.Bool("check", false, "list files whose formatting differs and fail")
+	write := flagSet.Bool("w", false, "write the result to the source file")
+	sortBy := flagSet.String("sort", "", "sort filters by label, from or action")
+	group := flagSet.Bool("group", false, "keep filters sharing a top-level label together")
+	if err := flagSet.Parse(args); err != nil {
+		return usage(err)
+	}
+
+	options := rules.FormatOptions{Sort: rules.SortOptions{By: *sortBy, Group: *group}}
+	if err := options.Sort.Validate(); err != nil {
+		return usage(fmt.Errorf("error: %w", err))
+	}
+
+	files := flagSet.Args()
+	if len(files) == 0 {
+		return usage(errors.New("error: YAML file path is required\n\nUsage: grc fmt [-check] [-w] [-sort <key>] [-group] <yaml_file>..."))
+	}
+	if *check && *write {
+		return usage(errors.New("error: -check and -w cannot be used together"))
+	}
+
+	var unformatted []string
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("reading file: %w", err)
+		}
+		formatted, err := rules.FormatConfig(content, options)
+		if err != nil {
+			return fmt.Errorf("formatting %s: %w", file, err)
+		}
+		changed := !bytes.Equal(content, formatted)
+
+		switch {
+		case *check:
+			if changed {
+				unformatted = append(unformatted, file)
+				if _, err := fmt.Fprintln(stdout, file); err != nil {
+					return fmt.Errorf("writing output: %w", err)
+				}
+			}
+		case *write:
+			if changed {
+				if err := rewriteFile(file, formatted); err != nil {
+					return err
+				}
+			}
+		default:
+			if _, err := stdout.Write(formatted); err != nil {
+				return fmt.Errorf("writing output: %w", err)
+			}
+		}
+	}
+
+	if len(unformatted) > 0 {
+		return fmt.Errorf("%d file(s) need formatting, run grc fmt -w", len(unformatted))
+	}
+	return nil
+}
+
+// runExplain describes every filter of a configuration in plain language
+func runExplain(_ context.Context, args []string, stdout io.Writer) error {
+	flagSet := flag.NewFlagSet("grc explain", flag.ContinueOnError)
+	flagSet.SetOutput(io.Discard)
+	format := flagSet.String("format", rules.ExplainText, "output format: text, markdown or html")
+	redact := flagSet.Bool("redact", false, "hide email addresses and secret values")
+	if err := flagSet.Parse(args); err != nil {
+		return usage(err)
+	}
+	if flagSet.NArg() != 1 {
+		return usage(errors.New("error: one YAML file path is required\n\nUsage: grc explain [-format text|markdown|html] [-redact] <yaml_file>"))
+	}
+
+	return withRedaction(*redact, stdout, io.Discard, func(stdout, _ io.Writer, redactor *rules.Redactor) error {
+		config, err := loadConfiguration(discardLogger, flagSet.Arg(0))
+		if err != nil {
+			return err
+		}
+		learnSensitiveValues(redactor, config)
+		return rules.WriteExplanation(stdout, config, *format)
+	})
+}
+
+// runImport converts filters kept in a CSV spreadsheet into a YAML
+// configuration, validating them first
+func runImport(_ context.Context, args []string, stdout io.Writer) error {
+	flagSet := flag.NewFlagSet("grc import", flag.ContinueOnError)
+	flagSet.SetOutput(io.Discard)
+	name := flagSet.String("name", "", "author name")
+	email := flagSet.String("email", "", "author email")
+	if err := flagSet.Parse(args); err != nil {
+		return usage(err)
+	}
+	if flagSet.NArg() != 1 || *name == "" || *email == "" {
+		return usage(errors.New("error: author and one CSV file are required\n\nUsage: grc import -name <name> -email <email> <csv_file> > config.yaml"))
+	}
 
-	outputFile := resolveOutputPath(yamlFile, flags.outputFile)
+	file, err := os.Open(flagSet.Arg(0))
+	if err != nil {
+		return fmt.Errorf("reading file: %w", err)
+	}
+	defer file.Close()
+
+	config, err := rules.ImportCSV(file, rules.Author{Name: *name, Email: *email})
+	if err != nil {
+		return fmt.Errorf("importing %s: %w", flagSet.Arg(0), err)
+	}
+	if _, err := rules.PrepareConfig(config); err != nil {
+		for _, validationErr := range rules.ValidationErrors(err) {
+			if validationErr.Filter >= 0 {
+				validationErr.Message = fmt.Sprintf("row %d: %s", validationErr.Line, validationErr.Message)
+			}
+		}
+		return fmt.Errorf("importing %s: %w", flagSet.Arg(0), err)
+	}
 
-	if err := persistXMLFile(logger, flags.verbose, outputFile, feed, flags.force); err != nil {
+	output, err := rules.MarshalConfig(config)
+	if err != nil {
 		return err
 	}
+	if _, err := stdout.Write(output); err != nil {
+		return fmt.Errorf("writing output: %w", err)
+	}
+	return nil
+}
+
+// runExport writes the normalized filters of a configuration as CSV. Values
+// read through ${env:}, ${file:} and ${secret:} references are written
+// expanded unless -redact is set.
+func runExport(_ context.Context, args []string, stdout io.Writer) error {
+	flagSet := flag.NewFlagSet("grc export", flag.ContinueOnError)
+	flagSet.SetOutput(io.Discard)
+	redact := flagSet.Bool("redact", false, "hide email addresses and secret values")
+	if err := flagSet.Parse(args); err != nil {
+		return usage(err)
+	}
+	if flagSet.NArg() != 1 {
+		return usage(errors.New("error: one YAML file path is required\n\nUsage: grc export [-redact] <yaml_file> > filters.csv"))
+	}
+
+	return withRedaction(*redact, stdout, io.Discard, func(stdout, _ io.Writer, redactor *rules.Redactor) error {
+		config, err := loadConfiguration(discardLogger, flagSet.Arg(0))
+		if err != nil {
+			return err
+		}
+		learnSensitiveValues(redactor, config)
+		return rules.WriteCSV(stdout, rules.NormalizeFilters(config))
+	})
+}
+
+// runAudit lists every forwarding filter with the policy rules it breaks,
+// failing when any rule is broken
+func runAudit(_ context.Context, args []string, stdout io.Writer) error {
+	flagSet := flag.NewFlagSet("grc audit", flag.ContinueOnError)
+	flagSet.SetOutput(io.Discard)
+	redact := flagSet.Bool("redact", false, "hide email addresses and secret values")
+	if err := flagSet.Parse(args); err != nil {
+		return usage(err)
+	}
+	if flagSet.NArg() != 1 {
+		return usage(errors.New("error: one YAML file path is required\n\nUsage: grc audit [-redact] <yaml_file>"))
+	}
+
+	return withRedaction(*redact, stdout, io.Discard, func(stdout, _ io.Writer, redactor *rules.Redactor) error {
+		config, err := rules.ReadConfig(flagSet.Arg(0))
+		if err != nil {
+			return fmt.Errorf("loading configuration: %w", err)
+		}
+
+		audits, prepared, err := rules.AuditForwards(config)
+		if err != nil {
+			return fmt.Errorf("auditing %s: %w", flagSet.Arg(0), err)
+		}
+		learnSensitiveValues(redactor, prepared)
+		if err := rules.WriteAudit(stdout, audits); err != nil {
+			return err
+		}
+
+		if err := rules.AuditError(audits); err != nil {
+			return fmt.Errorf("forwarding policy: %w", err)
+		}
+		return nil
+	})
+}
 
-	return displaySuccessMessage(stdout, outputFile)
+// rewriteFile atomically replaces the content of an existing file, keeping
+// its permissions
+func rewriteFile(file string, content []byte) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return fmt.Errorf("reading file: %w", err)
+	}
+	if err := rules.WriteFileAtomic(file, content, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("writing file: %w", err)
+	}
+	return nil
 }
 
 // ============================================================================
@@ -85,37 +522,79 @@ func parseCLIArgs(args []string) (*CLIFlags, error) {
 
 	flagSet.StringVar(&flags.outputFile, "output", "", "output XML file name")
 	flagSet.BoolVar(&flags.verbose, "verbose", false, "enable verbose logging")
+	flagSet.StringVar(&flags.logLevel, "log-level", "", "log events at debug, info or warn level")
+	flagSet.StringVar(&flags.logFormat, "log-format", "text", "log format: text or json")
 	flagSet.BoolVar(&flags.force, "force", false, "overwrite existing XML file")
+	flagSet.BoolVar(&flags.backup, "backup", false, "keep a timestamped .bak of the replaced output file")
+	flagSet.BoolVar(&flags.redact, "redact", false, "hide email addresses and secret values in logs and reports")
+	flagSet.StringVar(&flags.policyFile, "policy", "", "policy file the configuration must comply with")
+	flagSet.BoolVar(&flags.optimize, "optimize", false, "merge filters that differ only in one criterion")
+	flagSet.StringVar(&flags.sortBy, "sort", "", "sort entries by label, from or action")
+	flagSet.BoolVar(&flags.group, "group", false, "keep filters sharing a top-level label together")
+	flagSet.StringVar(&flags.format, "format", rules.DefaultFormat, "output format")
 	flagSet.BoolVar(&flags.showVersion, "version", false, "show version information")
 	flagSet.BoolVar(&flags.showHelp, "help", false, "show help message")
 
 	if err := flagSet.Parse(args); err != nil {
-		return nil, err
+		return nil, usage(err)
 	}
 
 	flags.remainingArgs = flagSet.Args()
 	return flags, nil
 }
 
-// validateRequiredArgs checks if required arguments were provided
+// validateRequiredArgs checks if required arguments were provided and valid
 func validateRequiredArgs(flags *CLIFlags) error {
 	if len(flags.remainingArgs) == 0 {
-		return errors.New("error: YAML file path is required\n\nUsage: grc [-output <xml_file>] [-verbose] [-force] <yaml_file>")
+		return usage(errors.New("error: YAML file path is required\n\nUsage: grc [-output <file>] [-format <name>] [-verbose] [-log-level <level>] [-log-format text|json] [-force] [-backup] [-redact] [-policy <file>] [-optimize] [-sort <key>] [-group] <yaml_file>"))
 	}
 	if len(flags.remainingArgs) > 1 {
-		return fmt.Errorf("error: only one YAML file can be processed at a time, got %d files: %v",
-			len(flags.remainingArgs), flags.remainingArgs)
+		return usage(fmt.Errorf("error: only one YAML file can be processed at a time, got %d files: %v",
+			len(flags.remainingArgs), flags.remainingArgs))
+	}
+	if err := (rules.SortOptions{By: flags.sortBy}).Validate(); err != nil {
+		return usage(fmt.Errorf("error: %w", err))
+	}
+	if _, err := rules.LookupEncoder(flags.format); err != nil {
+		return usage(fmt.Errorf("error: %w", err))
+	}
+	if _, ok := logLevels[flags.logLevel]; flags.logLevel != "" && !ok {
+		return usage(fmt.Errorf("error: unknown log level '%s' (use debug, info or warn)", flags.logLevel))
+	}
+	if flags.logFormat != "text" && flags.logFormat != "json" {
+		return usage(fmt.Errorf("error: unknown log format '%s' (use text or json)", flags.logFormat))
 	}
 	return nil
 }
 
-// createLogger configures the logger based on verbose flag
-func createLogger(verbose bool, stderr io.Writer) *log.Logger {
-	logger := log.New(io.Discard, "", log.LstdFlags)
-	if verbose {
-		logger.SetOutput(stderr)
+// logLevels maps the values of -log-level to slog levels
+var logLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+}
+
+// discardLogger drops every event, for commands that do not log. Its level
+// is above every level used, so it reports no level as enabled.
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1}))
+
+// createLogger configures the structured logger from the logging flags.
+// Logging is off unless -verbose or -log-level is given; -verbose logs at
+// info level and -log-format json writes one JSON object per event.
+func createLogger(flags *CLIFlags, stderr io.Writer) *slog.Logger {
+	level, enabled := logLevels[flags.logLevel]
+	if !enabled && flags.verbose {
+		level, enabled = slog.LevelInfo, true
+	}
+	if !enabled {
+		return discardLogger
 	}
-	return logger
+
+	options := &slog.HandlerOptions{Level: level}
+	if flags.logFormat == "json" {
+		return slog.New(slog.NewJSONHandler(stderr, options))
+	}
+	return slog.New(slog.NewTextHandler(stderr, options))
 }
 
 // checkContextCancellation checks if the context was cancelled
@@ -132,84 +611,224 @@ func checkContextCancellation(ctx context.Context) error {
 // Loading and Processing Functions
 // ============================================================================
 
-// loadConfiguration loads and validates the YAML configuration file
-func loadConfiguration(yamlFile string) (rules.FiltersConfig, error) {
-	config, err := rules.LoadConfig(yamlFile)
+// loadConfiguration reads and validates the YAML, JSON or TOML
+// configuration file, logging the load and validate stages
+func loadConfiguration(logger *slog.Logger, yamlFile string) (rules.FiltersConfig, error) {
+	start := time.Now()
+	config, err := rules.ReadConfig(yamlFile)
+	if err != nil {
+		logger.Error("read config failed", "stage", "load", "file", yamlFile, "error", err)
+		return rules.FiltersConfig{}, fmt.Errorf("loading configuration: %w", err)
+	}
+	logger.Info("read config", "stage", "load", "file", yamlFile, "duration", time.Since(start))
+
+	start = time.Now()
+	config, err = rules.PrepareConfig(config)
 	if err != nil {
+		logValidationErrors(logger, "validate", yamlFile, err)
 		return rules.FiltersConfig{}, fmt.Errorf("loading configuration: %w", err)
 	}
+	logger.Info("validated config", "stage", "validate", "file", yamlFile, "duration", time.Since(start))
 	return config, nil
 }
 
 // generateXMLFeed generates the XML feed from configuration
-func generateXMLFeed(config rules.FiltersConfig, logger *log.Logger, verbose bool) (rules.Feed, error) {
-	logVerboseMessage(logger, verbose, "Generating XML feed")
-	feed := rules.GenerateFeed(config, time.Now().UTC())
-	return feed, nil
+func generateXMLFeed(config rules.FiltersConfig, options rules.FeedOptions, logger *slog.Logger) (rules.Feed, rules.FeedReport, error) {
+	if logger.Enabled(context.Background(), slog.LevelDebug) {
+		for i, filter := range rules.NormalizeFilters(config) {
+			logger.Debug("filter", "stage", "generate", "filter", i, "description", rules.ExplainFilter(filter))
+		}
+	}
+
+	start := time.Now()
+	feed, report := rules.BuildFeed(config, time.Now().UTC(), options)
+	logger.Info("generated feed", "stage", "generate", "entries", report.Entries, "filters", report.Filters,
+		"duration", time.Since(start))
+	return feed, report, nil
+}
+
+// enforceOrgPolicy fails when the configuration breaks the policy file, if
+// one was given. The policy changes no filter, it only blocks generation.
+func enforceOrgPolicy(logger *slog.Logger, policyFile string, config rules.FiltersConfig) error {
+	if policyFile == "" {
+		return nil
+	}
+
+	logger.Info("check policy", "stage", "policy", "file", policyFile)
+	policy, err := rules.LoadOrgPolicy(policyFile)
+	if err != nil {
+		return fmt.Errorf("loading policy %s: %w", policyFile, err)
+	}
+	if err := rules.CheckOrgPolicy(policy, config); err != nil {
+		logValidationErrors(logger, "policy", policyFile, err)
+		return fmt.Errorf("policy %s: %w", policyFile, err)
+	}
+	return nil
+}
+
+// enforceLimits checks Gmail account limits, failing or warning on violations
+func enforceLimits(logger *slog.Logger, stderr io.Writer, feed rules.Feed, limits rules.Limits) error {
+	violations, err := rules.CheckLimits(feed, limits)
+	if err != nil {
+		return fmt.Errorf("checking limits: %w", err)
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+
+	if limits.Fail() {
+		return &rules.LimitError{Violations: violations}
+	}
+
+	warnings := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		warnings = append(warnings, violation.String())
+	}
+	return reportWarnings(logger, stderr, "generate", warnings)
+}
+
+// sameFile reports whether the output file, saved with extension when it has
+// none, is the input file: the same absolute path, or the same file reached
+// through a link
+func sameFile(outputFile, extension, inputFile string) bool {
+	if filepath.Ext(outputFile) == "" {
+		outputFile += extension
+	}
+	outputAbs, outputErr := filepath.Abs(outputFile)
+	inputAbs, inputErr := filepath.Abs(inputFile)
+	if outputErr == nil && inputErr == nil && outputAbs == inputAbs {
+		return true
+	}
+
+	outputInfo, outputErr := os.Stat(outputFile)
+	inputInfo, inputErr := os.Stat(inputFile)
+	return outputErr == nil && inputErr == nil && os.SameFile(outputInfo, inputInfo)
 }
 
-// resolveOutputPath determines the output file path
-func resolveOutputPath(yamlFile, outputFile string) string {
+// resolveOutputPath determines the output file path, named after the input
+// with the extension of the output format by default
+func resolveOutputPath(yamlFile, outputFile, extension string) string {
 	if outputFile == "" {
 		ext := filepath.Ext(yamlFile)
-		return strings.TrimSuffix(yamlFile, ext) + ".xml"
+		return strings.TrimSuffix(yamlFile, ext) + extension
 	}
 	return outputFile
 }
 
-// persistXMLFile saves the XML file to disk
-func persistXMLFile(logger *log.Logger, verbose bool, outputFile string, feed rules.Feed, force bool) error {
-	logFileOperation(logger, verbose, outputFile, force)
+// persistFeedFile saves the feed to disk in the output format, returning
+// the backup of the replaced file, if any
+func persistFeedFile(logger *slog.Logger, outputFile string, feed rules.Feed, format string, options rules.SaveOptions) (string, error) {
+	logFileOperation(logger, outputFile, format, options.Force)
 
-	if err := rules.SaveXML(outputFile, feed, force); err != nil {
-		return fmt.Errorf("saving XML: %w", err)
+	encoder, err := rules.LookupEncoder(format)
+	if err != nil {
+		return "", err
+	}
+	start := time.Now()
+	backupFile, err := rules.SaveFeedWith(outputFile, feed, encoder, options)
+	if err != nil {
+		return "", fmt.Errorf("saving %s: %w", strings.ToUpper(format), err)
+	}
+	logger.Info("saved feed", "stage", "save", "file", outputFile, "entries", len(feed.Entries), "duration", time.Since(start))
+	if backupFile != "" {
+		logger.Info("backed up file", "stage", "save", "file", outputFile, "backup", backupFile)
 	}
 
-	return nil
+	return backupFile, nil
 }
 
 // ============================================================================
 // Logging and Output Functions
 // ============================================================================
 
-// logApplicationInfo displays application information in verbose mode
-func logApplicationInfo(logger *log.Logger, verbose bool, version, buildTime, yamlFile string) {
-	if !verbose {
-		return
-	}
+// logApplicationInfo logs the version and the configuration being read
+func logApplicationInfo(logger *slog.Logger, version, buildTime, yamlFile string) {
+	logger.Info("start", "version", version, "build", buildTime, "file", yamlFile)
+}
 
-	logger.Printf("GRC version %s (build %s)", version, buildTime)
-	logger.Printf("Reading YAML file: %s", yamlFile)
+// logFileOperation logs the file about to be written; force is set when an
+// existing file may be replaced
+func logFileOperation(logger *slog.Logger, outputFile, format string, force bool) {
+	logger.Info("save feed", "stage", "save", "file", outputFile, "format", format, "force", force)
 }
 
-// logVerboseMessage displays message only in verbose mode
-func logVerboseMessage(logger *log.Logger, verbose bool, message string) {
-	if verbose {
-		logger.Println(message)
+// reportWarnings logs warnings when logging is enabled and prints them
+// otherwise, so each warning appears once and JSON logs stay one object per
+// line
+func reportWarnings(logger *slog.Logger, stderr io.Writer, stage string, warnings []string) error {
+	if !logger.Enabled(context.Background(), slog.LevelWarn) {
+		return displayWarnings(stderr, warnings)
+	}
+	for _, warning := range warnings {
+		logger.Warn("warning", "stage", stage, "warning", warning)
 	}
+	return nil
 }
 
-// logFileOperation logs file operation in verbose mode
-func logFileOperation(logger *log.Logger, verbose bool, outputFile string, force bool) {
-	if !verbose {
-		return
+// logValidationErrors logs every validation error of err with its filter,
+// field, rule and line
+func logValidationErrors(logger *slog.Logger, stage, file string, err error) {
+	for _, validationErr := range rules.ValidationErrors(err) {
+		logger.Error("validation error", "stage", stage, "file", file, "filter", validationErr.Filter,
+			"field", validationErr.Field, "rule", validationErr.Rule, "line", validationErr.Line,
+			"message", validationErr.Message)
 	}
+}
 
-	if force {
-		logger.Printf("Overwriting XML to: %s", outputFile)
-	} else {
-		logger.Printf("Saving XML to: %s", outputFile)
+// displaySuccessMessage displays success message on standard output
+func displaySuccessMessage(stdout io.Writer, format, outputFile string) error {
+	if _, err := fmt.Fprintf(stdout, "%s file successfully generated: %s\n", strings.ToUpper(format), outputFile); err != nil {
+		return fmt.Errorf("writing output message: %w", err)
 	}
+	return nil
 }
 
-// displaySuccessMessage displays success message on standard output
-func displaySuccessMessage(stdout io.Writer, outputFile string) error {
-	if _, err := fmt.Fprintf(stdout, "XML file successfully generated: %s\n", outputFile); err != nil {
+// displayBackupMessage reports where the replaced output file was kept
+func displayBackupMessage(stdout io.Writer, backupFile string) error {
+	if backupFile == "" {
+		return nil
+	}
+	if _, err := fmt.Fprintf(stdout, "Previous file kept as: %s\n", backupFile); err != nil {
+		return fmt.Errorf("writing output message: %w", err)
+	}
+	return nil
+}
+
+// displayFeedReport reports optimized filters and filters split into several entries
+func displayFeedReport(stdout io.Writer, report rules.FeedReport) error {
+	if report.Optimized > 0 {
+		if _, err := fmt.Fprintf(stdout, "Optimized filters: %d -> %d\n", report.Filters, report.Optimized); err != nil {
+			return fmt.Errorf("writing output message: %w", err)
+		}
+	}
+
+	if len(report.Splits) == 0 {
+		return nil
+	}
+
+	for _, split := range report.Splits {
+		if _, err := fmt.Fprintf(stdout, "Split %s into %d entries (%s exceeded the criteria length limit)\n",
+			split.Ref, split.Entries, strings.Join(split.Fields, ", ")); err != nil {
+			return fmt.Errorf("writing output message: %w", err)
+		}
+	}
+
+	if _, err := fmt.Fprintf(stdout, "Generated %d entries from %d filters\n", report.Entries, report.Filters); err != nil {
 		return fmt.Errorf("writing output message: %w", err)
 	}
 	return nil
 }
 
+// displayWarnings writes warnings to standard error
+func displayWarnings(stderr io.Writer, warnings []string) error {
+	for _, warning := range warnings {
+		if _, err := fmt.Fprintf(stderr, "grc: warning: %s\n", warning); err != nil {
+			return fmt.Errorf("writing warning: %w", err)
+		}
+	}
+	return nil
+}
+
 // displayVersion displays version information
 func displayVersion(stdout io.Writer, version, buildTime string) error {
 	_, err := fmt.Fprintf(stdout, "GRC - Gmail Rules Creator\nVersion: %s\nBuild Time: %s\n", version, buildTime)
@@ -221,19 +840,55 @@ func displayHelp(stdout io.Writer) error {
 	helpText := `GRC - Gmail Rules Creator
 
 Usage:
-  grc [options] <yaml_file>
+  grc [options] <config_file>
+  grc <command>
+
+  The configuration is YAML (.yaml, .yml), JSON (.json) or TOML (.toml);
+  files without an extension are recognized by their content.
+
+Commands:
+  schema           Print the JSON Schema of the YAML configuration
+  lsp              Run the language server over stdio for editor integration
+  fmt              Rewrite config files in the canonical layout (-check, -w, -sort, -group)
+  explain          Describe every filter in plain language (-format text|markdown|html, -redact)
+  import           Convert a CSV spreadsheet of filters to YAML (-name, -email)
+  export           Write the normalized filters as CSV (-redact)
+  audit            List every forwarding filter and check it against the policy (-redact)
 
 Options:
-  -output <file>   Specify output XML file path (default: same as input with .xml extension)
-  -verbose         Enable detailed logging output
-  -force           Overwrite existing XML file (default: fails if file exists)
+  -output <file>   Specify output file path (default: same as input with the format's extension)
+  -format <name>   Output format: xml (Gmail import, default), json, yaml or csv
+  -verbose         Enable detailed logging output (same as -log-level info)
+  -log-level <l>   Log events at debug, info or warn level
+  -log-format <f>  Log as text (default) or json, one object per line
+  -force           Overwrite existing output file (default: fails if file exists)
+  -backup          Replace the output file, keeping the previous one as <file>.<timestamp>.bak
+  -redact          Hide email addresses and secret values in logs, reports and errors;
+                   secret values shorter than 4 characters are left visible
+  -policy <file>   Refuse to generate when filters break the rules of a policy file
+  -optimize        Merge filters that share actions and differ only in one criterion
+  -sort <key>      Sort entries by label, from or action (default: YAML order)
+  -group           Keep filters sharing a top-level label together
   -version         Show version information
   -help            Show this help message
 
 Examples:
   grc config.yaml
   grc -output filters.xml config.yaml
+  grc -format json config.yaml
+  grc config.toml
   grc -verbose -force config.yaml
+  grc -log-level debug -log-format json config.yaml 2> grc.log
+  grc -backup config.yaml
+  grc -policy corp-policy.yaml config.yaml
+  grc -optimize config.yaml
+  grc schema > grc.schema.json
+  grc -sort label config.yaml
+  grc fmt -w config.yaml
+  grc explain -format markdown config.yaml
+  grc import -name "Jane Doe" -email jane@example.com filters.csv > config.yaml
+  grc export config.yaml > filters.csv
+  grc audit config.yaml
 `
 	_, err := fmt.Fprint(stdout, helpText)
 	return err
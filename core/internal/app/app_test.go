@@ -3,6 +3,8 @@ package app
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -102,10 +104,10 @@ filters:
 
 	logOutput := stderr.String()
 	expectedLogs := []string{
-		"GRC version test-version",
-		"Reading YAML file:",
-		"Generating XML feed",
-		"Saving XML to:",
+		"msg=start version=test-version",
+		"msg=\"read config\" stage=load file=" + tmpFile,
+		"msg=\"generated feed\" stage=generate entries=1 filters=1",
+		"msg=\"save feed\" stage=save file=" + strings.TrimSuffix(tmpFile, filepath.Ext(tmpFile)) + ".xml format=xml force=false",
 	}
 
 	for _, expected := range expectedLogs {
@@ -282,8 +284,8 @@ filters:
 	}
 
 	logOutput := stderr.String()
-	if !strings.Contains(logOutput, "Overwriting XML to:") {
-		t.Errorf("Expected log to contain 'Overwriting XML to:', got: %s", logOutput)
+	if !strings.Contains(logOutput, "msg=\"save feed\" stage=save file="+outputFile+" format=xml force=true") {
+		t.Errorf("Expected log to report replacing %s, got: %s", outputFile, logOutput)
 	}
 }
 
@@ -363,3 +365,662 @@ filters:
 	}
 }
 
+func TestRun_ReportsSplitFilters(t *testing.T) {
+	content := `author:
+  name: "Test User"
+  email: "test@example.com"
+limits:
+  maxCriteriaLength: 40
+filters:
+  - from: ["one@example.com", "two@example.com", "three@example.com"]
+    label: "Blocked"
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+
+	var stdout, stderr bytes.Buffer
+	ctx := context.Background()
+
+	err := Run(ctx, "test-version", "2023-01-01T00:00:00Z", []string{tmpFile}, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	output := stdout.String()
+	if !strings.Contains(output, "Split filter 0 into 2 entries") {
+		t.Errorf("Expected split report, got: %s", output)
+	}
+	if !strings.Contains(output, "Generated 2 entries from 1 filters") {
+		t.Errorf("Expected entry count, got: %s", output)
+	}
+}
+
+func TestRun_OptimizeFlag(t *testing.T) {
+	content := `author:
+  name: "Test User"
+  email: "test@example.com"
+filters:
+  - from: "a@news.com"
+    label: "Newsletters"
+  - from: "b@news.com"
+    label: "Newsletters"
+  - from: "boss@corp.com"
+    label: "Work"
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+
+	var stdout, stderr bytes.Buffer
+	ctx := context.Background()
+
+	err := Run(ctx, "test-version", "2023-01-01T00:00:00Z", []string{"--optimize", tmpFile}, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if !strings.Contains(stdout.String(), "Optimized filters: 3 -> 2") {
+		t.Errorf("Expected optimization report, got: %s", stdout.String())
+	}
+}
+
+func TestRun_LimitsWarnAndFail(t *testing.T) {
+	base := `author:
+  name: "Test User"
+  email: "test@example.com"
+limits:
+  maxFilters: 1
+%s
+filters:
+  - from: "a@example.com"
+    label: "A"
+  - from: "b@example.com"
+    label: "B"
+`
+	ctx := context.Background()
+
+	warnFile := testutils.CreateTempYAMLFile(t, strings.Replace(base, "%s", "", 1))
+	var stdout, stderr bytes.Buffer
+	if err := Run(ctx, "test-version", "2023-01-01T00:00:00Z", []string{warnFile}, &stdout, &stderr); err != nil {
+		t.Fatalf("Expected limits to warn by default, got: %v", err)
+	}
+	if !strings.Contains(stderr.String(), "warning: maxFilters exceeded: 2 > 1") {
+		t.Errorf("Expected limit warning, got: %s", stderr.String())
+	}
+
+	failFile := testutils.CreateTempYAMLFile(t, strings.Replace(base, "%s", "  onExceed: fail", 1))
+	stdout.Reset()
+	stderr.Reset()
+	err := Run(ctx, "test-version", "2023-01-01T00:00:00Z", []string{failFile}, &stdout, &stderr)
+	if err == nil || !strings.Contains(err.Error(), "Gmail limits exceeded: maxFilters exceeded") {
+		t.Errorf("Expected limit error, got: %v", err)
+	}
+}
+
+func TestRun_DisplaysConfigWarnings(t *testing.T) {
+	content := `author:
+  name: "Test User"
+  email: "test@example.com"
+default:
+  hasAttachment: true
+filters:
+  - from: "a@example.com"
+    label: "A"
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+	defer testutils.CleanupFile(strings.TrimSuffix(tmpFile, filepath.Ext(tmpFile)) + ".xml")
+
+	var stdout, stderr bytes.Buffer
+	if err := Run(context.Background(), "test-version", "2023-01-01T00:00:00Z", []string{tmpFile}, &stdout, &stderr); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !strings.Contains(stderr.String(), "grc: warning: default.hasAttachment is deprecated; move it to defaultCriteria.hasAttachment") {
+		t.Errorf("Expected migration warning, got: %s", stderr.String())
+	}
+}
+
+func TestRun_SchemaCommand(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if err := Run(context.Background(), "test-version", "2023-01-01T00:00:00Z", []string{"schema"}, &stdout, &stderr); err != nil {
+		t.Fatalf("schema command failed: %v", err)
+	}
+	if !strings.Contains(stdout.String(), `"$schema": "http://json-schema.org/draft-07/schema#"`) {
+		t.Errorf("Expected JSON Schema output, got: %s", stdout.String())
+	}
+
+	err := Run(context.Background(), "test-version", "2023-01-01T00:00:00Z", []string{"schema", "extra"}, &stdout, &stderr)
+	if err == nil || !strings.Contains(err.Error(), "schema takes no arguments") {
+		t.Errorf("Expected argument error, got: %v", err)
+	}
+}
+
+func TestRun_LSPCommand(t *testing.T) {
+	message := `{"jsonrpc":"2.0","id":1,"method":"shutdown"}`
+	exit := `{"jsonrpc":"2.0","method":"exit"}`
+	original := stdin
+	stdin = strings.NewReader(fmt.Sprintf("Content-Length: %d\r\n\r\n%sContent-Length: %d\r\n\r\n%s", len(message), message, len(exit), exit))
+	defer func() { stdin = original }()
+
+	var stdout, stderr bytes.Buffer
+	if err := Run(context.Background(), "test-version", "2023-01-01T00:00:00Z", []string{"lsp"}, &stdout, &stderr); err != nil {
+		t.Fatalf("lsp command failed: %v", err)
+	}
+	if !strings.Contains(stdout.String(), `{"jsonrpc":"2.0","id":1,"result":null}`) {
+		t.Errorf("Expected shutdown response, got: %s", stdout.String())
+	}
+}
+
+func TestRun_FmtCommand(t *testing.T) {
+	content := `filters:
+  - label: A
+    from: a@example.com
+author:
+  name: Test User
+  email: test@example.com
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+	ctx := context.Background()
+
+	var stdout, stderr bytes.Buffer
+	err := Run(ctx, "test-version", "2023-01-01T00:00:00Z", []string{"fmt", "-check", tmpFile}, &stdout, &stderr)
+	if err == nil || !strings.Contains(err.Error(), "1 file(s) need formatting") || !strings.Contains(stdout.String(), tmpFile) {
+		t.Fatalf("Expected -check to fail and list the file, got: %v (%s)", err, stdout.String())
+	}
+
+	stdout.Reset()
+	if err := Run(ctx, "test-version", "2023-01-01T00:00:00Z", []string{"fmt", "-w", tmpFile}, &stdout, &stderr); err != nil {
+		t.Fatalf("fmt -w failed: %v", err)
+	}
+	written, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatalf("Failed to read formatted file: %v", err)
+	}
+	if !strings.HasPrefix(string(written), "author:\n  name: \"Test User\"") {
+		t.Errorf("Expected formatted file, got:\n%s", written)
+	}
+
+	if err := Run(ctx, "test-version", "2023-01-01T00:00:00Z", []string{"fmt", "-check", tmpFile}, &stdout, &stderr); err != nil {
+		t.Errorf("Expected formatted file to pass -check, got: %v", err)
+	}
+}
+
+func TestRun_SortFlag(t *testing.T) {
+	content := `author:
+  name: "Test User"
+  email: "test@example.com"
+filters:
+  - from: "b@example.com"
+    label: "Work"
+  - from: "a@example.com"
+    label: "Home"
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+	outputFile := strings.TrimSuffix(tmpFile, filepath.Ext(tmpFile)) + ".xml"
+	defer testutils.CleanupFile(outputFile)
+	ctx := context.Background()
+
+	var stdout, stderr bytes.Buffer
+	err := Run(ctx, "test-version", "2023-01-01T00:00:00Z", []string{"-sort", "size", tmpFile}, &stdout, &stderr)
+	if err == nil || !strings.Contains(err.Error(), "unknown sort key 'size'") {
+		t.Fatalf("Expected unknown sort key error, got: %v", err)
+	}
+
+	if err := Run(ctx, "test-version", "2023-01-01T00:00:00Z", []string{"-sort", "label", tmpFile}, &stdout, &stderr); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	xmlContent, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read XML: %v", err)
+	}
+	if strings.Index(string(xmlContent), `value="Home"`) > strings.Index(string(xmlContent), `value="Work"`) {
+		t.Errorf("Expected entries sorted by label, got:\n%s", xmlContent)
+	}
+}
+
+func TestRun_ExplainCommand(t *testing.T) {
+	content := `author:
+  name: "Test User"
+  email: "test@example.com"
+default:
+  shouldNeverSpam: true
+filters:
+  - from: "@shop.com"
+    subject: "Sale"
+    label: "Marketing/Newsletters"
+    shouldArchive: true
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+
+	var stdout, stderr bytes.Buffer
+	if err := Run(context.Background(), "test-version", "2023-01-01T00:00:00Z", []string{"explain", tmpFile}, &stdout, &stderr); err != nil {
+		t.Fatalf("explain command failed: %v", err)
+	}
+	expected := "1. Mail from @shop.com with subject 'Sale' will be labeled Marketing/Newsletters, archived and never sent to spam."
+	if !strings.Contains(stdout.String(), expected) {
+		t.Errorf("Expected %q, got: %s", expected, stdout.String())
+	}
+
+	stdout.Reset()
+	if err := Run(context.Background(), "test-version", "2023-01-01T00:00:00Z", []string{"explain", "-format", "html", tmpFile}, &stdout, &stderr); err != nil {
+		t.Fatalf("explain -format html failed: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "<li>Mail from <strong>@shop.com</strong>") {
+		t.Errorf("Expected HTML output, got: %s", stdout.String())
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	valid := `author:
+  name: "Test User"
+  email: "test@example.com"
+filters:
+  - from: "a@example.com"
+    label: "A"
+`
+	tests := []struct {
+		name     string
+		content  string
+		args     []string
+		expected int
+	}{
+		{name: "success", content: valid, args: []string{"-force"}, expected: ExitOK},
+		{name: "usage", content: valid, args: []string{"-sort", "size"}, expected: ExitUsage},
+		{name: "parse", content: "author: [", expected: ExitParse},
+		{name: "unknown key", content: strings.Replace(valid, "label:", "lable:", 1), expected: ExitParse},
+		{name: "validation", content: strings.Replace(valid, "a@example.com", "bad", 1), expected: ExitValidation},
+		{name: "limits", content: valid + "  - from: \"b@example.com\"\n    label: \"B\"\nlimits:\n  maxFilters: 1\n  onExceed: fail\n", expected: ExitLimits},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpFile := testutils.CreateTempYAMLFile(t, tt.content)
+			defer testutils.CleanupFile(tmpFile)
+			defer testutils.CleanupFile(strings.TrimSuffix(tmpFile, filepath.Ext(tmpFile)) + ".xml")
+
+			var stdout, stderr bytes.Buffer
+			err := Run(context.Background(), "test-version", "2023-01-01T00:00:00Z", append(tt.args, tmpFile), &stdout, &stderr)
+			if code := ExitCode(err); code != tt.expected {
+				t.Errorf("Expected exit code %d, got %d (%v)", tt.expected, code, err)
+			}
+		})
+	}
+}
+
+func TestRun_FormatFlag(t *testing.T) {
+	content := `author:
+  name: "Test User"
+  email: "test@example.com"
+filters:
+  - from: "a@example.com"
+    label: "A"
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+	outputFile := strings.TrimSuffix(tmpFile, filepath.Ext(tmpFile)) + ".json"
+	defer testutils.CleanupFile(outputFile)
+	ctx := context.Background()
+
+	var stdout, stderr bytes.Buffer
+	err := Run(ctx, "test-version", "2023-01-01T00:00:00Z", []string{"-format", "sieve", tmpFile}, &stdout, &stderr)
+	if ExitCode(err) != ExitUsage || !strings.Contains(err.Error(), "unknown output format 'sieve'") {
+		t.Fatalf("Expected unknown format usage error, got: %v", err)
+	}
+
+	if err := Run(ctx, "test-version", "2023-01-01T00:00:00Z", []string{"-format", "json", tmpFile}, &stdout, &stderr); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "JSON file successfully generated: "+outputFile) {
+		t.Errorf("Expected JSON success message, got: %s", stdout.String())
+	}
+	jsonContent, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read JSON: %v", err)
+	}
+	if !strings.Contains(string(jsonContent), `"from": "a@example.com"`) {
+		t.Errorf("Expected filter properties in JSON, got:\n%s", jsonContent)
+	}
+}
+
+func TestRun_BackupFlag(t *testing.T) {
+	content := `author:
+  name: "Test User"
+  email: "test@example.com"
+filters:
+  - from: "a@example.com"
+    label: "A"
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+	outputFile := strings.TrimSuffix(tmpFile, filepath.Ext(tmpFile)) + ".xml"
+	defer testutils.CleanupFile(outputFile)
+	if err := os.WriteFile(outputFile, []byte("previous"), 0o644); err != nil {
+		t.Fatalf("Failed to write XML: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := Run(context.Background(), "test-version", "2023-01-01T00:00:00Z", []string{"-backup", tmpFile}, &stdout, &stderr); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	backups, _ := filepath.Glob(outputFile + ".*.bak")
+	if len(backups) != 1 {
+		t.Fatalf("Expected one backup, got %v", backups)
+	}
+	defer testutils.CleanupFile(backups[0])
+	if !strings.Contains(stdout.String(), "Previous file kept as: "+backups[0]) {
+		t.Errorf("Expected the backup to be reported, got: %s", stdout.String())
+	}
+	if backup, _ := os.ReadFile(backups[0]); string(backup) != "previous" {
+		t.Errorf("Expected the backup to hold the previous XML, got %q", backup)
+	}
+}
+
+func TestRun_RedactFlag(t *testing.T) {
+	t.Setenv("GRC_TEST_TOKEN", "private-label-token")
+	content := `author:
+  name: "Test User"
+  email: "test@example.com"
+filters:
+  - from: "boss@example.com"
+    label: "${env:GRC_TEST_TOKEN}"
+    forwardTo: "me.private@example.com"
+  - from: "not-an-address@"
+    label: "Broken"
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+	ctx := context.Background()
+
+	var stdout, stderr bytes.Buffer
+	err := Run(ctx, "test-version", "2023-01-01T00:00:00Z", []string{"-redact", "-verbose", tmpFile}, &stdout, &stderr)
+	if ExitCode(err) != ExitValidation {
+		t.Fatalf("Expected a validation error, got: %v", err)
+	}
+
+	fixed := strings.Replace(content, "  - from: \"not-an-address@\"\n    label: \"Broken\"\n", "", 1)
+	if err := os.WriteFile(tmpFile, []byte(fixed), 0o644); err != nil {
+		t.Fatalf("Failed to write YAML: %v", err)
+	}
+	outputFile := strings.TrimSuffix(tmpFile, filepath.Ext(tmpFile)) + ".xml"
+	defer testutils.CleanupFile(outputFile)
+
+	if err := Run(ctx, "test-version", "2023-01-01T00:00:00Z", []string{"-redact", "-verbose", tmpFile}, &stdout, &stderr); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	stdout.Reset()
+	if err := Run(ctx, "test-version", "2023-01-01T00:00:00Z", []string{"explain", "-redact", tmpFile}, &stdout, &stderr); err != nil {
+		t.Fatalf("explain failed: %v", err)
+	}
+	if err := Run(ctx, "test-version", "2023-01-01T00:00:00Z", []string{"export", "-redact", tmpFile}, &stdout, &stderr); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+
+	output := stdout.String() + stderr.String()
+	for _, leaked := range []string{"me.private@example.com", "boss@example.com", "private-label-token"} {
+		if strings.Contains(output, leaked) {
+			t.Errorf("Expected %s to be redacted, got:\n%s", leaked, output)
+		}
+	}
+	if !strings.Contains(output, "<redacted:") {
+		t.Errorf("Expected redaction placeholders, got:\n%s", output)
+	}
+
+	xml, err := os.ReadFile(outputFile)
+	if err != nil || !strings.Contains(string(xml), "me.private@example.com") {
+		t.Errorf("Expected the feed itself to keep the real address, got %v:\n%s", err, xml)
+	}
+}
+
+func TestRun_OutputIsInput(t *testing.T) {
+	jsonFile := filepath.Join(t.TempDir(), "config.json")
+	content := `{"author": {"name": "Test User", "email": "test@example.com"}, "filters": [{"from": "a@example.com", "label": "A"}]}`
+	if err := os.WriteFile(jsonFile, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write JSON: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	err := Run(context.Background(), "test-version", "2023-01-01T00:00:00Z", []string{"-format", "json", "-force", jsonFile}, &stdout, &stderr)
+	if ExitCode(err) != ExitUsage || !strings.Contains(err.Error(), "is the input configuration") {
+		t.Fatalf("Expected an output-is-input usage error, got: %v", err)
+	}
+	if got, _ := os.ReadFile(jsonFile); string(got) != content {
+		t.Errorf("Expected the configuration to be left untouched, got:\n%s", got)
+	}
+
+	// A relative output naming the absolute input, with or without extension
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd failed: %v", err)
+	}
+	relative, err := filepath.Rel(wd, jsonFile)
+	if err != nil {
+		t.Fatalf("Rel failed: %v", err)
+	}
+	yamlFile := filepath.Join(filepath.Dir(jsonFile), "config.yaml")
+	if err := os.WriteFile(yamlFile, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write YAML: %v", err)
+	}
+	err = Run(context.Background(), "test-version", "2023-01-01T00:00:00Z", []string{"-format", "yaml", "-force", yamlFile}, &stdout, &stderr)
+	if ExitCode(err) != ExitUsage {
+		t.Errorf("Expected -format yaml to refuse replacing config.yaml, got: %v", err)
+	}
+
+	for _, output := range []string{"./" + relative, strings.TrimSuffix(relative, ".json")} {
+		err := Run(context.Background(), "test-version", "2023-01-01T00:00:00Z", []string{"-format", "json", "-force", "-output", output, jsonFile}, &stdout, &stderr)
+		if ExitCode(err) != ExitUsage {
+			t.Errorf("%s: expected an output-is-input usage error, got: %v", output, err)
+		}
+	}
+	if got, _ := os.ReadFile(jsonFile); string(got) != content {
+		t.Errorf("Expected the configuration to be left untouched, got:\n%s", got)
+	}
+}
+
+func TestRun_ImportExportCommands(t *testing.T) {
+	csvFile := filepath.Join(t.TempDir(), "filters.csv")
+	content := "From,Label,shouldArchive\n@shop.com,Shopping,yes\nnews@example.com,News,\n"
+	if err := os.WriteFile(csvFile, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+	ctx := context.Background()
+
+	var stdout, stderr bytes.Buffer
+	err := Run(ctx, "test-version", "2023-01-01T00:00:00Z", []string{"import", "-name", "Test User", "-email", "test@example.com", csvFile}, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	for _, expected := range []string{`name: "Test User"`, `from: "@shop.com"`, "shouldArchive: true", `label: "News"`} {
+		if !strings.Contains(stdout.String(), expected) {
+			t.Errorf("Expected imported YAML to contain %q, got:\n%s", expected, stdout.String())
+		}
+	}
+
+	yamlFile := testutils.CreateTempYAMLFile(t, stdout.String())
+	defer testutils.CleanupFile(yamlFile)
+	stdout.Reset()
+	if err := Run(ctx, "test-version", "2023-01-01T00:00:00Z", []string{"export", yamlFile}, &stdout, &stderr); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	expected := "from,shouldArchive,label\n'@shop.com,true,Shopping\nnews@example.com,,News\n"
+	if stdout.String() != expected {
+		t.Errorf("Expected CSV:\n%s\ngot:\n%s", expected, stdout.String())
+	}
+
+	if err := os.WriteFile(csvFile, []byte("from,label\nbad,Work\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+	err = Run(ctx, "test-version", "2023-01-01T00:00:00Z", []string{"import", "-name", "Test User", "-email", "test@example.com", csvFile}, &stdout, &stderr)
+	if ExitCode(err) != ExitValidation || !strings.Contains(err.Error(), "row 2: filter 0: 'from' field 'bad'") {
+		t.Errorf("Expected validation error naming the row, got: %v", err)
+	}
+}
+
+func TestRun_AuditCommand(t *testing.T) {
+	content := `author:
+  name: "Test User"
+  email: "me@corp.com"
+policy:
+  allowedForwardDomains: ["corp.com"]
+filters:
+  - from: "boss@corp.com"
+    label: "Boss"
+    forwardTo: "assistant@corp.com"
+  - from: "alerts@corp.com"
+    label: "Alerts"
+    forwardTo: "me.private@gmail.com"
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+	ctx := context.Background()
+
+	var stdout, stderr bytes.Buffer
+	err := Run(ctx, "test-version", "2023-01-01T00:00:00Z", []string{"audit", tmpFile}, &stdout, &stderr)
+	if ExitCode(err) != ExitValidation || !strings.Contains(err.Error(), "filter 1: policy: forwardTo 'me.private@gmail.com'") {
+		t.Errorf("Expected a policy violation, got: %v", err)
+	}
+	for _, expected := range []string{"Forwarding rules: 2, 1 violating the policy", "forwards to assistant@corp.com [ok]", "forwards to me.private@gmail.com [VIOLATION]"} {
+		if !strings.Contains(stdout.String(), expected) {
+			t.Errorf("Expected %q in audit, got:\n%s", expected, stdout.String())
+		}
+	}
+
+	compliant := strings.Replace(content, "me.private@gmail.com", "me.private@corp.com", 1)
+	if err := os.WriteFile(tmpFile, []byte(compliant), 0o644); err != nil {
+		t.Fatalf("Failed to write YAML: %v", err)
+	}
+	stdout.Reset()
+	if err := Run(ctx, "test-version", "2023-01-01T00:00:00Z", []string{"audit", tmpFile}, &stdout, &stderr); err != nil {
+		t.Fatalf("audit of a compliant configuration failed: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "Forwarding rules: 2, 0 violating the policy") {
+		t.Errorf("Unexpected audit:\n%s", stdout.String())
+	}
+}
+
+func TestRun_PolicyFlag(t *testing.T) {
+	policyFile := filepath.Join(t.TempDir(), "corp.yaml")
+	policy := "rules:\n  - name: no-trash-corp\n    from: corp.com\n    forbid: shouldTrash\n"
+	if err := os.WriteFile(policyFile, []byte(policy), 0o644); err != nil {
+		t.Fatalf("Failed to write policy: %v", err)
+	}
+	content := `author:
+  name: "Test User"
+  email: "me@corp.com"
+filters:
+  - from: "alerts@corp.com"
+    shouldTrash: true
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+	outputFile := strings.TrimSuffix(tmpFile, filepath.Ext(tmpFile)) + ".xml"
+	defer testutils.CleanupFile(outputFile)
+	ctx := context.Background()
+
+	var stdout, stderr bytes.Buffer
+	err := Run(ctx, "test-version", "2023-01-01T00:00:00Z", []string{"-policy", policyFile, tmpFile}, &stdout, &stderr)
+	if ExitCode(err) != ExitValidation || !strings.Contains(err.Error(), "filter 0: policy 'corp' rule 'no-trash-corp': shouldTrash is forbidden for mail from corp.com") {
+		t.Errorf("Expected a policy violation, got: %v", err)
+	}
+	if _, statErr := os.Stat(outputFile); !os.IsNotExist(statErr) {
+		t.Errorf("Expected no output file when the policy is broken")
+	}
+
+	compliant := strings.Replace(content, "shouldTrash: true", "label: \"Alerts\"", 1)
+	if err := os.WriteFile(tmpFile, []byte(compliant), 0o644); err != nil {
+		t.Fatalf("Failed to write YAML: %v", err)
+	}
+	if err := Run(ctx, "test-version", "2023-01-01T00:00:00Z", []string{"-policy", policyFile, tmpFile}, &stdout, &stderr); err != nil {
+		t.Errorf("Run with a compliant configuration failed: %v", err)
+	}
+
+	err = Run(ctx, "test-version", "2023-01-01T00:00:00Z", []string{"-policy", policyFile + ".missing", tmpFile}, &stdout, &stderr)
+	if err == nil || !strings.Contains(err.Error(), "loading policy") {
+		t.Errorf("Expected a policy loading error, got: %v", err)
+	}
+}
+
+func TestRun_StructuredLogging(t *testing.T) {
+	content := `author:
+  name: "Test User"
+  email: "test@example.com"
+filters:
+  - from: "example@test.com"
+    label: "Test"
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+	outputFile := strings.TrimSuffix(tmpFile, filepath.Ext(tmpFile)) + ".xml"
+	defer testutils.CleanupFile(outputFile)
+	ctx := context.Background()
+
+	var stdout, stderr bytes.Buffer
+	err := Run(ctx, "test-version", "2023-01-01T00:00:00Z", []string{"-log-level", "debug", "-log-format", "json", tmpFile}, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	stages := map[string]bool{}
+	filterLogged := false
+	for _, line := range strings.Split(strings.TrimSpace(stderr.String()), "\n") {
+		var event map[string]any
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("Expected one JSON object per line, got %q: %v", line, err)
+		}
+		if stage, ok := event["stage"].(string); ok {
+			stages[stage] = true
+		}
+		if event["level"] == "DEBUG" && event["filter"] == float64(0) {
+			filterLogged = true
+		}
+		if event["msg"] == "saved feed" && (event["file"] != outputFile || event["duration"] == nil) {
+			t.Errorf("Unexpected save event: %v", event)
+		}
+	}
+	for _, stage := range []string{"load", "validate", "generate", "save"} {
+		if !stages[stage] {
+			t.Errorf("Expected an event for stage %s, got:\n%s", stage, stderr.String())
+		}
+	}
+	if !filterLogged {
+		t.Errorf("Expected a debug event for filter 0, got:\n%s", stderr.String())
+	}
+
+	stderr.Reset()
+	if err := Run(ctx, "test-version", "2023-01-01T00:00:00Z", []string{"-log-level", "warn", "-force", tmpFile}, &stdout, &stderr); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if stderr.Len() != 0 {
+		t.Errorf("Expected no info events at warn level, got: %s", stderr.String())
+	}
+
+	deprecated := strings.Replace(content, "filters:", "default:\n  hasAttachment: false\nfilters:", 1)
+	if err := os.WriteFile(tmpFile, []byte(deprecated), 0o644); err != nil {
+		t.Fatalf("Failed to write YAML: %v", err)
+	}
+	stderr.Reset()
+	if err := Run(ctx, "test-version", "2023-01-01T00:00:00Z", []string{"-verbose", "-log-format", "json", "-force", tmpFile}, &stdout, &stderr); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	warnings := 0
+	for _, line := range strings.Split(strings.TrimSpace(stderr.String()), "\n") {
+		var event map[string]any
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("Expected only JSON lines with a warning, got %q: %v", line, err)
+		}
+		if event["level"] == "WARN" {
+			warnings++
+		}
+	}
+	if warnings != 1 {
+		t.Errorf("Expected the deprecation warning logged once, got:\n%s", stderr.String())
+	}
+
+	for _, args := range [][]string{{"-log-level", "trace", tmpFile}, {"-log-format", "xml", tmpFile}} {
+		if err := Run(ctx, "test-version", "2023-01-01T00:00:00Z", args, &stdout, &stderr); ExitCode(err) != ExitUsage {
+			t.Errorf("%v: expected a usage error, got: %v", args, err)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package lsp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/carlosrabelo/grc/core/internal/rules"
+	"gopkg.in/yaml.v3"
+)
+
+// quickFixKind is the LSP kind of the offered code actions
+const quickFixKind = "quickfix"
+
+// missingKeyFixes lists the keys inserted to fix filters missing a condition
+// or an action
+var missingKeyFixes = map[string][]string{
+	"must define at least one condition": {`from: ""`},
+	"must define at least one action":    {`label: ""`, "shouldArchive: true"},
+}
+
+// sectionsByType maps Go type names in decoding errors to configuration sections
+var sectionsByType = map[string]string{
+	"FiltersConfig":    "config",
+	"Author":           "author",
+	"Defaults":         "default",
+	"CriteriaDefaults": "defaultCriteria",
+	"Limits":           "limits",
+	"Filter":           "filter",
+	"FilterGroup":      "group",
+}
+
+// codeActions offers fixes for the given diagnostics
+func codeActions(doc *document, uri string, diagnostics []Diagnostic) []CodeAction {
+	actions := []CodeAction{}
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Source != diagnosticSource {
+			continue
+		}
+		actions = append(actions, missingKeyActions(doc, uri, diagnostic)...)
+		actions = append(actions, unknownKeyActions(uri, diagnostic)...)
+	}
+	return actions
+}
+
+// missingKeyActions inserts a condition or an action into a filter lacking one
+func missingKeyActions(doc *document, uri string, diagnostic Diagnostic) []CodeAction {
+	var keys []string
+	for problem, fixes := range missingKeyFixes {
+		if strings.Contains(diagnostic.Message, problem) {
+			keys = fixes
+		}
+	}
+	match := filterRefRegex.FindStringSubmatch(diagnostic.Message)
+	if keys == nil || match == nil {
+		return nil
+	}
+
+	index, _ := strconv.Atoi(match[2])
+	filter := doc.filterNode(match[1], index)
+	if filter == nil || filter.Kind != yaml.MappingNode || len(filter.Content) == 0 {
+		return nil
+	}
+
+	actions := make([]CodeAction, 0, len(keys))
+	for _, key := range keys {
+		actions = append(actions, CodeAction{
+			Title:       fmt.Sprintf("Add %s", key),
+			Kind:        quickFixKind,
+			Diagnostics: []Diagnostic{diagnostic},
+			Edit:        WorkspaceEdit{Changes: map[string][]TextEdit{uri: {doc.appendToMapping(filter, key)}}},
+		})
+	}
+	return actions
+}
+
+// appendToMapping inserts a key after the last line of a block mapping
+func (d *document) appendToMapping(mapping *yaml.Node, text string) TextEdit {
+	indent := strings.Repeat(" ", mapping.Content[0].Column-1)
+	line := lastLine(mapping) // one-based, so it is the zero-based index of the next line
+
+	if line >= len(d.lines) {
+		end := d.position(len(d.lines)-1, len(d.line(len(d.lines)-1)))
+		return TextEdit{Range: Range{Start: end, End: end}, NewText: "\n" + indent + text}
+	}
+	start := Position{Line: line, Character: 0}
+	return TextEdit{Range: Range{Start: start, End: start}, NewText: indent + text + "\n"}
+}
+
+// unknownKeyActions replaces a misspelled key with the closest known key
+func unknownKeyActions(uri string, diagnostic Diagnostic) []CodeAction {
+	match := unknownFieldRegex.FindStringSubmatch(diagnostic.Message)
+	if match == nil {
+		return nil
+	}
+
+	suggestion := closestKey(match[1], rules.FieldDocs(sectionsByType[match[2]]))
+	if suggestion == "" {
+		return nil
+	}
+
+	return []CodeAction{{
+		Title:       fmt.Sprintf("Replace '%s' with '%s'", match[1], suggestion),
+		Kind:        quickFixKind,
+		Diagnostics: []Diagnostic{diagnostic},
+		Edit:        WorkspaceEdit{Changes: map[string][]TextEdit{uri: {{Range: diagnostic.Range, NewText: suggestion}}}},
+	}}
+}
+
+// closestKey returns the known key nearest to a misspelled one, or an empty
+// string when none is close enough
+func closestKey(key string, docs []rules.FieldDoc) string {
+	best, bestDistance := "", 3
+	for _, doc := range docs {
+		distance := editDistance(strings.ToLower(key), strings.ToLower(doc.Key))
+		if distance < bestDistance {
+			best, bestDistance = doc.Key, distance
+		}
+	}
+	return best
+}
+
+// editDistance computes the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
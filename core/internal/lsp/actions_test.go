@@ -0,0 +1,59 @@
+package lsp
+
+import (
+	"testing"
+)
+
+func TestCodeActions_MissingAction(t *testing.T) {
+	text := validConfig + "  - from: \"b@example.com\"\n    subject: \"Hi\"\n"
+	diagnostics := diagnose(text, "")
+
+	actions := codeActions(newDocument(text), "file:///grc.yaml", diagnostics)
+	if len(actions) != 2 {
+		t.Fatalf("Expected 2 code actions, got %+v", actions)
+	}
+
+	edit := actions[0].Edit.Changes["file:///grc.yaml"][0]
+	if actions[0].Title != `Add label: ""` || edit.NewText != "    label: \"\"\n" || edit.Range.Start != (Position{Line: 8, Character: 0}) {
+		t.Errorf("Unexpected fix: %s %+v", actions[0].Title, edit)
+	}
+}
+
+func TestCodeActions_MissingActionAtEndOfFile(t *testing.T) {
+	text := validConfig + "  - from: \"b@example.com\""
+	actions := codeActions(newDocument(text), "file:///grc.yaml", diagnose(text, ""))
+	if len(actions) == 0 {
+		t.Fatalf("Expected code actions")
+	}
+
+	edit := actions[1].Edit.Changes["file:///grc.yaml"][0]
+	if edit.NewText != "\n    shouldArchive: true" || edit.Range.Start != (Position{Line: 6, Character: 25}) {
+		t.Errorf("Unexpected fix at end of file: %+v", edit)
+	}
+}
+
+func TestCodeActions_MisspelledKey(t *testing.T) {
+	text := "author:\n  name: \"Test User\"\n  email: \"test@example.com\"\nfilters:\n  - from: \"a@example.com\"\n    lable: \"A\"\n"
+	actions := codeActions(newDocument(text), "file:///grc.yaml", diagnose(text, ""))
+	if len(actions) != 1 || actions[0].Title != "Replace 'lable' with 'label'" {
+		t.Fatalf("Expected a replacement fix, got %+v", actions)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"label", "label", 0},
+		{"lable", "label", 2},
+		{"shouldArchiv", "shouldArchive", 1},
+		{"", "abc", 3},
+	}
+
+	for _, tt := range tests {
+		if result := editDistance(tt.a, tt.b); result != tt.expected {
+			t.Errorf("editDistance(%q, %q) = %d, expected %d", tt.a, tt.b, result, tt.expected)
+		}
+	}
+}
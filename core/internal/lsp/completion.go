@@ -0,0 +1,186 @@
+package lsp
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/carlosrabelo/grc/core/internal/rules"
+)
+
+// Patterns recognizing what is being typed before the cursor
+var (
+	keyPrefixRegex   = regexp.MustCompile(`^(\s*(?:-\s+)*)[A-Za-z_]*$`)
+	valuePrefixRegex = regexp.MustCompile(`^\s*(?:-\s+)*([A-Za-z_]\w*)\s*:\s*["']?[^"'#]*$`)
+	labelValueRegex  = regexp.MustCompile(`^\s*(?:-\s+)*label\s*:\s*["']?([^"'#]+?)["']?\s*(?:#.*)?$`)
+)
+
+// complete suggests keys or values at the given position
+func complete(doc *document, position Position) []CompletionItem {
+	prefix := doc.line(position.Line)[:doc.offset(position)]
+
+	if match := keyPrefixRegex.FindStringSubmatch(prefix); match != nil {
+		section := sectionFor(doc.ancestors(position.Line, len(match[1])))
+		return keyCompletions(section)
+	}
+
+	if match := valuePrefixRegex.FindStringSubmatch(prefix); match != nil {
+		return valueCompletions(doc, match[1])
+	}
+	return []CompletionItem{}
+}
+
+// keyCompletions suggests the keys of a configuration section
+func keyCompletions(section string) []CompletionItem {
+	items := []CompletionItem{}
+	for _, doc := range rules.FieldDocs(section) {
+		items = append(items, CompletionItem{
+			Label:         doc.Key,
+			Kind:          completionKindProperty,
+			Detail:        doc.Type,
+			Documentation: doc.Description,
+			InsertText:    doc.Key + ": ",
+		})
+	}
+	return items
+}
+
+// valueCompletions suggests values for a key
+func valueCompletions(doc *document, key string) []CompletionItem {
+	items := []CompletionItem{}
+	value := func(label, detail string) {
+		items = append(items, CompletionItem{Label: label, Kind: completionKindValue, Detail: detail})
+	}
+
+	switch key {
+	case "smartLabel":
+		for _, label := range rules.SmartLabels {
+			value(label, rules.SmartLabelNames[label])
+		}
+	case "label":
+		for _, label := range doc.usedLabels() {
+			value(label, "used label")
+		}
+	case "use":
+		for _, name := range doc.templateNames() {
+			value(name, "template")
+		}
+	case "onExceed":
+		value(rules.LimitsWarn, "report exceeded limits as warnings")
+		value(rules.LimitsFail, "fail when a limit is exceeded")
+	default:
+		if strings.HasPrefix(key, "should") || key == "hasAttachment" || key == "excludeChats" || key == "inheritDefaults" ||
+			key == "requireForwardLabel" || key == "forbidForwardAndTrash" {
+			value("true", "")
+			value("false", "")
+		}
+	}
+	return items
+}
+
+// hover explains the key under the cursor
+func hover(doc *document, position Position) *Hover {
+	match := keyLineRegex.FindStringSubmatchIndex(doc.line(position.Line))
+	if match == nil {
+		return nil
+	}
+	start, end := match[4], match[5]
+	if offset := doc.offset(position); offset < start || offset > end {
+		return nil
+	}
+
+	key := doc.line(position.Line)[start:end]
+	section := sectionFor(doc.ancestors(position.Line, start))
+	for _, field := range rules.FieldDocs(section) {
+		if field.Key != key {
+			continue
+		}
+		return &Hover{
+			Contents: MarkupContent{
+				Kind:  "markdown",
+				Value: fmt.Sprintf("**%s** (%s)\n\n%s", field.Key, field.Type, field.Description),
+			},
+			Range: &Range{Start: doc.position(position.Line, start), End: doc.position(position.Line, end)},
+		}
+	}
+	return nil
+}
+
+// ancestors returns the keys enclosing a key at the given line and column,
+// innermost first
+func (d *document) ancestors(line, column int) []string {
+	var keys []string
+	for i := line - 1; i >= 0 && column > 0; i-- {
+		text := d.line(i)
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(strings.TrimSpace(text), "#") {
+			continue
+		}
+
+		match := keyLineRegex.FindStringSubmatch(text)
+		if match == nil {
+			continue
+		}
+		if keyColumn := len(match[1]); keyColumn < column {
+			keys = append(keys, match[2])
+			column = keyColumn
+		}
+	}
+	return keys
+}
+
+// sectionFor maps enclosing keys to the configuration section they describe
+func sectionFor(ancestors []string) string {
+	if len(ancestors) == 0 {
+		return "config"
+	}
+	if len(ancestors) > 1 && ancestors[1] == "templates" {
+		return "filter"
+	}
+
+	switch ancestors[0] {
+	case "filters":
+		return "filter"
+	case "groups":
+		return "group"
+	case "author", "default", "defaultCriteria", "limits", "policy":
+		return ancestors[0]
+	default:
+		return ""
+	}
+}
+
+// usedLabels lists the labels already used in the document
+func (d *document) usedLabels() []string {
+	seen := map[string]bool{}
+	for i := range d.lines {
+		if match := labelValueRegex.FindStringSubmatch(d.line(i)); match != nil {
+			seen[strings.TrimSpace(match[1])] = true
+		}
+	}
+	return sortedKeys(seen)
+}
+
+// templateNames lists the templates defined in the document
+func (d *document) templateNames() []string {
+	_, templates := rules.MappingEntry(d.root, "templates")
+	if templates == nil {
+		return nil
+	}
+
+	names := map[string]bool{}
+	for i := 0; i+1 < len(templates.Content); i += 2 {
+		names[templates.Content[i].Value] = true
+	}
+	return sortedKeys(names)
+}
+
+// sortedKeys returns the keys of a set in order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,83 @@
+package lsp
+
+import (
+	"strings"
+	"testing"
+)
+
+func completionLabels(items []CompletionItem) []string {
+	labels := make([]string, 0, len(items))
+	for _, item := range items {
+		labels = append(labels, item.Label)
+	}
+	return labels
+}
+
+func TestComplete(t *testing.T) {
+	text := `author:
+  name: "Test User"
+  email: "test@example.com"
+default:
+  sh
+filters:
+  - from: "a@example.com"
+    label: "@Work/Reports"
+  - from: "b@example.com"
+    smartLabel: 
+    label: 
+templates:
+  news:
+    shouldA
+policy:
+  req
+  forbidForwardAndTrash: 
+`
+	doc := newDocument(text)
+
+	tests := []struct {
+		name     string
+		position Position
+		contains string
+		excludes string
+	}{
+		{"default keys", Position{Line: 4, Character: 4}, "shouldArchive", "from"},
+		{"filter keys on a new item", Position{Line: 8, Character: 4}, "hasTheWord", "author"},
+		{"smartLabel values", Position{Line: 9, Character: 16}, "^smartlabel_promo", ""},
+		{"used labels", Position{Line: 10, Character: 11}, "@Work/Reports", ""},
+		{"template keys", Position{Line: 13, Character: 11}, "shouldArchive", "name"},
+		{"policy keys", Position{Line: 15, Character: 5}, "requireForwardLabel", "shouldArchive"},
+		{"policy values", Position{Line: 16, Character: 25}, "true", ""},
+		{"top-level keys", Position{Line: 17, Character: 0}, "defaultCriteria", "shouldArchive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labels := strings.Join(completionLabels(complete(doc, tt.position)), ",")
+			if !strings.Contains(","+labels+",", ","+tt.contains+",") {
+				t.Errorf("Expected %s among completions, got %s", tt.contains, labels)
+			}
+			if tt.excludes != "" && strings.Contains(","+labels+",", ","+tt.excludes+",") {
+				t.Errorf("Expected %s not to be offered, got %s", tt.excludes, labels)
+			}
+		})
+	}
+}
+
+func TestHover(t *testing.T) {
+	doc := newDocument(validConfig + "    shouldArchive: true\n")
+
+	result := hover(doc, Position{Line: 6, Character: 8})
+	if result == nil || !strings.Contains(result.Contents.Value, "**shouldArchive** (boolean)\n\nSkip the inbox") {
+		t.Fatalf("Expected hover for shouldArchive, got %+v", result)
+	}
+
+	if hover(doc, Position{Line: 6, Character: 22}) != nil {
+		t.Errorf("Expected no hover over a value")
+	}
+
+	policy := newDocument("policy:\n  allowedForwardDomains: [\"corp.com\"]\n")
+	result = hover(policy, Position{Line: 1, Character: 4})
+	if result == nil || !strings.Contains(result.Contents.Value, "**allowedForwardDomains** (list)") {
+		t.Errorf("Expected hover for allowedForwardDomains, got %+v", result)
+	}
+}
@@ -0,0 +1,146 @@
+package lsp
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/carlosrabelo/grc/core/internal/rules"
+)
+
+// diagnosticSource names grc in editor diagnostics
+const diagnosticSource = "grc"
+
+// Patterns locating configuration errors reported by the rules package
+var (
+	lineErrorRegex    = regexp.MustCompile(`^(?:yaml: )?line (\d+): `)
+	unknownFieldRegex = regexp.MustCompile(`field (\S+) not found in type rules\.(\w+)`)
+	filterRefRegex    = regexp.MustCompile(`^(?:group '([^']+)' )?filter (\d+)\b`)
+	fieldRefRegex     = regexp.MustCompile(`'(\w+)' field|field '(\w+)'`)
+	groupRefRegex     = regexp.MustCompile(`^group '([^']+)'`)
+	templateRefRegex  = regexp.MustCompile(`^template '([^']+)'`)
+	keyRefRegex       = regexp.MustCompile(`^(\w+)(?:\.(\w+))?`)
+)
+
+// diagnose validates a document with the same checks as grc itself and
+// locates every error and warning in the document. Relative file references
+// resolve against dir, the directory of the document.
+func diagnose(text, dir string) []Diagnostic {
+	doc := newDocument(text)
+	diagnostics := []Diagnostic{}
+
+	config, err := rules.ParseConfigIn([]byte(text), dir)
+	if err != nil {
+		return append(diagnostics, errorDiagnostics(doc, err)...)
+	}
+
+	for _, warning := range config.Warnings {
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    doc.locate(warning),
+			Severity: severityWarning,
+			Source:   diagnosticSource,
+			Message:  warning,
+		})
+	}
+	return diagnostics
+}
+
+// errorDiagnostics returns one diagnostic per configuration problem. Typed
+// validation errors are placed at the position they carry; the message is
+// only searched for a location when the error has none.
+func errorDiagnostics(doc *document, err error) []Diagnostic {
+	var diagnostics []Diagnostic
+	add := func(r Range, message string) {
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    r,
+			Severity: severityError,
+			Source:   diagnosticSource,
+			Message:  message,
+		})
+	}
+
+	validationErrs := rules.ValidationErrors(err)
+	if len(validationErrs) == 0 {
+		for _, message := range splitErrors(err.Error()) {
+			add(doc.locate(message), message)
+		}
+		return diagnostics
+	}
+
+	for _, validationErr := range validationErrs {
+		if validationErr.Line > 0 {
+			add(doc.positionRange(validationErr.Line, validationErr.Column), validationErr.Message)
+		} else {
+			add(doc.locate(validationErr.Message), validationErr.Message)
+		}
+	}
+	return diagnostics
+}
+
+// splitErrors splits aggregated YAML errors into one message per problem
+func splitErrors(message string) []string {
+	for _, prefix := range []string{"YAML validation error: ", "YAML syntax error: "} {
+		if rest, ok := strings.CutPrefix(message, prefix); ok {
+			return strings.Split(rest, "; ")
+		}
+	}
+	return []string{message}
+}
+
+// locate finds the part of the document an error message refers to
+func (d *document) locate(message string) Range {
+	if match := lineErrorRegex.FindStringSubmatch(message); match != nil {
+		line, _ := strconv.Atoi(match[1])
+		return d.locateOnLine(line-1, message)
+	}
+
+	if d.root == nil {
+		return d.lineRange(0)
+	}
+
+	if match := filterRefRegex.FindStringSubmatch(message); match != nil {
+		index, _ := strconv.Atoi(match[2])
+		if filter := d.filterNode(match[1], index); filter != nil {
+			if match := fieldRefRegex.FindStringSubmatch(message); match != nil {
+				if key, _ := rules.MappingEntry(filter, match[1]+match[2]); key != nil {
+					return d.nodeRange(key)
+				}
+			}
+			return d.mappingStart(filter)
+		}
+	}
+
+	if match := groupRefRegex.FindStringSubmatch(message); match != nil {
+		if group := d.groupNode(match[1]); group != nil {
+			return d.mappingStart(group)
+		}
+	}
+
+	if match := templateRefRegex.FindStringSubmatch(message); match != nil {
+		_, templates := rules.MappingEntry(d.root, "templates")
+		if key, _ := rules.MappingEntry(templates, match[1]); key != nil {
+			return d.nodeRange(key)
+		}
+	}
+
+	if match := keyRefRegex.FindStringSubmatch(message); match != nil {
+		if key, value := rules.MappingEntry(d.root, match[1]); key != nil {
+			if nested, _ := rules.MappingEntry(value, match[2]); nested != nil {
+				return d.nodeRange(nested)
+			}
+			return d.nodeRange(key)
+		}
+	}
+
+	return d.lineRange(0)
+}
+
+// locateOnLine spans a line, narrowed to the unknown key the message names
+func (d *document) locateOnLine(line int, message string) Range {
+	if match := unknownFieldRegex.FindStringSubmatch(message); match != nil {
+		if column := strings.Index(d.line(line), match[1]); column >= 0 {
+			return Range{Start: d.position(line, column), End: d.position(line, column+len(match[1]))}
+		}
+	}
+	return d.lineRange(line)
+}
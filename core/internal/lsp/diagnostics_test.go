@@ -0,0 +1,123 @@
+package lsp
+
+import (
+	"strings"
+	"testing"
+)
+
+const validConfig = `author:
+  name: "Test User"
+  email: "test@example.com"
+filters:
+  - from: "a@example.com"
+    label: "A"
+`
+
+func TestDiagnose(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		severity int
+		message  string
+		start    Position
+	}{
+		{
+			name:     "unknown key",
+			text:     strings.Replace(validConfig, "    label:", "    lable:", 1),
+			severity: severityError,
+			message:  "line 6: field lable not found in type rules.Filter",
+			start:    Position{Line: 5, Character: 4},
+		},
+		{
+			name:     "invalid address in a filter field",
+			text:     strings.Replace(validConfig, `"a@example.com"`, `"not-an-address"`, 1),
+			severity: severityError,
+			message:  "filter 0: 'from' field 'not-an-address' is not a valid email address or domain pattern",
+			start:    Position{Line: 4, Character: 4},
+		},
+		{
+			name:     "missing action",
+			text:     validConfig + "  - from: \"b@example.com\"\n",
+			severity: severityError,
+			message:  "filter 1 must define at least one action",
+			start:    Position{Line: 6, Character: 4},
+		},
+		{
+			name: "group filter",
+			text: `author:
+  name: "Test User"
+  email: "test@example.com"
+groups:
+  - name: work
+    filters:
+      - to: "bad"
+        label: "Work"
+`,
+			severity: severityError,
+			message:  "group 'work' filter 0: 'to' field 'bad' is not a valid email address or domain pattern",
+			start:    Position{Line: 6, Character: 8},
+		},
+		{
+			name:     "undefined variable in the author",
+			text:     strings.Replace(validConfig, `"Test User"`, `"${grc_undefined_name}"`, 1),
+			severity: severityError,
+			message:  "author: field 'name': undefined variable 'grc_undefined_name'",
+			start:    Position{Line: 1, Character: 2},
+		},
+		{
+			name:     "unknown key after an astral character",
+			text:     strings.Replace(validConfig, "    label: \"A\"\n", "", 1) + "  - {label: \"\U0001F680 Work\", frm: \"b@example.com\"}\n",
+			severity: severityError,
+			message:  "line 6: field frm not found in type rules.Filter",
+			start:    Position{Line: 5, Character: 23},
+		},
+		{
+			name:     "typed error after an astral character",
+			text:     validConfig + "  - {from: \"c@example.com\", label: \"\U0001F680\", forwardTo: \"bad\"}\n",
+			severity: severityError,
+			message:  "filter 1: 'forwardTo' field 'bad' is not a valid email address",
+			start:    Position{Line: 6, Character: 41},
+		},
+		{
+			name:     "deprecated default",
+			text:     strings.Replace(validConfig, "filters:", "default:\n  hasAttachment: false\nfilters:", 1),
+			severity: severityWarning,
+			message:  "default.hasAttachment: false is deprecated and has no effect; remove it",
+			start:    Position{Line: 4, Character: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diagnostics := diagnose(tt.text, "")
+			if len(diagnostics) != 1 {
+				t.Fatalf("Expected 1 diagnostic, got %+v", diagnostics)
+			}
+			diagnostic := diagnostics[0]
+			if diagnostic.Severity != tt.severity || diagnostic.Message != tt.message {
+				t.Errorf("Expected %q (severity %d), got %q (severity %d)", tt.message, tt.severity, diagnostic.Message, diagnostic.Severity)
+			}
+			if diagnostic.Range.Start != tt.start {
+				t.Errorf("Expected diagnostic at %+v, got %+v", tt.start, diagnostic.Range.Start)
+			}
+		})
+	}
+}
+
+func TestDiagnose_ValidConfig(t *testing.T) {
+	if diagnostics := diagnose(validConfig, ""); len(diagnostics) != 0 {
+		t.Errorf("Expected no diagnostics, got %+v", diagnostics)
+	}
+}
+
+func TestDiagnose_ReportsEveryProblem(t *testing.T) {
+	text := strings.Replace(validConfig, `"a@example.com"`, `"not-an-address"`, 1) + "  - from: \"b@example.com\"\n"
+
+	diagnostics := diagnose(text, "")
+	if len(diagnostics) != 2 {
+		t.Fatalf("Expected 2 diagnostics, got %+v", diagnostics)
+	}
+	if diagnostics[0].Range.Start.Line != 4 || diagnostics[1].Range.Start.Line != 6 {
+		t.Errorf("Expected diagnostics on lines 4 and 6, got %+v", diagnostics)
+	}
+}
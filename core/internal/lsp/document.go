@@ -0,0 +1,211 @@
+package lsp
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/carlosrabelo/grc/core/internal/rules"
+	"gopkg.in/yaml.v3"
+)
+
+// document is an open configuration file
+type document struct {
+	lines []string
+	root  *yaml.Node // top-level mapping, nil when the YAML does not parse
+}
+
+// keyLineRegex matches a line holding a mapping key, possibly in a list item
+var keyLineRegex = regexp.MustCompile(`^(\s*(?:-\s+)*)([A-Za-z_][\w.-]*)\s*:(.*)$`)
+
+// newDocument splits the text into lines and parses its YAML tree
+func newDocument(text string) *document {
+	doc := &document{lines: strings.Split(text, "\n")}
+
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(text), &node); err == nil &&
+		len(node.Content) == 1 && node.Content[0].Kind == yaml.MappingNode {
+		doc.root = node.Content[0]
+	}
+	return doc
+}
+
+// line returns a line without its trailing carriage return
+func (d *document) line(index int) string {
+	if index < 0 || index >= len(d.lines) {
+		return ""
+	}
+	return strings.TrimSuffix(d.lines[index], "\r")
+}
+
+// ============================================================================
+// Positions
+// ============================================================================
+
+// The document works with byte offsets within its lines. LSP positions count
+// UTF-16 code units and yaml.v3 columns count runes, so both are converted
+// where they enter or leave the document.
+
+// position converts a byte offset within a zero-based line into an LSP position
+func (d *document) position(line, offset int) Position {
+	text := d.line(line)
+	offset = max(0, min(offset, len(text)))
+
+	character := 0
+	for _, r := range text[:offset] {
+		character += utf16Len(r)
+	}
+	return Position{Line: line, Character: character}
+}
+
+// offset converts an LSP position into a byte offset within its line
+func (d *document) offset(position Position) int {
+	text := d.line(position.Line)
+	character := 0
+	for i, r := range text {
+		if character >= position.Character {
+			return i
+		}
+		character += utf16Len(r)
+	}
+	return len(text)
+}
+
+// columnOffset converts a one-based yaml.v3 column of a zero-based line into
+// a byte offset
+func (d *document) columnOffset(line, column int) int {
+	text := d.line(line)
+	runes := 0
+	for i := range text {
+		if runes == column-1 {
+			return i
+		}
+		runes++
+	}
+	return len(text)
+}
+
+// utf16Len returns the number of UTF-16 code units encoding a rune
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// lineRange spans the non-blank part of a zero-based line
+func (d *document) lineRange(index int) Range {
+	text := d.line(index)
+	start := len(text) - len(strings.TrimLeft(text, " \t-"))
+	return Range{
+		Start: d.position(index, start),
+		End:   d.position(index, len(strings.TrimRight(text, " \t"))),
+	}
+}
+
+// nodeRange spans a scalar node such as a mapping key
+func (d *document) nodeRange(node *yaml.Node) Range {
+	line := node.Line - 1
+	start := d.columnOffset(line, node.Column)
+	return Range{Start: d.position(line, start), End: d.position(line, start+len(node.Value))}
+}
+
+// positionRange spans the node at a one-based YAML line and column, or the
+// rest of the line when no node starts there
+func (d *document) positionRange(line, column int) Range {
+	if node := nodeAt(d.root, line, column); node != nil {
+		if node.Kind == yaml.ScalarNode {
+			return d.nodeRange(node)
+		}
+		return d.mappingStart(node)
+	}
+
+	r := d.lineRange(line - 1)
+	if column > 0 {
+		r.Start = d.position(line-1, d.columnOffset(line-1, column))
+	}
+	return r
+}
+
+// nodeAt returns the node starting at a one-based line and column,
+// preferring a scalar over the collection that starts at the same place
+func nodeAt(node *yaml.Node, line, column int) *yaml.Node {
+	if node == nil || node.Line > line {
+		return nil
+	}
+	for _, child := range node.Content {
+		if found := nodeAt(child, line, column); found != nil {
+			return found
+		}
+	}
+	if node.Line == line && node.Column == column {
+		return node
+	}
+	return nil
+}
+
+// sequenceItem returns an element of a sequence node
+func sequenceItem(sequence *yaml.Node, index int) *yaml.Node {
+	if sequence == nil || sequence.Kind != yaml.SequenceNode || index < 0 || index >= len(sequence.Content) {
+		return nil
+	}
+	return sequence.Content[index]
+}
+
+// groupNode finds a group by its slash-separated path, matching group names
+// or their "#index" fallback
+func (d *document) groupNode(path string) *yaml.Node {
+	_, groups := rules.MappingEntry(d.root, "groups")
+	var group *yaml.Node
+	for _, name := range strings.Split(path, "/") {
+		group = nil
+		if groups == nil {
+			return nil
+		}
+		for i, candidate := range groups.Content {
+			_, nameNode := rules.MappingEntry(candidate, "name")
+			if (nameNode != nil && strings.TrimSpace(nameNode.Value) == name) || "#"+strconv.Itoa(i) == name {
+				group = candidate
+				break
+			}
+		}
+		if group == nil {
+			return nil
+		}
+		_, groups = rules.MappingEntry(group, "groups")
+	}
+	return group
+}
+
+// filterNode finds a filter by its index, within a group when path is set
+func (d *document) filterNode(path string, index int) *yaml.Node {
+	parent := d.root
+	if path != "" {
+		parent = d.groupNode(path)
+	}
+	_, filters := rules.MappingEntry(parent, "filters")
+	return sequenceItem(filters, index)
+}
+
+// mappingStart spans the first key of a mapping, where a list item begins
+func (d *document) mappingStart(mapping *yaml.Node) Range {
+	if mapping.Kind == yaml.MappingNode && len(mapping.Content) > 0 {
+		return d.nodeRange(mapping.Content[0])
+	}
+	start := d.position(mapping.Line-1, d.columnOffset(mapping.Line-1, mapping.Column))
+	return Range{Start: start, End: start}
+}
+
+// lastLine returns the last one-based line used by a node and its children
+func lastLine(node *yaml.Node) int {
+	line := node.Line
+	for _, child := range node.Content {
+		if childLine := lastLine(child); childLine > line {
+			line = childLine
+		}
+	}
+	if node.Kind == yaml.ScalarNode && (node.Style&(yaml.LiteralStyle|yaml.FoldedStyle)) != 0 {
+		line += strings.Count(strings.TrimSuffix(node.Value, "\n"), "\n") + 1
+	}
+	return line
+}
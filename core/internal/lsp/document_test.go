@@ -0,0 +1,35 @@
+package lsp
+
+import "testing"
+
+func TestDocument_Positions(t *testing.T) {
+	// "é" takes two bytes and one UTF-16 unit, "🚀" four bytes and two units
+	doc := newDocument("label: \"é🚀\" # x\n")
+
+	tests := []struct {
+		name   string
+		offset int
+		char   int
+	}{
+		{"line start", 0, 0},
+		{"before the accent", 8, 8},
+		{"after the accent", 10, 9},
+		{"after the astral character", 14, 11},
+		{"line end", 19, 16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			position := doc.position(0, tt.offset)
+			if position.Character != tt.char {
+				t.Errorf("Expected byte offset %d at character %d, got %d", tt.offset, tt.char, position.Character)
+			}
+			if offset := doc.offset(position); offset != tt.offset {
+				t.Errorf("Expected character %d at byte offset %d, got %d", tt.char, tt.offset, offset)
+			}
+		})
+	}
+
+	if offset := doc.columnOffset(0, 11); offset != 14 {
+		t.Errorf("Expected rune column 11 at byte offset 14, got %d", offset)
+	}
+}
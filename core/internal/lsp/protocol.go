@@ -0,0 +1,219 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// ============================================================================
+// JSON-RPC Framing
+// ============================================================================
+
+// request is an incoming JSON-RPC request or notification
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// isNotification reports whether the request expects no response
+func (r request) isNotification() bool {
+	return len(r.ID) == 0
+}
+
+// response is an outgoing JSON-RPC response
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result"`
+}
+
+// errorResponse is an outgoing JSON-RPC error response
+type errorResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Error   responseError   `json:"error"`
+}
+
+// responseError describes a failed request
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// notification is an outgoing JSON-RPC notification
+type notification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
+
+// JSON-RPC error codes
+const (
+	codeParseError     = -32700
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// readMessage reads one Content-Length framed message
+func readMessage(reader *bufio.Reader) ([]byte, error) {
+	headers, err := textproto.NewReader(reader).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(strings.TrimSpace(headers.Get("Content-Length")))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length header %q", headers.Get("Content-Length"))
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return nil, fmt.Errorf("reading message body: %w", err)
+	}
+	return body, nil
+}
+
+// writeMessage writes a Content-Length framed message
+func writeMessage(writer io.Writer, message any) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("encoding message: %w", err)
+	}
+	if _, err := fmt.Fprintf(writer, "Content-Length: %d\r\n\r\n%s", len(body), body); err != nil {
+		return fmt.Errorf("writing message: %w", err)
+	}
+	return nil
+}
+
+// ============================================================================
+// LSP Types
+// ============================================================================
+
+// Position is a zero-based line and character offset
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// Range is a span between two positions
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Diagnostic severities
+const (
+	severityError   = 1
+	severityWarning = 2
+)
+
+// Diagnostic reports a problem in a document
+type Diagnostic struct {
+	Range    Range  `json:"range"`
+	Severity int    `json:"severity"`
+	Source   string `json:"source"`
+	Message  string `json:"message"`
+}
+
+// TextEdit replaces a range of a document
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+// WorkspaceEdit groups text edits by document URI
+type WorkspaceEdit struct {
+	Changes map[string][]TextEdit `json:"changes"`
+}
+
+// CodeAction is a fix offered for diagnostics
+type CodeAction struct {
+	Title       string        `json:"title"`
+	Kind        string        `json:"kind"`
+	Diagnostics []Diagnostic  `json:"diagnostics,omitempty"`
+	Edit        WorkspaceEdit `json:"edit"`
+}
+
+// Completion item kinds
+const (
+	completionKindValue    = 12
+	completionKindProperty = 10
+)
+
+// CompletionItem is a completion suggestion
+type CompletionItem struct {
+	Label         string `json:"label"`
+	Kind          int    `json:"kind"`
+	Detail        string `json:"detail,omitempty"`
+	Documentation string `json:"documentation,omitempty"`
+	InsertText    string `json:"insertText,omitempty"`
+}
+
+// MarkupContent is formatted text shown by the editor
+type MarkupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+// Hover is the text shown when hovering a key
+type Hover struct {
+	Contents MarkupContent `json:"contents"`
+	Range    *Range        `json:"range,omitempty"`
+}
+
+// textDocumentItem is an opened document
+type textDocumentItem struct {
+	URI  string `json:"uri"`
+	Text string `json:"text"`
+}
+
+// textDocumentIdentifier names a document
+type textDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+// didOpenParams are the parameters of textDocument/didOpen
+type didOpenParams struct {
+	TextDocument textDocumentItem `json:"textDocument"`
+}
+
+// didChangeParams are the parameters of textDocument/didChange with full sync
+type didChangeParams struct {
+	TextDocument   textDocumentIdentifier `json:"textDocument"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+}
+
+// didCloseParams are the parameters of textDocument/didClose
+type didCloseParams struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+}
+
+// positionParams are the parameters of completion and hover requests
+type positionParams struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+// codeActionParams are the parameters of textDocument/codeAction
+type codeActionParams struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+	Range        Range                  `json:"range"`
+	Context      struct {
+		Diagnostics []Diagnostic `json:"diagnostics"`
+	} `json:"context"`
+}
+
+// publishDiagnosticsParams are the parameters of textDocument/publishDiagnostics
+type publishDiagnosticsParams struct {
+	URI         string       `json:"uri"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
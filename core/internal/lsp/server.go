@@ -0,0 +1,197 @@
+// Package lsp implements a language server for grc configuration files,
+// speaking the Language Server Protocol over stdio.
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+)
+
+// textDocumentSyncFull asks clients to send the whole document on every change
+const textDocumentSyncFull = 1
+
+// Server holds the state of a language server session
+type Server struct {
+	out       io.Writer
+	documents map[string]string
+	shutdown  bool
+}
+
+// errExit stops the server when the client sends the exit notification
+var errExit = errors.New("exit")
+
+// Serve runs a language server reading requests from in and writing
+// responses to out until the client exits or in is closed
+func Serve(ctx context.Context, in io.Reader, out io.Writer) error {
+	server := &Server{out: out, documents: map[string]string{}}
+	reader := bufio.NewReader(in)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		body, err := readMessage(reader)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading request: %w", err)
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			if err := writeMessage(out, errorResponse{JSONRPC: "2.0", ID: json.RawMessage("null"),
+				Error: responseError{Code: codeParseError, Message: err.Error()}}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := server.handle(req); err != nil {
+			if errors.Is(err, errExit) {
+				if !server.shutdown {
+					return errors.New("exit received before shutdown")
+				}
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// handle dispatches a request to its handler and writes the response
+func (s *Server) handle(req request) error {
+	result, err := s.dispatch(req)
+	if req.isNotification() {
+		if errors.Is(err, errExit) {
+			return err
+		}
+		return nil
+	}
+
+	if err != nil {
+		code := codeInvalidParams
+		var methodErr methodNotFoundError
+		if errors.As(err, &methodErr) {
+			code = codeMethodNotFound
+		}
+		return writeMessage(s.out, errorResponse{JSONRPC: "2.0", ID: req.ID,
+			Error: responseError{Code: code, Message: err.Error()}})
+	}
+	return writeMessage(s.out, response{JSONRPC: "2.0", ID: req.ID, Result: result})
+}
+
+// methodNotFoundError reports a request for an unsupported method
+type methodNotFoundError string
+
+func (e methodNotFoundError) Error() string {
+	return fmt.Sprintf("method not found: %s", string(e))
+}
+
+// dispatch runs the handler of a method
+func (s *Server) dispatch(req request) (any, error) {
+	switch req.Method {
+	case "initialize":
+		return s.initialize(), nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "exit":
+		return nil, errExit
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.update(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		if len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		return nil, s.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		delete(s.documents, params.TextDocument.URI)
+		return nil, s.publish(params.TextDocument.URI, []Diagnostic{})
+	case "textDocument/completion":
+		var params positionParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return complete(s.document(params.TextDocument.URI), params.Position), nil
+	case "textDocument/hover":
+		var params positionParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return hover(s.document(params.TextDocument.URI), params.Position), nil
+	case "textDocument/codeAction":
+		var params codeActionParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return codeActions(s.document(params.TextDocument.URI), params.TextDocument.URI, params.Context.Diagnostics), nil
+	default:
+		return nil, methodNotFoundError(req.Method)
+	}
+}
+
+// initialize describes the server capabilities
+func (s *Server) initialize() any {
+	return map[string]any{
+		"capabilities": map[string]any{
+			"textDocumentSync":   textDocumentSyncFull,
+			"completionProvider": map[string]any{"triggerCharacters": []string{":", " "}},
+			"hoverProvider":      true,
+			"codeActionProvider": true,
+		},
+		"serverInfo": map[string]any{"name": "grc"},
+	}
+}
+
+// update stores a document and publishes its diagnostics
+func (s *Server) update(uri, text string) error {
+	s.documents[uri] = text
+	return s.publish(uri, diagnose(text, documentDir(uri)))
+}
+
+// publish sends the diagnostics of a document to the client
+func (s *Server) publish(uri string, diagnostics []Diagnostic) error {
+	return writeMessage(s.out, notification{
+		JSONRPC: "2.0",
+		Method:  "textDocument/publishDiagnostics",
+		Params:  publishDiagnosticsParams{URI: uri, Diagnostics: diagnostics},
+	})
+}
+
+// document returns an open document, empty when the client did not open it
+func (s *Server) document(uri string) *document {
+	return newDocument(s.documents[uri])
+}
+
+// documentDir returns the directory of a file:// document URI, or "" for
+// other schemes, whose relative references resolve against the server's
+// working directory
+func documentDir(uri string) string {
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Scheme != "file" {
+		return ""
+	}
+	return filepath.Dir(filepath.FromSlash(parsed.Path))
+}
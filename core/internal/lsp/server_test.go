@@ -0,0 +1,126 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func frame(t *testing.T, messages ...string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	for _, message := range messages {
+		fmt.Fprintf(&buf, "Content-Length: %d\r\n\r\n%s", len(message), message)
+	}
+	return &buf
+}
+
+func readAll(t *testing.T, out *bytes.Buffer) []map[string]any {
+	t.Helper()
+	reader := bufio.NewReader(out)
+	var messages []map[string]any
+	for {
+		body, err := readMessage(reader)
+		if err != nil {
+			return messages
+		}
+		var message map[string]any
+		if err := json.Unmarshal(body, &message); err != nil {
+			t.Fatalf("Invalid JSON from server: %v", err)
+		}
+		messages = append(messages, message)
+	}
+}
+
+func TestServe_Session(t *testing.T) {
+	text, _ := json.Marshal(strings.Replace(validConfig, `"a@example.com"`, `"bad"`, 1))
+	in := frame(t,
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","method":"initialized","params":{}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///grc.yaml","text":`+string(text)+`}}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"textDocument/hover","params":{"textDocument":{"uri":"file:///grc.yaml"},"position":{"line":5,"character":5}}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"unknown/method"}`,
+		`{"jsonrpc":"2.0","id":4,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+	)
+	var out bytes.Buffer
+
+	if err := Serve(context.Background(), in, &out); err != nil {
+		t.Fatalf("Serve failed: %v", err)
+	}
+
+	messages := readAll(t, &out)
+	if len(messages) != 5 {
+		t.Fatalf("Expected 5 messages, got %d: %+v", len(messages), messages)
+	}
+
+	capabilities := messages[0]["result"].(map[string]any)["capabilities"].(map[string]any)
+	if capabilities["hoverProvider"] != true || capabilities["codeActionProvider"] != true {
+		t.Errorf("Unexpected capabilities: %+v", capabilities)
+	}
+
+	if messages[1]["method"] != "textDocument/publishDiagnostics" {
+		t.Fatalf("Expected diagnostics notification, got %+v", messages[1])
+	}
+	diagnostics := messages[1]["params"].(map[string]any)["diagnostics"].([]any)
+	if len(diagnostics) != 1 {
+		t.Errorf("Expected 1 diagnostic, got %+v", diagnostics)
+	}
+
+	contents := messages[2]["result"].(map[string]any)["contents"].(map[string]any)
+	if !strings.Contains(contents["value"].(string), "**label**") {
+		t.Errorf("Expected label hover, got %+v", contents)
+	}
+
+	if code := messages[3]["error"].(map[string]any)["code"].(float64); code != codeMethodNotFound {
+		t.Errorf("Expected method not found error, got %v", code)
+	}
+
+	if result, ok := messages[4]["result"]; !ok || result != nil {
+		t.Errorf("Expected null shutdown result, got %+v", messages[4])
+	}
+}
+
+func TestServe_RelativeFileReference(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "fwd.txt"), []byte("me@example.com\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write reference: %v", err)
+	}
+	uri := (&url.URL{Scheme: "file", Path: filepath.ToSlash(filepath.Join(dir, "grc.yaml"))}).String()
+	text, _ := json.Marshal(validConfig + "    forwardTo: \"${file:fwd.txt}\"\n")
+	in := frame(t,
+		`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"`+uri+`","text":`+string(text)+`}}}`,
+		`{"jsonrpc":"2.0","id":1,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+	)
+	var out bytes.Buffer
+
+	if err := Serve(context.Background(), in, &out); err != nil {
+		t.Fatalf("Serve failed: %v", err)
+	}
+
+	messages := readAll(t, &out)
+	if len(messages) != 2 {
+		t.Fatalf("Expected 1 message, got %+v", messages)
+	}
+	if diagnostics := messages[0]["params"].(map[string]any)["diagnostics"].([]any); len(diagnostics) != 0 {
+		t.Errorf("Expected the reference to resolve beside the document, got %+v", diagnostics)
+	}
+}
+
+func TestServe_ExitBeforeShutdown(t *testing.T) {
+	in := frame(t, `{"jsonrpc":"2.0","method":"exit"}`)
+	var out bytes.Buffer
+
+	err := Serve(context.Background(), in, &out)
+	if err == nil || !strings.Contains(err.Error(), "exit received before shutdown") {
+		t.Errorf("Expected exit error, got: %v", err)
+	}
+}
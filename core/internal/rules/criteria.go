@@ -0,0 +1,451 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ============================================================================
+// Structured Criteria
+// ============================================================================
+
+// Criterion holds a filter criterion rendered in Gmail search syntax.
+// In YAML it can be written as a plain string, a list of alternatives or a
+// mapping with any/all/none keys that may be nested:
+//
+//	from: "a.com OR b.com"
+//	from: ["a.com", "b.com"]
+//	from: {any: ["a.com", "b.com"], none: ["noreply@a.com"]}
+type Criterion string
+
+// criteriaOperators lists the keys accepted in structured criteria mappings
+var criteriaOperators = []string{"any", "all", "none"}
+
+// UnmarshalYAML renders lists and any/all/none structures into Gmail syntax
+func (c *Criterion) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		var value string
+		if err := node.Decode(&value); err != nil {
+			return err
+		}
+		*c = Criterion(value)
+		return nil
+	}
+
+	expr, err := renderCriteriaNode(node, nil)
+	if err != nil {
+		return &yaml.TypeError{Errors: []string{err.Error()}}
+	}
+	*c = Criterion(expr.text)
+	return nil
+}
+
+// UnmarshalTOML accepts the same shapes as UnmarshalYAML from a TOML value
+func (c *Criterion) UnmarshalTOML(value any) error {
+	node, err := tomlValueNode(value)
+	if err != nil {
+		return err
+	}
+	if err := c.UnmarshalYAML(node); err != nil {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			return errors.New(strings.Join(typeErr.Errors, "; "))
+		}
+		return err
+	}
+	return nil
+}
+
+// criteriaError reports a problem of a criteria node, with its line when the
+// node has one
+func criteriaError(node *yaml.Node, format string, args ...any) error {
+	message := fmt.Sprintf(format, args...)
+	if node.Line == 0 {
+		return errors.New(message)
+	}
+	return fmt.Errorf("line %d: %s", node.Line, message)
+}
+
+// criteriaExpr is a rendered criteria fragment
+type criteriaExpr struct {
+	text     string
+	compound bool // true when the fragment needs parentheses to be nested
+}
+
+// renderCriteriaNode renders a YAML node into a Gmail search expression.
+// When resolve is set, the ${name} references of each term are replaced
+// before the term is quoted, and terms Gmail cannot quote are rejected;
+// decoding only drops their double quotes, as interpolateConfig renders every
+// criterion again with resolve set.
+func renderCriteriaNode(node *yaml.Node, resolve func(string) (string, error)) (criteriaExpr, error) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		value := node.Value
+		if resolve != nil {
+			interpolated, err := interpolateString(value, resolve)
+			if err != nil {
+				return criteriaExpr{}, err
+			}
+			value = interpolated
+		}
+		term, err := quoteCriteriaTerm(value)
+		if err != nil && resolve != nil {
+			return criteriaExpr{}, err
+		}
+		if term == "" {
+			return criteriaExpr{}, criteriaError(node, "empty criteria value")
+		}
+		return criteriaExpr{text: term}, nil
+	case yaml.SequenceNode:
+		parts, err := renderCriteriaItems(node, resolve)
+		if err != nil {
+			return criteriaExpr{}, err
+		}
+		return joinAny(parts), nil
+	case yaml.MappingNode:
+		return renderCriteriaMapping(node, resolve)
+	default:
+		return criteriaExpr{}, criteriaError(node, "criteria must be a string, a list or an any/all/none mapping")
+	}
+}
+
+// renderCriteriaItems renders every element of a criteria list
+func renderCriteriaItems(node *yaml.Node, resolve func(string) (string, error)) ([]criteriaExpr, error) {
+	if node.Kind != yaml.SequenceNode {
+		part, err := renderCriteriaNode(node, resolve)
+		if err != nil {
+			return nil, err
+		}
+		return []criteriaExpr{part}, nil
+	}
+
+	if len(node.Content) == 0 {
+		return nil, criteriaError(node, "criteria list must not be empty")
+	}
+
+	parts := make([]criteriaExpr, 0, len(node.Content))
+	for _, item := range node.Content {
+		part, err := renderCriteriaNode(item, resolve)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+// renderCriteriaMapping renders an any/all/none mapping, combining its keys with AND
+func renderCriteriaMapping(node *yaml.Node, resolve func(string) (string, error)) (criteriaExpr, error) {
+	if len(node.Content) == 0 {
+		return criteriaExpr{}, criteriaError(node, "criteria mapping must use any, all or none")
+	}
+
+	groups := make([]criteriaExpr, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+
+		parts, err := renderCriteriaItems(value, resolve)
+		if err != nil {
+			return criteriaExpr{}, err
+		}
+
+		switch key.Value {
+		case "any":
+			groups = append(groups, joinAny(parts))
+		case "all":
+			groups = append(groups, joinAll(parts))
+		case "none":
+			groups = append(groups, joinNone(parts))
+		default:
+			return criteriaExpr{}, criteriaError(key, "unknown criteria operator '%s' (use %s)",
+				key.Value, strings.Join(criteriaOperators, ", "))
+		}
+	}
+
+	return joinAll(groups), nil
+}
+
+// AnyOf builds a criterion matching any of the given terms, quoting terms
+// as structured YAML criteria do; empty terms are skipped. Double quotes are
+// dropped from terms that need quoting; AnyOfTerms rejects such terms.
+func AnyOf(terms ...string) Criterion {
+	criterion, _ := AnyOfTerms(terms...)
+	return criterion
+}
+
+// AnyOfTerms builds a criterion like AnyOf, reporting terms that need
+// quoting but contain double quotes, which Gmail search cannot quote
+func AnyOfTerms(terms ...string) (Criterion, error) {
+	parts := make([]criteriaExpr, 0, len(terms))
+	var errs []error
+	for _, term := range terms {
+		quoted, err := quoteCriteriaTerm(term)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if quoted != "" {
+			parts = append(parts, criteriaExpr{text: quoted})
+		}
+	}
+	if len(parts) == 0 {
+		return "", errors.Join(errs...)
+	}
+	return Criterion(joinAny(parts).text), errors.Join(errs...)
+}
+
+// ErrCriteriaQuote reports a criteria term holding double quotes that needs
+// quoting: Gmail search has no escape for a quote inside a quoted phrase
+var ErrCriteriaQuote = errors.New("contains a double quote, which Gmail search cannot quote")
+
+// joinAny combines fragments with OR
+func joinAny(parts []criteriaExpr) criteriaExpr {
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	texts := make([]string, len(parts))
+	for i, part := range parts {
+		texts[i] = part.nested()
+	}
+	return criteriaExpr{text: strings.Join(texts, " OR "), compound: true}
+}
+
+// joinAll combines fragments with an implicit AND
+func joinAll(parts []criteriaExpr) criteriaExpr {
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	texts := make([]string, len(parts))
+	for i, part := range parts {
+		texts[i] = part.nested()
+	}
+	return criteriaExpr{text: strings.Join(texts, " "), compound: true}
+}
+
+// joinNone negates every fragment
+func joinNone(parts []criteriaExpr) criteriaExpr {
+	texts := make([]string, len(parts))
+	for i, part := range parts {
+		texts[i] = "-" + part.nested()
+	}
+	return criteriaExpr{text: strings.Join(texts, " "), compound: len(parts) > 1}
+}
+
+// nested returns the fragment wrapped in parentheses when needed
+func (e criteriaExpr) nested() string {
+	if e.compound {
+		return "(" + e.text + ")"
+	}
+	return e.text
+}
+
+// quoteCriteriaTerm quotes a single term when it contains spaces or grouping
+// characters. ${name} variable references are not considered grouping. A
+// term to quote that contains double quotes is returned without them, with
+// an ErrCriteriaQuote error.
+func quoteCriteriaTerm(term string) (string, error) {
+	term = strings.TrimSpace(term)
+	if term == "" {
+		return "", nil
+	}
+	if isQuoted(term) || !strings.ContainsAny(varReferenceRegex.ReplaceAllString(term, ""), " \t(){}") {
+		return term, nil
+	}
+	quoted := `"` + strings.ReplaceAll(term, `"`, "") + `"`
+	if strings.Contains(term, `"`) {
+		return quoted, fmt.Errorf("term '%s' %w", term, ErrCriteriaQuote)
+	}
+	return quoted, nil
+}
+
+// isQuoted reports whether the term is already wrapped in double quotes
+func isQuoted(term string) bool {
+	return len(term) >= 2 && strings.HasPrefix(term, `"`) && strings.HasSuffix(term, `"`)
+}
+
+// ============================================================================
+// Criteria Parsing
+// ============================================================================
+
+// criteriaToken is a lexical token of a Gmail search expression
+type criteriaToken struct {
+	text  string
+	start int // byte offset of the token in the expression
+	end   int // byte offset just past the token
+}
+
+// criteriaTerms extracts the individual terms of a Gmail search expression,
+// dropping operators, grouping and negation. It reports false when the
+// expression is malformed (dangling operators, unbalanced parentheses, ...).
+func criteriaTerms(value string) ([]string, bool) {
+	tokens, ok := tokenizeCriteria(value)
+	if !ok || len(tokens) == 0 {
+		return nil, false
+	}
+
+	terms := make([]string, 0, len(tokens))
+	depth := 0
+	expectOperand := true
+	for _, token := range tokens {
+		switch token.text {
+		case "(", "{", "-(", "-{":
+			depth++
+			expectOperand = true
+		case ")", "}":
+			if depth == 0 || expectOperand {
+				return nil, false
+			}
+			depth--
+		case "OR", "AND":
+			if expectOperand {
+				return nil, false
+			}
+			expectOperand = true
+		default:
+			term := strings.TrimPrefix(token.text, "-")
+			if term == "" {
+				return nil, false
+			}
+			terms = append(terms, strings.Trim(term, `"`))
+			expectOperand = false
+		}
+	}
+
+	if depth != 0 || expectOperand {
+		return nil, false
+	}
+	return terms, true
+}
+
+// positiveCriteriaTerms extracts the terms of a Gmail search expression that
+// a message is matched on, leaving out negated terms and the terms of
+// negated groups. It reports false when the expression is malformed.
+func positiveCriteriaTerms(value string) ([]string, bool) {
+	if _, ok := criteriaTerms(value); !ok {
+		return nil, false
+	}
+	tokens, _ := tokenizeCriteria(value)
+
+	var terms []string
+	var groups []bool // whether each enclosing group is negated
+	negations := 0
+	for _, token := range tokens {
+		switch token.text {
+		case "(", "{":
+			groups = append(groups, false)
+		case "-(", "-{":
+			groups = append(groups, true)
+			negations++
+		case ")", "}":
+			if groups[len(groups)-1] {
+				negations--
+			}
+			groups = groups[:len(groups)-1]
+		case "OR", "AND":
+		default:
+			negated := negations
+			if strings.HasPrefix(token.text, "-") {
+				negated++
+			}
+			if negated%2 == 0 {
+				terms = append(terms, strings.Trim(strings.TrimPrefix(token.text, "-"), `"`))
+			}
+		}
+	}
+	return terms, true
+}
+
+// splitTopLevelOr splits an expression like "a OR (b c) OR d" into its
+// alternatives. It reports false when the expression is malformed or is not
+// an OR of alternatives at the top level (e.g. "(a OR b) -c").
+func splitTopLevelOr(value string) ([]string, bool) {
+	if _, ok := criteriaTerms(value); !ok {
+		return nil, false
+	}
+
+	tokens, _ := tokenizeCriteria(value)
+	alternatives := make([]string, 0, len(tokens))
+	depth := 0
+	start := -1
+	end := 0
+	for _, token := range tokens {
+		switch token.text {
+		case "(", "{", "-(", "-{":
+			if depth == 0 && start >= 0 {
+				return nil, false
+			}
+			if start < 0 {
+				start = token.start
+			}
+			depth++
+		case ")", "}":
+			depth--
+			end = token.end
+		case "OR":
+			if depth == 0 {
+				alternatives = append(alternatives, value[start:end])
+				start = -1
+			}
+		default:
+			if depth == 0 && start >= 0 {
+				return nil, false
+			}
+			if start < 0 {
+				start = token.start
+			}
+			end = token.end
+		}
+	}
+	alternatives = append(alternatives, value[start:end])
+
+	return alternatives, true
+}
+
+// tokenizeCriteria splits an expression into terms, quoted phrases, operators and brackets
+func tokenizeCriteria(value string) ([]criteriaToken, bool) {
+	tokens := make([]criteriaToken, 0, 8)
+	start := -1
+
+	flush := func(end int) {
+		if start >= 0 {
+			tokens = append(tokens, criteriaToken{text: value[start:end], start: start, end: end})
+			start = -1
+		}
+	}
+
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case c == '"':
+			closing := strings.IndexByte(value[i+1:], '"')
+			if closing < 0 {
+				return nil, false
+			}
+			if start < 0 {
+				start = i
+			}
+			i += closing + 1
+		case c == ' ' || c == '\t' || c == '\n':
+			flush(i)
+		case c == '(' || c == '{':
+			if start >= 0 && value[start:i] == "-" {
+				tokens = append(tokens, criteriaToken{text: value[start : i+1], start: start, end: i + 1})
+				start = -1
+				continue
+			}
+			flush(i)
+			tokens = append(tokens, criteriaToken{text: string(c), start: i, end: i + 1})
+		case c == ')' || c == '}':
+			flush(i)
+			tokens = append(tokens, criteriaToken{text: string(c), start: i, end: i + 1})
+		default:
+			if start < 0 {
+				start = i
+			}
+		}
+	}
+	flush(len(value))
+
+	return tokens, true
+}
@@ -0,0 +1,259 @@
+package rules
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/carlosrabelo/grc/core/internal/testutils"
+	"gopkg.in/yaml.v3"
+)
+
+func TestCriterion_UnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected Criterion
+	}{
+		{"Plain string", `"a.com OR b.com"`, "a.com OR b.com"},
+		{"List", `["a.com", "b.com", "c.com"]`, "a.com OR b.com OR c.com"},
+		{"Single element list", `["a.com"]`, "a.com"},
+		{"Quoted phrase", `["Big Sale", "Offer"]`, `"Big Sale" OR Offer`},
+		{"Any mapping", `{any: ["a.com", "b.com"]}`, "a.com OR b.com"},
+		{"All mapping", `{all: ["invoice", "paid"]}`, "invoice paid"},
+		{"None mapping", `{none: ["draft", "test"]}`, "-draft -test"},
+		{"Any and none", `{any: ["a.com", "b.com"], none: ["noreply@a.com"]}`, "(a.com OR b.com) -noreply@a.com"},
+		{"Nested all inside any", `{any: [{all: ["invoice", "paid"]}, "receipt"]}`, "(invoice paid) OR receipt"},
+		{"Negated group", `{none: [["spam", "promo"]]}`, "-(spam OR promo)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result Criterion
+			if err := yaml.Unmarshal([]byte(tt.input), &result); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestCriterion_UnmarshalYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"Empty list", `[]`, "must not be empty"},
+		{"Unknown operator", `{either: ["a.com"]}`, "unknown criteria operator 'either'"},
+		{"Empty element", `["a.com", ""]`, "empty criteria value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result Criterion
+			err := yaml.Unmarshal([]byte(tt.input), &result)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected error containing %q, got: %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestCriteriaTerms(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected []string
+		ok       bool
+	}{
+		{"Single term", "a.com", []string{"a.com"}, true},
+		{"OR list", "a.com OR b.com", []string{"a.com", "b.com"}, true},
+		{"Grouped and negated", "(a.com OR b.com) -noreply@a.com", []string{"a.com", "b.com", "noreply@a.com"}, true},
+		{"Quoted phrase", `"Big Sale" OR Offer`, []string{"Big Sale", "Offer"}, true},
+		{"Dangling OR", "a.com OR", nil, false},
+		{"Double OR", "a.com OR OR b.com", nil, false},
+		{"Unbalanced parentheses", "(a.com OR b.com", nil, false},
+		{"Unterminated quote", `"a.com`, nil, false},
+		{"Empty", "  ", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			terms, ok := criteriaTerms(tt.value)
+			if ok != tt.ok {
+				t.Fatalf("Expected ok=%v, got %v (terms %v)", tt.ok, ok, terms)
+			}
+			if ok && !reflect.DeepEqual(terms, tt.expected) {
+				t.Errorf("Expected terms %v, got %v", tt.expected, terms)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_StructuredCriteria(t *testing.T) {
+	content := `author:
+  name: "Test User"
+  email: "test@example.com"
+filters:
+  - from:
+      - "shop.com"
+      - "@store.com"
+    subject:
+      any: ["Sale", "Big Deal"]
+    label: "Marketing"
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+
+	config, err := LoadConfig(tmpFile)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	filter := config.Filters[0]
+	if filter.From != "shop.com OR @store.com" {
+		t.Errorf("Expected rendered from criteria, got %q", filter.From)
+	}
+	if filter.Subject != `Sale OR "Big Deal"` {
+		t.Errorf("Expected rendered subject criteria, got %q", filter.Subject)
+	}
+}
+
+func TestLoadConfig_StructuredCriteriaVars(t *testing.T) {
+	content := `vars:
+  promo: "Big Sale"
+  domain: "shop.com"
+  senders: "a.com OR b.com"
+author:
+  name: "Test User"
+  email: "test@example.com"
+templates:
+  deals:
+    subject: ["${promo}", "Offer"]
+filters:
+  - from: ["news@${domain}", "@store.com"]
+    subject:
+      any: ["${promo}", "Deal"]
+    label: "Marketing"
+  - from: "news@${domain}"
+    use: deals
+    label: "Deals"
+  - from: "${senders}"
+    subject: "${promo}"
+    label: "Plain"
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+
+	config, err := LoadConfig(tmpFile)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		value    Criterion
+		expected Criterion
+	}{
+		{"list terms", config.Filters[0].From, "news@shop.com OR @store.com"},
+		{"term expanding to several words", config.Filters[0].Subject, `"Big Sale" OR Deal`},
+		{"template list", config.Filters[1].Subject, `"Big Sale" OR Offer`},
+		{"plain strings stay expressions", config.Filters[2].From, "a.com OR b.com"},
+		{"plain strings are not quoted", config.Filters[2].Subject, "Big Sale"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.value != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, tt.value)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_StructuredCriteriaInvalidElement(t *testing.T) {
+	content := `author:
+  name: "Test User"
+  email: "test@example.com"
+filters:
+  - from:
+      any: ["shop.com", "not-an-address"]
+    label: "Marketing"
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+
+	_, err := LoadConfig(tmpFile)
+	if err == nil || !strings.Contains(err.Error(), "invalid element 'not-an-address'") {
+		t.Errorf("Expected element validation error, got: %v", err)
+	}
+}
+
+func TestAnyOf(t *testing.T) {
+	tests := []struct {
+		terms    []string
+		expected Criterion
+	}{
+		{nil, ""},
+		{[]string{"a.com"}, "a.com"},
+		{[]string{"a.com", "", "Big Deal"}, `a.com OR "Big Deal"`},
+		{[]string{`"Big Deal"`, `say"hi`}, `"Big Deal" OR say"hi`},
+	}
+
+	for _, tt := range tests {
+		if result := AnyOf(tt.terms...); result != tt.expected {
+			t.Errorf("AnyOf(%q) = %q, expected %q", tt.terms, result, tt.expected)
+		}
+		if _, err := AnyOfTerms(tt.terms...); err != nil {
+			t.Errorf("AnyOfTerms(%q) failed: %v", tt.terms, err)
+		}
+	}
+
+	if _, err := AnyOfTerms("a.com", `say "hi" now`); !errors.Is(err, ErrCriteriaQuote) {
+		t.Errorf("Expected a quote error, got: %v", err)
+	}
+}
+
+func TestLoadConfig_RejectsQuotesInQuotedTerms(t *testing.T) {
+	content := `vars:
+  greeting: 'say "hi"'
+author:
+  name: "Test User"
+  email: "test@example.com"
+templates:
+  quoted:
+    hasTheWord: ['He said "no" twice']
+filters:
+  - subject: ["Big Sale", 'say "hi" now']
+    label: "A"
+  - subject: ["${greeting} now"]
+    label: "B"
+  - use: quoted
+    label: "C"
+  - subject: 'plain "strings" stay'
+    label: "D"
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+
+	_, err := LoadConfig(tmpFile)
+	errs := ValidationErrors(err)
+	expected := []struct {
+		filter int
+		field  string
+		line   int
+	}{{0, "subject", 10}, {1, "subject", 12}, {2, "hasTheWord", 14}}
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d criteria errors, got: %v", len(expected), err)
+	}
+	for i, want := range expected {
+		got := errs[i]
+		if got.Filter != want.filter || got.Field != want.field || got.Rule != RuleCriteria || got.Line != want.line ||
+			!strings.Contains(got.Message, "contains a double quote") {
+			t.Errorf("Error %d: unexpected %+v", i, *got)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package rules
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ============================================================================
+// CSV Import and Export
+// ============================================================================
+
+// csvFields lists the filter keys exported as CSV columns, in canonical
+// order. use and inheritDefaults are resolved by normalization.
+var csvFields = []string{
+	"from", "to", "subject", "hasTheWord",
+	"doesNotHaveTheWord", "notFrom", "notTo", "notSubject",
+	"list", "query", "hasAttachment", "excludeChats", "size",
+	"shouldArchive", "shouldMarkAsRead", "shouldStar", "shouldNeverSpam",
+	"shouldAlwaysMarkAsImportant", "shouldNeverMarkAsImportant", "shouldTrash",
+	"label", "smartLabel", "forwardTo",
+}
+
+// csvFormulaPrefixes start cells that spreadsheets evaluate as formulas
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// csvEscape marks the start of a cell written as text, the way spreadsheets
+// do for values typed with a leading apostrophe
+const csvEscape = "'"
+
+// csvBooleans maps the spellings accepted for boolean cells
+var csvBooleans = map[string]bool{
+	"true": true, "t": true, "yes": true, "y": true, "1": true, "x": true, "on": true,
+	"false": false, "f": false, "no": false, "n": false, "0": false, "off": false,
+}
+
+// ReadCSV reads filters from CSV. The header row names filter fields by
+// their YAML key or Go name, case-insensitively; every following row is a
+// filter. Empty cells leave the field unset, boolean cells accept
+// true/false, yes/no, y/n, 1/0, on/off and x, and use cells list templates
+// separated by commas. Blank rows are skipped.
+func ReadCSV(r io.Reader) ([]Filter, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("CSV is empty, expected a header row")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV: %w", err)
+	}
+	columns, err := csvColumns(header)
+	if err != nil {
+		return nil, err
+	}
+
+	var filters []Filter
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return filters, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading CSV: %w", err)
+		}
+
+		row, _ := reader.FieldPos(0)
+		if isBlankRecord(record) {
+			continue
+		}
+		filter, err := csvFilter(record, columns, row)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+}
+
+// ImportCSV builds a configuration from the filters of a CSV file and an
+// author. The result is decoded but not validated, like DecodeConfig;
+// validation errors carry the CSV row as their line.
+func ImportCSV(r io.Reader, author Author) (FiltersConfig, error) {
+	filters, err := ReadCSV(r)
+	if err != nil {
+		return FiltersConfig{}, &ParseError{Err: err}
+	}
+	return FiltersConfig{Author: author, Filters: filters}, nil
+}
+
+// WriteCSV writes filters as CSV with a header row, one column per field
+// set in any filter. Export the normalized filters of a configuration to get
+// rows ReadCSV turns back into equivalent filters.
+func WriteCSV(w io.Writer, filters []Filter) error {
+	values := make([]map[string]string, len(filters))
+	for i, filter := range filters {
+		values[i] = csvValues(filter)
+	}
+	return writeCSVRows(w, values)
+}
+
+// writeCSVRows writes rows of cells keyed by filter key, with a header of
+// the keys set in any row in canonical order
+func writeCSVRows(w io.Writer, values []map[string]string) error {
+	used := map[string]bool{}
+	for _, row := range values {
+		for name := range row {
+			used[name] = true
+		}
+	}
+
+	var header []string
+	for _, name := range csvFields {
+		if used[name] {
+			header = append(header, name)
+		}
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("writing CSV: %w", err)
+	}
+	for _, filterValues := range values {
+		record := make([]string, len(header))
+		for i, name := range header {
+			record[i] = filterValues[name]
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("writing CSV: %w", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("writing CSV: %w", err)
+	}
+	return nil
+}
+
+// csvEncoder writes the entries of a feed as CSV, one row per entry, in the
+// columns WriteCSV uses, so grc import reads the rows back as filters
+type csvEncoder struct{}
+
+func (csvEncoder) Encode(w io.Writer, feed Feed) error {
+	values := make([]map[string]string, len(feed.Entries))
+	for i, entry := range feed.Entries {
+		values[i] = csvEntryValues(entry)
+	}
+	return writeCSVRows(w, values)
+}
+
+func (csvEncoder) Extension() string { return ".csv" }
+
+// csvEntryValues renders the properties of a feed entry as CSV cells keyed
+// by filter key
+func csvEntryValues(entry Entry) map[string]string {
+	values := map[string]string{}
+	var size SizeCriterion
+	for _, property := range entry.Properties {
+		if property.Value == "" {
+			continue
+		}
+		switch property.Name {
+		case "smartLabelToApply":
+			values["smartLabel"] = csvEscapeCell(property.Value)
+		case "size":
+			size.Value, _ = strconv.Atoi(property.Value)
+		case "sizeOperator":
+			size.Operator = property.Value
+		case "sizeUnit":
+			size.Unit = property.Value
+		default:
+			values[property.Name] = csvEscapeCell(property.Value)
+		}
+	}
+	if size.Value > 0 {
+		values["size"] = size.String()
+	}
+	return values
+}
+
+// csvColumns maps header cells to filter struct fields. Blank header cells,
+// left by spreadsheets after the last column, map to no field.
+func csvColumns(header []string) ([]reflect.StructField, error) {
+	fields := map[string]reflect.StructField{}
+	filterType := reflect.TypeOf(Filter{})
+	for i := 0; i < filterType.NumField(); i++ {
+		field := filterType.Field(i)
+		if field.IsExported() {
+			fields[strings.ToLower(yamlFieldName(field))] = field
+			fields[strings.ToLower(field.Name)] = field
+		}
+	}
+
+	columns := make([]reflect.StructField, len(header))
+	seen := map[string]bool{}
+	for i, cell := range header {
+		name := strings.TrimSpace(strings.TrimPrefix(cell, "\ufeff"))
+		if name == "" {
+			continue
+		}
+		field, ok := fields[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("CSV header: column %d: unknown filter field '%s'", i+1, name)
+		}
+		if seen[field.Name] {
+			return nil, fmt.Errorf("CSV header: column %d: field '%s' appears twice", i+1, name)
+		}
+		seen[field.Name] = true
+		columns[i] = field
+	}
+	return columns, nil
+}
+
+// csvFilter decodes a CSV record into a filter declared at the given row
+func csvFilter(record []string, columns []reflect.StructField, row int) (Filter, error) {
+	var filter Filter
+	value := reflect.ValueOf(&filter).Elem()
+
+	for i, cell := range record {
+		cell = strings.TrimSpace(cell)
+		if cell == "" {
+			continue
+		}
+		if i >= len(columns) || columns[i].Index == nil {
+			return Filter{}, fmt.Errorf("CSV row %d: column %d has no header", row, i+1)
+		}
+
+		column := columns[i]
+		field := value.FieldByIndex(column.Index)
+		switch {
+		case field.Kind() == reflect.String:
+			field.SetString(csvUnescape(cell))
+		case column.Type == reflect.TypeOf(StringList{}):
+			var names StringList
+			for _, name := range strings.Split(cell, ",") {
+				if name = strings.TrimSpace(name); name != "" {
+					names = append(names, name)
+				}
+			}
+			field.Set(reflect.ValueOf(names))
+		case column.Type == reflect.TypeOf((*bool)(nil)):
+			parsed, ok := csvBooleans[strings.ToLower(cell)]
+			if !ok {
+				return Filter{}, fmt.Errorf("CSV row %d: column '%s': '%s' is not a boolean (use true/false, yes/no or 1/0)",
+					row, yamlFieldName(column), cell)
+			}
+			field.Set(reflect.ValueOf(&parsed))
+		}
+	}
+
+	// Validation errors of the filter point at its row
+	filter.origin.node = &yaml.Node{Line: row, Column: 1}
+	return filter, nil
+}
+
+// csvValues renders the set fields of a filter as CSV cells
+func csvValues(filter Filter) map[string]string {
+	values := map[string]string{}
+	value := reflect.ValueOf(filter)
+	filterType := value.Type()
+
+	for _, name := range csvFields {
+		structField, _ := fieldByYAMLName(filterType, name)
+		field := value.FieldByIndex(structField.Index)
+		switch {
+		case field.Kind() == reflect.String && field.String() != "":
+			values[name] = csvEscapeCell(field.String())
+		case field.Kind() == reflect.Pointer && !field.IsNil():
+			values[name] = strconv.FormatBool(field.Elem().Bool())
+		}
+	}
+	return values
+}
+
+// csvEscapeCell keeps spreadsheets from reading a cell as a formula by
+// prefixing an apostrophe to cells starting with = + - @, a tab or a carriage
+// return. Cells already starting with an apostrophe get another one, so
+// csvUnescape restores every value exactly.
+func csvEscapeCell(value string) string {
+	if strings.ContainsAny(value[:1], csvFormulaPrefixes+csvEscape) {
+		return csvEscape + value
+	}
+	return value
+}
+
+// csvUnescape removes the apostrophe csvEscapeCell adds. An apostrophe
+// followed by anything else is part of the value.
+func csvUnescape(cell string) string {
+	rest, ok := strings.CutPrefix(cell, csvEscape)
+	if ok && rest != "" && strings.ContainsAny(rest[:1], csvFormulaPrefixes+csvEscape) {
+		return rest
+	}
+	return cell
+}
+
+// isBlankRecord reports whether every cell of a record is empty
+func isBlankRecord(record []string) bool {
+	for _, cell := range record {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,172 @@
+package rules
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReadCSV(t *testing.T) {
+	content := "\ufeffFrom, label ,ShouldArchive,shouldStar,use,\n" +
+		"@shop.com,Shopping,yes,X,\"a, b\",\n" +
+		",,,,,\n" +
+		"news@example.com,News,N,,,\n"
+
+	filters, err := ReadCSV(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("ReadCSV failed: %v", err)
+	}
+	if len(filters) != 2 {
+		t.Fatalf("Expected 2 filters, got %d", len(filters))
+	}
+
+	first, second := filters[0], filters[1]
+	if first.From != "@shop.com" || first.Label != "Shopping" || !isTrue(first.ShouldArchive) || !isTrue(first.ShouldStar) {
+		t.Errorf("Unexpected first filter: %+v", first)
+	}
+	if len(first.Use) != 2 || first.Use[0] != "a" || first.Use[1] != "b" {
+		t.Errorf("Expected templates [a b], got %v", first.Use)
+	}
+	if second.ShouldArchive == nil || *second.ShouldArchive || second.ShouldStar != nil {
+		t.Errorf("Expected shouldArchive false and shouldStar unset, got %+v", second)
+	}
+	if second.origin.node == nil || second.origin.node.Line != 4 {
+		t.Errorf("Expected the second filter to come from row 4, got %+v", second.origin.node)
+	}
+}
+
+func TestReadCSV_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		message string
+	}{
+		{"empty", "", "CSV is empty"},
+		{"unknown column", "from,colour\n", "column 2: unknown filter field 'colour'"},
+		{"duplicate column", "label,Label\n", "field 'Label' appears twice"},
+		{"bad boolean", "from,shouldStar\na@example.com,maybe\n", "CSV row 2: column 'shouldStar': 'maybe' is not a boolean"},
+		{"extra cell", "from\na@example.com,Work\n", "CSV row 2: column 2 has no header"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadCSV(strings.NewReader(tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("Expected error containing %q, got: %v", tt.message, err)
+			}
+		})
+	}
+}
+
+func TestImportCSV_ValidationErrorRows(t *testing.T) {
+	config, err := ImportCSV(strings.NewReader("from,label\na@example.com,A\n\nb@example.com,\n"), Author{Name: "A", Email: "a@example.com"})
+	if err != nil {
+		t.Fatalf("ImportCSV failed: %v", err)
+	}
+
+	_, err = PrepareConfig(config)
+	got := ValidationErrors(err)
+	if len(got) != 1 || got[0].Rule != RuleAction || got[0].Line != 4 {
+		t.Errorf("Expected a missing action error on row 4, got %+v", got)
+	}
+}
+
+func TestWriteCSV_RoundTrip(t *testing.T) {
+	content := `author:
+  name: "Test User"
+  email: "test@example.com"
+default:
+  shouldNeverSpam: true
+groups:
+  - name: work
+    labelPrefix: "Work"
+    filters:
+      - from: ["a@example.com", "b@example.com"]
+        label: "Team"
+filters:
+  - subject: "Invoice, paid"
+    size: ">5MB"
+    shouldArchive: false
+`
+	config, err := ParseConfig([]byte(content))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, NormalizeFilters(config)); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+	expected := "from,subject,size,shouldArchive,shouldNeverSpam,label\n" +
+		",\"Invoice, paid\",>5MB,false,true,\n" +
+		"a@example.com OR b@example.com,,,,true,Work/Team\n"
+	if buf.String() != expected {
+		t.Errorf("Expected CSV:\n%s\ngot:\n%s", expected, buf.String())
+	}
+
+	filters, err := ReadCSV(&buf)
+	if err != nil {
+		t.Fatalf("ReadCSV failed: %v", err)
+	}
+	if len(filters) != 2 || filters[0].Subject != "Invoice, paid" || filters[1].Label != "Work/Team" || !isTrue(filters[1].ShouldNeverSpam) {
+		t.Errorf("Unexpected filters after round trip: %+v", filters)
+	}
+}
+
+func TestMarshalConfig(t *testing.T) {
+	config := FiltersConfig{
+		Author:  Author{Name: "Test User", Email: "test@example.com"},
+		Filters: []Filter{{From: "a@example.com", Label: "A"}},
+	}
+
+	output, err := MarshalConfig(config)
+	if err != nil {
+		t.Fatalf("MarshalConfig failed: %v", err)
+	}
+	expected := `author:
+  name: "Test User"
+  email: "test@example.com"
+
+filters:
+  - from: "a@example.com"
+    label: "A"
+`
+	if string(output) != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, output)
+	}
+}
+
+func TestWriteCSV_FormulaCells(t *testing.T) {
+	filters := []Filter{
+		{From: "@shop.com", Label: "=HYPERLINK(\"x\")"},
+		{From: "-spam@x.com", Label: "+1"},
+		{From: "a@x.com", Label: "'quoted", Subject: "'=literal"},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, filters); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+	expected := "from,subject,label\n" +
+		"'@shop.com,,\"'=HYPERLINK(\"\"x\"\")\"\n" +
+		"'-spam@x.com,,'+1\n" +
+		"a@x.com,''=literal,''quoted\n"
+	if buf.String() != expected {
+		t.Errorf("Expected CSV:\n%s\ngot:\n%s", expected, buf.String())
+	}
+
+	read, err := ReadCSV(&buf)
+	if err != nil {
+		t.Fatalf("ReadCSV failed: %v", err)
+	}
+	for i, filter := range read {
+		if filter.From != filters[i].From || filter.Label != filters[i].Label || filter.Subject != filters[i].Subject {
+			t.Errorf("Row %d: expected %+v, got %+v", i, filters[i], filter)
+		}
+	}
+
+	read, err = ReadCSV(strings.NewReader("from,label\n'tis@x.com,'Work\n"))
+	if err != nil || read[0].From != "'tis@x.com" || read[0].Label != "'Work" {
+		t.Errorf("Expected apostrophes not followed by a formula character to be kept, got %+v, %v", read, err)
+	}
+}
@@ -0,0 +1,125 @@
+package rules
+
+import "fmt"
+
+// CriteriaDefaults defines criteria added to every filter that does not set
+// them itself. Unlike action defaults they narrow what filters match, so they
+// live in their own defaultCriteria block.
+type CriteriaDefaults struct {
+	HasAttachment *bool `yaml:"hasAttachment,omitempty"`
+	ExcludeChats  *bool `yaml:"excludeChats,omitempty"`
+}
+
+// migrateDefaults moves the deprecated default.hasAttachment key to
+// defaultCriteria, recording a warning for every migrated or dropped key
+func migrateDefaults(config FiltersConfig) FiltersConfig {
+	if value := config.Defaults.HasAttachment; value != nil {
+		switch {
+		case config.DefaultCriteria.HasAttachment != nil:
+			config.Warnings = append(config.Warnings,
+				"default.hasAttachment is deprecated and ignored because defaultCriteria.hasAttachment is set; remove it")
+		case *value:
+			config.DefaultCriteria.HasAttachment = value
+			config.Warnings = append(config.Warnings,
+				"default.hasAttachment is deprecated; move it to defaultCriteria.hasAttachment")
+		default:
+			config.Warnings = append(config.Warnings,
+				"default.hasAttachment: false is deprecated and has no effect; remove it")
+		}
+		config.Defaults.HasAttachment = nil
+	}
+
+	config.Groups = migrateGroupDefaults(config.Groups, "", &config.Warnings)
+	return config
+}
+
+// migrateGroupDefaults drops the deprecated hasAttachment key from group
+// defaults, which have no criteria counterpart
+func migrateGroupDefaults(groups []FilterGroup, parent string, warnings *[]string) []FilterGroup {
+	if len(groups) == 0 {
+		return groups
+	}
+
+	migrated := make([]FilterGroup, len(groups))
+	for i, group := range groups {
+		path := groupName(group, i)
+		if parent != "" {
+			path = parent + "/" + path
+		}
+		if group.Defaults.HasAttachment != nil {
+			*warnings = append(*warnings, fmt.Sprintf(
+				"group '%s': default.hasAttachment is no longer supported and is ignored; set hasAttachment on the filters instead", path))
+			group.Defaults.HasAttachment = nil
+		}
+		group.Groups = migrateGroupDefaults(group.Groups, path, warnings)
+		migrated[i] = group
+	}
+	return migrated
+}
+
+// applyCriteriaDefaults fills criteria the filter does not set itself. Filters
+// with inheritDefaults: false are left untouched.
+func applyCriteriaDefaults(filter Filter, defaults CriteriaDefaults) Filter {
+	if filter.InheritDefaults != nil && !*filter.InheritDefaults {
+		return filter
+	}
+
+	if filter.HasAttachment == nil && defaults.HasAttachment != nil {
+		value := *defaults.HasAttachment
+		filter.HasAttachment = &value
+	}
+	if filter.ExcludeChats == nil && defaults.ExcludeChats != nil {
+		value := *defaults.ExcludeChats
+		filter.ExcludeChats = &value
+	}
+	return filter
+}
+
+// checkCriteriaDefaults warns about criteria defaults that end up applied to
+// every filter, changing what each of them matches
+func checkCriteriaDefaults(config FiltersConfig) []string {
+	if len(config.Filters) == 0 {
+		return nil
+	}
+
+	criteria := []struct {
+		name     string
+		value    *bool
+		isSet    func(Filter) bool
+		meanings [2]string // false, true
+	}{
+		{"hasAttachment", config.DefaultCriteria.HasAttachment,
+			func(f Filter) bool { return f.HasAttachment != nil },
+			[2]string{"only match messages without attachments", "only match messages with attachments"}},
+		{"excludeChats", config.DefaultCriteria.ExcludeChats,
+			func(f Filter) bool { return f.ExcludeChats != nil },
+			[2]string{"include chats", "exclude chats"}},
+	}
+
+	var warnings []string
+	for _, criterion := range criteria {
+		if criterion.value == nil {
+			continue
+		}
+
+		appliesToAll := true
+		for _, filter := range config.Filters {
+			if criterion.isSet(filter) || (filter.InheritDefaults != nil && !*filter.InheritDefaults) {
+				appliesToAll = false
+				break
+			}
+		}
+		if !appliesToAll {
+			continue
+		}
+
+		meaning := criterion.meanings[0]
+		if *criterion.value {
+			meaning = criterion.meanings[1]
+		}
+		warnings = append(warnings, fmt.Sprintf(
+			"defaultCriteria.%s: %t applies to every filter; all %d filters now %s",
+			criterion.name, *criterion.value, len(config.Filters), meaning))
+	}
+	return warnings
+}
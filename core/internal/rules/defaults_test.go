@@ -0,0 +1,157 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/carlosrabelo/grc/core/internal/testutils"
+)
+
+func TestLoadConfig_DefaultCriteria(t *testing.T) {
+	content := `author:
+  name: "Test User"
+  email: "test@example.com"
+defaultCriteria:
+  excludeChats: true
+  hasAttachment: true
+filters:
+  - from: "reports@example.com"
+    label: "Reports"
+  - from: "chat@example.com"
+    label: "Chat"
+    excludeChats: false
+    inheritDefaults: false
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+
+	config, err := LoadConfig(tmpFile)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if len(config.Warnings) != 0 {
+		t.Errorf("Expected no warnings when a filter opts out, got %v", config.Warnings)
+	}
+
+	feed := GenerateFeed(config, time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC))
+	first := feed.Entries[0].Properties
+	if !hasProperty(first, "hasAttachment", "true") || !hasProperty(first, "excludeChats", "true") {
+		t.Errorf("Expected criteria defaults on first filter, got %+v", first)
+	}
+	second := feed.Entries[1].Properties
+	if hasPropertyName(second, "hasAttachment") || !hasProperty(second, "excludeChats", "false") {
+		t.Errorf("Expected second filter to skip criteria defaults, got %+v", second)
+	}
+}
+
+func TestLoadConfig_DefaultCriteriaWarnsWhenAppliedToEveryFilter(t *testing.T) {
+	content := `author:
+  name: "Test User"
+  email: "test@example.com"
+defaultCriteria:
+  hasAttachment: true
+filters:
+  - from: "a@example.com"
+    label: "A"
+  - from: "b@example.com"
+    label: "B"
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+
+	config, err := LoadConfig(tmpFile)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	expected := "defaultCriteria.hasAttachment: true applies to every filter; all 2 filters now only match messages with attachments"
+	if len(config.Warnings) != 1 || config.Warnings[0] != expected {
+		t.Errorf("Expected warning %q, got %v", expected, config.Warnings)
+	}
+}
+
+func TestLoadConfig_DefaultCriteriaDoNotCountAsCondition(t *testing.T) {
+	content := `author:
+  name: "Test User"
+  email: "test@example.com"
+defaultCriteria:
+  hasAttachment: true
+filters:
+  - label: "Attachments"
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+
+	_, err := LoadConfig(tmpFile)
+	if err == nil || !strings.Contains(err.Error(), "filter 0 must define at least one condition") {
+		t.Errorf("Expected missing condition error, got: %v", err)
+	}
+}
+
+func TestLoadConfig_MigratesDefaultHasAttachment(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      string
+		attachment string // expected hasAttachment property, empty when absent
+		warning    string
+	}{
+		{"true is migrated", "true", "true", "default.hasAttachment is deprecated; move it to defaultCriteria.hasAttachment"},
+		{"false is dropped", "false", "", "default.hasAttachment: false is deprecated and has no effect; remove it"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := `author:
+  name: "Test User"
+  email: "test@example.com"
+default:
+  shouldArchive: true
+  hasAttachment: ` + tt.value + `
+filters:
+  - from: "a@example.com"
+    label: "A"
+  - from: "b@example.com"
+    label: "B"
+    hasAttachment: false
+`
+			tmpFile := testutils.CreateTempYAMLFile(t, content)
+			defer testutils.CleanupFile(tmpFile)
+
+			config, err := LoadConfig(tmpFile)
+			if err != nil {
+				t.Fatalf("LoadConfig failed: %v", err)
+			}
+			if len(config.Warnings) == 0 || config.Warnings[0] != tt.warning {
+				t.Errorf("Expected migration warning %q, got %v", tt.warning, config.Warnings)
+			}
+
+			props := GenerateFeed(config, time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)).Entries[0].Properties
+			if tt.attachment == "" && hasPropertyName(props, "hasAttachment") {
+				t.Errorf("Expected no hasAttachment property, got %+v", props)
+			}
+			if tt.attachment != "" && !hasProperty(props, "hasAttachment", tt.attachment) {
+				t.Errorf("Expected hasAttachment=%s, got %+v", tt.attachment, props)
+			}
+		})
+	}
+}
+
+func TestMigrateDefaults_GroupHasAttachment(t *testing.T) {
+	config := FiltersConfig{
+		Groups: []FilterGroup{{
+			Name: "work",
+			Groups: []FilterGroup{{
+				Name:     "reports",
+				Defaults: Defaults{HasAttachment: testutils.BoolPtr(true)},
+			}},
+		}},
+	}
+
+	migrated := migrateDefaults(config)
+	if migrated.Groups[0].Groups[0].Defaults.HasAttachment != nil {
+		t.Errorf("Expected group hasAttachment default to be dropped")
+	}
+	if len(migrated.Warnings) != 1 || !strings.Contains(migrated.Warnings[0], "group 'work/reports': default.hasAttachment is no longer supported") {
+		t.Errorf("Expected group migration warning, got %v", migrated.Warnings)
+	}
+}
@@ -0,0 +1,175 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ============================================================================
+// Output Encoders
+// ============================================================================
+
+// Built-in output formats
+const (
+	FormatXML  = "xml"
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatCSV  = "csv"
+
+	// DefaultFormat is the Gmail Atom XML imported by Gmail
+	DefaultFormat = FormatXML
+)
+
+// Encoder writes a feed in an output format
+type Encoder interface {
+	// Encode writes the feed to w
+	Encode(w io.Writer, feed Feed) error
+	// Extension returns the file extension of the format, e.g. ".xml"
+	Extension() string
+}
+
+// encoders maps format names to registered encoders
+var (
+	encodersMu sync.RWMutex
+	encoders   = map[string]Encoder{
+		FormatXML:  xmlEncoder{},
+		FormatJSON: jsonEncoder{},
+		FormatYAML: yamlEncoder{},
+		FormatCSV:  csvEncoder{},
+	}
+)
+
+// RegisterEncoder makes an encoder available under a format name. It panics
+// when the name is empty or already registered.
+func RegisterEncoder(name string, encoder Encoder) {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+
+	if name == "" || encoder == nil {
+		panic("rules: RegisterEncoder needs a name and an encoder")
+	}
+	if _, exists := encoders[name]; exists {
+		panic(fmt.Sprintf("rules: encoder '%s' is already registered", name))
+	}
+	encoders[name] = encoder
+}
+
+// LookupEncoder returns the encoder registered under a format name
+func LookupEncoder(name string) (Encoder, error) {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+
+	encoder, ok := encoders[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown output format '%s' (use %s)", name, strings.Join(encoderNames(), ", "))
+	}
+	return encoder, nil
+}
+
+// EncoderNames lists the registered format names in order
+func EncoderNames() []string {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+	return encoderNames()
+}
+
+// encoderNames lists the registered format names; callers hold encodersMu
+func encoderNames() []string {
+	names := make([]string, 0, len(encoders))
+	for name := range encoders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// xmlEncoder writes the Gmail Atom XML
+type xmlEncoder struct{}
+
+func (xmlEncoder) Encode(w io.Writer, feed Feed) error {
+	output, err := MarshalFeed(feed)
+	if err != nil {
+		return err
+	}
+	return writeEncoded(w, output)
+}
+
+func (xmlEncoder) Extension() string { return ".xml" }
+
+// jsonEncoder writes the feed as JSON
+type jsonEncoder struct{}
+
+func (jsonEncoder) Encode(w io.Writer, feed Feed) error {
+	output, err := json.MarshalIndent(newFeedDocument(feed), "", "  ")
+	if err != nil {
+		return fmt.Errorf("generating JSON: %w", err)
+	}
+	return writeEncoded(w, append(output, '\n'))
+}
+
+func (jsonEncoder) Extension() string { return ".json" }
+
+// yamlEncoder writes the feed as YAML
+type yamlEncoder struct{}
+
+func (yamlEncoder) Encode(w io.Writer, feed Feed) error {
+	var b strings.Builder
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(newFeedDocument(feed)); err != nil {
+		return fmt.Errorf("generating YAML: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("generating YAML: %w", err)
+	}
+	return writeEncoded(w, []byte(b.String()))
+}
+
+func (yamlEncoder) Extension() string { return ".yaml" }
+
+// feedDocument is the JSON and YAML form of a feed, with the properties of
+// each filter as a name to value mapping
+type feedDocument struct {
+	Title   string           `json:"title" yaml:"title"`
+	Updated string           `json:"updated" yaml:"updated"`
+	Author  Author           `json:"author" yaml:"author"`
+	Filters []filterDocument `json:"filters" yaml:"filters"`
+}
+
+// filterDocument is the JSON and YAML form of a feed entry
+type filterDocument struct {
+	ID         string            `json:"id" yaml:"id"`
+	Properties map[string]string `json:"properties" yaml:"properties"`
+}
+
+// newFeedDocument converts a feed for the JSON and YAML encoders
+func newFeedDocument(feed Feed) feedDocument {
+	document := feedDocument{
+		Title:   feed.Title,
+		Updated: feed.Updated,
+		Author:  feed.Author,
+		Filters: make([]filterDocument, 0, len(feed.Entries)),
+	}
+	for _, entry := range feed.Entries {
+		properties := make(map[string]string, len(entry.Properties))
+		for _, property := range entry.Properties {
+			properties[property.Name] = property.Value
+		}
+		document.Filters = append(document.Filters, filterDocument{ID: entry.ID, Properties: properties})
+	}
+	return document
+}
+
+// writeEncoded writes an encoded feed
+func writeEncoded(w io.Writer, output []byte) error {
+	if _, err := w.Write(output); err != nil {
+		return fmt.Errorf("writing feed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package rules
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/carlosrabelo/grc/core/internal/testutils"
+	"gopkg.in/yaml.v3"
+)
+
+func TestEncoders(t *testing.T) {
+	tests := []struct {
+		format    string
+		extension string
+		decode    func([]byte, any) error
+	}{
+		{FormatJSON, ".json", json.Unmarshal},
+		{FormatYAML, ".yaml", yaml.Unmarshal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			encoder, err := LookupEncoder(tt.format)
+			if err != nil {
+				t.Fatalf("LookupEncoder failed: %v", err)
+			}
+			if encoder.Extension() != tt.extension {
+				t.Errorf("Expected extension %s, got %s", tt.extension, encoder.Extension())
+			}
+
+			var buf bytes.Buffer
+			if err := encoder.Encode(&buf, testFeed()); err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+
+			var document feedDocument
+			if err := tt.decode(buf.Bytes(), &document); err != nil {
+				t.Fatalf("Output does not decode: %v\n%s", err, buf.String())
+			}
+			if document.Author.Email != "test@example.com" || document.Updated != "2023-01-01T12:00:00Z" || len(document.Filters) != 1 {
+				t.Fatalf("Unexpected document: %+v", document)
+			}
+			expected := map[string]string{"from": "a@example.com", "label": "A", "shouldArchive": "true"}
+			for name, value := range expected {
+				if document.Filters[0].Properties[name] != value {
+					t.Errorf("Expected %s=%s, got %+v", name, value, document.Filters[0].Properties)
+				}
+			}
+		})
+	}
+}
+
+func TestLookupEncoder_DefaultIsGmailXML(t *testing.T) {
+	encoder, err := LookupEncoder(DefaultFormat)
+	if err != nil {
+		t.Fatalf("LookupEncoder failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := encoder.Encode(&buf, testFeed()); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	expected, _ := MarshalFeed(testFeed())
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Errorf("Expected the default encoder to write the Gmail XML, got:\n%s", buf.String())
+	}
+
+	if _, err := LookupEncoder("sieve"); err == nil || !strings.Contains(err.Error(), "use csv, json, xml, yaml") {
+		t.Errorf("Expected unknown format error listing formats, got: %v", err)
+	}
+}
+
+func TestCSVEncoder_RoundTrips(t *testing.T) {
+	feed := testFeed(
+		Filter{From: "a@example.com", Size: ">5MB", SmartLabel: "^smartlabel_notification", ShouldArchive: testutils.BoolPtr(true)},
+		Filter{Subject: "=SUM(A1)", NotFrom: "b@example.com", Label: "Sums"},
+	)
+	encoder, err := LookupEncoder(FormatCSV)
+	if err != nil {
+		t.Fatalf("LookupEncoder failed: %v", err)
+	}
+	if encoder.Extension() != ".csv" {
+		t.Errorf("Expected extension .csv, got %s", encoder.Extension())
+	}
+
+	var buf bytes.Buffer
+	if err := encoder.Encode(&buf, feed); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	filters, err := ReadCSV(&buf)
+	if err != nil {
+		t.Fatalf("ReadCSV failed: %v\n%s", err, buf.String())
+	}
+
+	config := FiltersConfig{Author: feed.Author, Limits: Limits{MaxCriteriaLength: -1}, Filters: filters}
+	roundTrip := GenerateFeed(config, time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC))
+	for i, entry := range feed.Entries {
+		if !reflect.DeepEqual(roundTrip.Entries[i].Properties, entry.Properties) {
+			t.Errorf("Entry %d: expected %+v, got %+v", i, entry.Properties, roundTrip.Entries[i].Properties)
+		}
+	}
+}
+
+// countingEncoder writes the number of entries of a feed
+type countingEncoder struct{}
+
+func (countingEncoder) Encode(w io.Writer, feed Feed) error {
+	_, err := io.WriteString(w, strings.Repeat("#", len(feed.Entries)))
+	return err
+}
+
+func (countingEncoder) Extension() string { return ".count" }
+
+func TestRegisterEncoder(t *testing.T) {
+	RegisterEncoder("count-test", countingEncoder{})
+	defer func() {
+		encodersMu.Lock()
+		delete(encoders, "count-test")
+		encodersMu.Unlock()
+	}()
+
+	encoder, err := LookupEncoder("count-test")
+	if err != nil {
+		t.Fatalf("LookupEncoder failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := encoder.Encode(&buf, testFeed()); err != nil || buf.String() != "#" {
+		t.Errorf("Expected registered encoder output '#', got %q (%v)", buf.String(), err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected duplicate registration to panic")
+		}
+	}()
+	RegisterEncoder(FormatXML, countingEncoder{})
+}
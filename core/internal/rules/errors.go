@@ -0,0 +1,165 @@
+package rules
+
+import "gopkg.in/yaml.v3"
+
+// ============================================================================
+// Configuration Errors
+// ============================================================================
+
+// Rule codes identifying the check a ValidationError failed
+const (
+	RuleRequired  = "required"  // a required field or section is missing
+	RuleEmail     = "email"     // not a valid email address
+	RuleAddress   = "address"   // not a valid email address or domain pattern
+	RuleCondition = "condition" // filter without any condition
+	RuleAction    = "action"    // filter without any action
+	RuleSize      = "size"      // malformed size criterion
+	RuleCriteria  = "criteria"  // criteria term Gmail search cannot express
+	RuleEnum      = "enum"      // value outside the allowed set
+	RuleTemplate  = "template"  // unknown or nested template
+	RuleVariable  = "variable"  // malformed or undefined variable reference
+	RulePolicy    = "policy"    // filter breaking the forwarding policy
+)
+
+// ParseError reports content that cannot be decoded as a configuration:
+// YAML syntax errors, unknown keys and values of the wrong type
+type ParseError struct {
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError describes one problem of a decoded configuration.
+// PrepareConfig reports every problem it finds, joined with errors.Join.
+type ValidationError struct {
+	Filter  int    // index in Filters once groups are flattened, -1 outside filters
+	Field   string // YAML key of the offending field, empty for a whole filter or section
+	Value   string // offending value
+	Rule    string // Rule* code of the failed check
+	Line    int    // source line, 0 when the configuration was not decoded from YAML
+	Column  int    // source column, 0 when unknown
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// ValidationErrors returns every ValidationError in err, following wrapped
+// and joined errors
+func ValidationErrors(err error) []*ValidationError {
+	var found []*ValidationError
+	var walk func(error)
+	walk = func(err error) {
+		switch e := err.(type) {
+		case *ValidationError:
+			found = append(found, e)
+		case interface{ Unwrap() []error }:
+			for _, inner := range e.Unwrap() {
+				walk(inner)
+			}
+		case interface{ Unwrap() error }:
+			walk(e.Unwrap())
+		}
+	}
+	walk(err)
+	return found
+}
+
+// at positions the error on node. Nil nodes leave the error without a position.
+func (e *ValidationError) at(node *yaml.Node) *ValidationError {
+	if node != nil {
+		e.Line, e.Column = node.Line, node.Column
+	}
+	return e
+}
+
+// filterError builds a validation error of a filter, positioned on the key of
+// the field or on the filter itself
+func filterError(index int, filter Filter, field, value, rule, message string) *ValidationError {
+	err := &ValidationError{Filter: index, Field: field, Value: value, Rule: rule, Message: message}
+	if key, _ := MappingEntry(filter.origin.node, field); key != nil {
+		return err.at(key)
+	}
+	return err.at(filter.origin.node)
+}
+
+// sectionError builds a validation error of a top-level section, positioned
+// on the field within the section, or on the section key when the field is
+// absent
+func sectionError(config FiltersConfig, section, field, value, rule, message string) *ValidationError {
+	err := &ValidationError{Filter: -1, Field: field, Value: value, Rule: rule, Message: message}
+	key, node := MappingEntry(config.source, section)
+	if fieldKey, _ := MappingEntry(node, field); fieldKey != nil {
+		key = fieldKey
+	}
+	if key == nil {
+		key = config.source
+	}
+	return err.at(key)
+}
+
+// ============================================================================
+// Source Positions
+// ============================================================================
+
+// attachSource records the YAML nodes of the configuration and its filters,
+// used to position validation errors
+func attachSource(config *FiltersConfig, document *yaml.Node) {
+	root := document
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	if root.Kind != yaml.MappingNode {
+		return
+	}
+
+	config.source = root
+	_, filters := MappingEntry(root, "filters")
+	attachFilterNodes(config.Filters, filters)
+	_, groups := MappingEntry(root, "groups")
+	attachGroupNodes(config.Groups, groups)
+}
+
+// attachFilterNodes records the node of each filter of a sequence
+func attachFilterNodes(filters []Filter, sequence *yaml.Node) {
+	if sequence == nil || sequence.Kind != yaml.SequenceNode || len(sequence.Content) != len(filters) {
+		return
+	}
+	for i := range filters {
+		filters[i].origin.node = sequence.Content[i]
+	}
+}
+
+// attachGroupNodes records the filter nodes of each group, recursively
+func attachGroupNodes(groups []FilterGroup, sequence *yaml.Node) {
+	if sequence == nil || sequence.Kind != yaml.SequenceNode || len(sequence.Content) != len(groups) {
+		return
+	}
+	for i := range groups {
+		_, filters := MappingEntry(sequence.Content[i], "filters")
+		attachFilterNodes(groups[i].Filters, filters)
+		_, nested := MappingEntry(sequence.Content[i], "groups")
+		attachGroupNodes(groups[i].Groups, nested)
+	}
+}
+
+// MappingEntry returns the key and value nodes of a mapping entry, or nils
+// when mapping is not a mapping node or has no such key
+func MappingEntry(mapping *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if mapping == nil || mapping.Kind != yaml.MappingNode || key == "" {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i], mapping.Content[i+1]
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,108 @@
+package rules
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseConfig_ValidationErrors(t *testing.T) {
+	content := `author:
+  name: "Test User"
+  email: "not-an-email"
+limits:
+  onExceed: "explode"
+filters:
+  - from: "a@example.com"
+    label: "A"
+  - from: "bad"
+    size: ">5GB"
+groups:
+  - name: work
+    filters:
+      - to: "b@example.com"
+`
+
+	_, err := ParseConfig([]byte(content))
+	got := ValidationErrors(err)
+
+	expected := []ValidationError{
+		{Filter: -1, Field: "email", Value: "not-an-email", Rule: RuleEmail, Line: 3, Column: 3},
+		{Filter: -1, Field: "onExceed", Value: "explode", Rule: RuleEnum, Line: 5, Column: 3},
+		{Filter: 1, Rule: RuleAction, Line: 9, Column: 5},
+		{Filter: 1, Field: "from", Value: "bad", Rule: RuleAddress, Line: 9, Column: 5},
+		{Filter: 1, Field: "size", Value: ">5GB", Rule: RuleSize, Line: 10, Column: 5},
+		{Filter: 2, Rule: RuleAction, Line: 14, Column: 9},
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %d validation errors, got %d: %v", len(expected), len(got), err)
+	}
+	for i, want := range expected {
+		actual := *got[i]
+		actual.Message = ""
+		if actual != want {
+			t.Errorf("Error %d: expected %+v, got %+v (%s)", i, want, actual, got[i].Message)
+		}
+	}
+}
+
+func TestParseConfig_ErrorTypes(t *testing.T) {
+	_, err := ParseConfig([]byte("author: ["))
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Errorf("Expected *ParseError for a syntax error, got %T", err)
+	}
+
+	_, err = ParseConfig([]byte("author:\n  name: A\n  email: a@example.com\nfilters: []\n"))
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Rule != RuleRequired || validationErr.Line != 4 {
+		t.Errorf("Expected a required-filters *ValidationError on line 4, got %+v", validationErr)
+	}
+}
+
+func TestPrepareConfig_TemplateAndVariableErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		rule    string
+		field   string
+		line    int
+	}{
+		{
+			name:    "unknown template",
+			content: "author:\n  name: A\n  email: a@example.com\nfilters:\n  - use: missing\n    from: b@example.com\n",
+			rule:    RuleTemplate,
+			field:   "use",
+			line:    5,
+		},
+		{
+			name:    "undefined variable",
+			content: "author:\n  name: A\n  email: a@example.com\nfilters:\n  - from: b@example.com\n    label: \"${undefined_grc_var}\"\n",
+			rule:    RuleVariable,
+			field:   "label",
+			line:    6,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseConfig([]byte(tt.content))
+			got := ValidationErrors(err)
+			if len(got) != 1 || got[0].Rule != tt.rule || got[0].Field != tt.field || got[0].Line != tt.line || got[0].Filter != 0 {
+				t.Errorf("Expected %s error on '%s' at line %d, got %+v", tt.rule, tt.field, tt.line, got)
+			}
+		})
+	}
+}
+
+func TestPrepareConfig_BuiltConfigHasNoPosition(t *testing.T) {
+	config := FiltersConfig{
+		Author:  Author{Name: "A", Email: "a@example.com"},
+		Filters: []Filter{{From: "bad", Label: "A"}},
+	}
+
+	_, err := PrepareConfig(config)
+	got := ValidationErrors(err)
+	if len(got) != 1 || got[0].Line != 0 || got[0].Field != "from" {
+		t.Errorf("Expected one unpositioned 'from' error, got %+v", got)
+	}
+}
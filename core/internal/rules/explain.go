@@ -0,0 +1,194 @@
+package rules
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// ============================================================================
+// Plain-Language Explanations
+// ============================================================================
+
+// Output formats accepted by WriteExplanation
+const (
+	ExplainText     = "text"
+	ExplainMarkdown = "markdown"
+	ExplainHTML     = "html"
+)
+
+// markdownEscaper escapes characters with a meaning in Markdown text
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "<", `\<`)
+
+// explainer renders values quoted from the configuration in an output format
+type explainer struct {
+	value  func(string) string // addresses, labels and other names
+	phrase func(string) string // words searched in messages
+}
+
+// explainers maps output formats to their renderers
+var explainers = map[string]explainer{
+	ExplainText: {
+		value:  func(s string) string { return s },
+		phrase: func(s string) string { return "'" + s + "'" },
+	},
+	ExplainMarkdown: {
+		value:  func(s string) string { return "**" + markdownEscaper.Replace(s) + "**" },
+		phrase: func(s string) string { return "**" + markdownEscaper.Replace(s) + "**" },
+	},
+	ExplainHTML: {
+		value:  func(s string) string { return "<strong>" + html.EscapeString(s) + "</strong>" },
+		phrase: func(s string) string { return "<strong>" + html.EscapeString(s) + "</strong>" },
+	},
+}
+
+// ExplainFilter describes in plain English what a normalized filter does,
+// e.g. "Mail from @shop.com with subject 'Sale' will be labeled Newsletters
+// and archived"
+func ExplainFilter(filter Filter) string {
+	return explainers[ExplainText].sentence(filter)
+}
+
+// WriteExplanation describes every normalized filter of the configuration,
+// the same filters GenerateFeed turns into entries, in the given format
+func WriteExplanation(w io.Writer, config FiltersConfig, format string) error {
+	explain, ok := explainers[format]
+	if !ok {
+		return fmt.Errorf("unknown explain format '%s' (use %s, %s or %s)", format, ExplainText, ExplainMarkdown, ExplainHTML)
+	}
+
+	filters := NormalizeFilters(config)
+	sentences := make([]string, 0, len(filters))
+	for _, filter := range filters {
+		sentences = append(sentences, explain.sentence(filter))
+	}
+
+	var b strings.Builder
+	title := "Gmail filters"
+	if name := strings.TrimSpace(config.Author.Name); name != "" {
+		title += " for " + name
+	}
+
+	switch format {
+	case ExplainText:
+		fmt.Fprintf(&b, "%s\n\n", title)
+		for i, sentence := range sentences {
+			fmt.Fprintf(&b, "%d. %s\n", i+1, sentence)
+		}
+	case ExplainMarkdown:
+		fmt.Fprintf(&b, "# %s\n\n", markdownEscaper.Replace(title))
+		for i, sentence := range sentences {
+			fmt.Fprintf(&b, "%d. %s\n", i+1, sentence)
+		}
+	case ExplainHTML:
+		escaped := html.EscapeString(title)
+		fmt.Fprintf(&b, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n</head>\n<body>\n<h1>%s</h1>\n<ol>\n", escaped, escaped)
+		for _, sentence := range sentences {
+			fmt.Fprintf(&b, "<li>%s</li>\n", sentence)
+		}
+		b.WriteString("</ol>\n</body>\n</html>\n")
+	}
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("writing explanation: %w", err)
+	}
+	return nil
+}
+
+// sentence describes a filter as "<conditions> will be <actions>."
+func (e explainer) sentence(filter Filter) string {
+	actions := e.actions(filter)
+	if len(actions) == 0 {
+		return e.conditions(filter) + " will not be changed."
+	}
+	return e.conditions(filter) + " will be " + joinPhrases(actions) + "."
+}
+
+// conditions describes the messages a filter matches
+func (e explainer) conditions(filter Filter) string {
+	parts := []string{"Mail"}
+	add := func(format, value string, render func(string) string) {
+		if value != "" {
+			parts = append(parts, fmt.Sprintf(format, render(value)))
+		}
+	}
+
+	add("from %s", string(filter.From), e.value)
+	add("sent to %s", string(filter.To), e.value)
+	add("on the mailing list %s", string(filter.List), e.value)
+	add("with subject %s", string(filter.Subject), e.phrase)
+	add("containing %s", string(filter.HasTheWord), e.phrase)
+	add("matching the search %s", filter.Query, e.phrase)
+	add("not from %s", string(filter.NotFrom), e.value)
+	add("not sent to %s", string(filter.NotTo), e.value)
+	add("without %s in the subject", string(filter.NotSubject), e.phrase)
+	add("not containing %s", filter.DoesNotHaveTheWord, e.phrase)
+
+	if filter.HasAttachment != nil {
+		parts = append(parts, map[bool]string{true: "with attachments", false: "without attachments"}[*filter.HasAttachment])
+	}
+	if size, err := ParseSize(filter.Size); err == nil {
+		parts = append(parts, size.describe())
+	}
+	if isTrue(filter.ExcludeChats) {
+		parts = append(parts, "excluding chats")
+	}
+	return strings.Join(parts, " ")
+}
+
+// actions lists what a filter does to matching messages. Actions set to
+// false do nothing in Gmail and are left out.
+func (e explainer) actions(filter Filter) []string {
+	var actions []string
+	if filter.Label != "" {
+		actions = append(actions, "labeled "+e.value(filter.Label))
+	}
+	if filter.SmartLabel != "" {
+		category := filter.SmartLabel
+		if name, ok := SmartLabelNames[category]; ok {
+			category = name
+		}
+		actions = append(actions, "categorized as "+e.value(category))
+	}
+	if filter.ForwardTo != "" {
+		actions = append(actions, "forwarded to "+e.value(filter.ForwardTo))
+	}
+
+	flags := []struct {
+		value  *bool
+		phrase string
+	}{
+		{filter.ShouldArchive, "archived"},
+		{filter.ShouldMarkAsRead, "marked as read"},
+		{filter.ShouldStar, "starred"},
+		{filter.ShouldAlwaysMarkAsImportant, "always marked as important"},
+		{filter.ShouldNeverMarkAsImportant, "never marked as important"},
+		{filter.ShouldNeverSpam, "never sent to spam"},
+		{filter.ShouldTrash, "deleted"},
+	}
+	for _, flag := range flags {
+		if isTrue(flag.value) {
+			actions = append(actions, flag.phrase)
+		}
+	}
+	return actions
+}
+
+// describe renders a size criterion, e.g. "larger than 5 MB"
+func (s SizeCriterion) describe() string {
+	comparison := "larger than"
+	if s.Operator == sizeOperatorSmaller {
+		comparison = "smaller than"
+	}
+	unit := map[string]string{"s_sb": "bytes", "s_skb": "KB", "s_smb": "MB"}[s.Unit]
+	return fmt.Sprintf("%s %d %s", comparison, s.Value, unit)
+}
+
+// joinPhrases joins phrases as "a, b and c"
+func joinPhrases(phrases []string) string {
+	if len(phrases) == 1 {
+		return phrases[0]
+	}
+	return strings.Join(phrases[:len(phrases)-1], ", ") + " and " + phrases[len(phrases)-1]
+}
@@ -0,0 +1,79 @@
+package rules
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/carlosrabelo/grc/core/internal/testutils"
+)
+
+func TestExplainFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected string
+	}{
+		{
+			name: "criteria and several actions",
+			filter: Filter{From: "@shop.com", Subject: "Sale", Label: "Marketing/Newsletters",
+				ShouldArchive: testutils.BoolPtr(true), ShouldNeverSpam: testutils.BoolPtr(true)},
+			expected: "Mail from @shop.com with subject 'Sale' will be labeled Marketing/Newsletters, archived and never sent to spam.",
+		},
+		{
+			name: "size, attachments and smart label",
+			filter: Filter{HasAttachment: testutils.BoolPtr(true), Size: ">5MB", NotFrom: "me@example.com",
+				SmartLabel: "^smartlabel_promo", ShouldMarkAsRead: testutils.BoolPtr(false)},
+			expected: "Mail not from me@example.com with attachments larger than 5 MB will be categorized as Promotions.",
+		},
+		{
+			name:     "only false actions",
+			filter:   Filter{Query: "is:chat", ShouldArchive: testutils.BoolPtr(false)},
+			expected: "Mail matching the search 'is:chat' will not be changed.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := ExplainFilter(tt.filter); result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestWriteExplanation(t *testing.T) {
+	config := FiltersConfig{
+		Author:   Author{Name: "Jane <Doe>", Email: "jane@example.com"},
+		Defaults: Defaults{ShouldArchive: testutils.BoolPtr(true), LabelPrefix: "@Auto"},
+		Filters:  []Filter{{From: "news@shop.com", Subject: "50% *off*", Label: "Shop"}},
+	}
+
+	tests := []struct {
+		format   string
+		contains []string
+	}{
+		{ExplainText, []string{"Gmail filters for Jane <Doe>\n\n", "1. Mail from news@shop.com with subject '50% *off*' will be labeled @Auto/Shop and archived.\n"}},
+		{ExplainMarkdown, []string{"# Gmail filters for Jane \\<Doe>\n\n", "1. Mail from **news@shop.com** with subject **50% \\*off\\*** will be labeled **@Auto/Shop** and archived.\n"}},
+		{ExplainHTML, []string{"<h1>Gmail filters for Jane &lt;Doe&gt;</h1>", "<li>Mail from <strong>news@shop.com</strong> with subject <strong>50% *off*</strong> will be labeled <strong>@Auto/Shop</strong> and archived.</li>"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteExplanation(&buf, config, tt.format); err != nil {
+				t.Fatalf("WriteExplanation failed: %v", err)
+			}
+			for _, expected := range tt.contains {
+				if !strings.Contains(buf.String(), expected) {
+					t.Errorf("Expected output to contain %q, got:\n%s", expected, buf.String())
+				}
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := WriteExplanation(&buf, config, "pdf"); err == nil || !strings.Contains(err.Error(), "unknown explain format 'pdf'") {
+		t.Errorf("Expected unknown format error, got: %v", err)
+	}
+}
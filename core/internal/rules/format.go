@@ -0,0 +1,311 @@
+package rules
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ============================================================================
+// Canonical Formatting
+// ============================================================================
+
+// criteriaFields lists the filter keys that count as a condition, in the
+// order buildFilterProperties emits them
+var criteriaFields = []string{
+	"from", "to", "subject", "hasTheWord",
+	"doesNotHaveTheWord", "notFrom", "notTo", "notSubject",
+	"list", "query", "hasAttachment", "excludeChats", "size",
+}
+
+// actionFields lists the filter keys that count as an action, in the order
+// buildFilterProperties emits them
+var actionFields = []string{
+	"shouldArchive", "shouldMarkAsRead", "shouldStar", "shouldNeverSpam",
+	"shouldAlwaysMarkAsImportant", "shouldNeverMarkAsImportant", "shouldTrash",
+	"label", "smartLabel", "forwardTo",
+}
+
+// filterKeyOrder is the canonical key order of filters and templates
+var filterKeyOrder = append(append([]string{"use", "inheritDefaults"}, criteriaFields...), actionFields...)
+
+// FormatOptions tunes FormatConfig
+type FormatOptions struct {
+	// Sort reorders the filters of every filters list
+	Sort SortOptions
+}
+
+// FormatConfig rewrites YAML configuration content into the canonical layout:
+// sections and keys in a fixed order, string values double-quoted and
+// two-space indentation. Comments are preserved.
+func FormatConfig(content []byte, options FormatOptions) ([]byte, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+
+	var document yaml.Node
+	if err := decoder.Decode(&document); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("configuration is empty")
+		}
+		return nil, fmt.Errorf("YAML syntax error: %w", err)
+	}
+	var next yaml.Node
+	if err := decoder.Decode(&next); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("configuration must contain a single YAML document")
+	}
+
+	if len(document.Content) != 1 || document.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("configuration must be a YAML mapping")
+	}
+	root := document.Content[0]
+	if options.Sort.enabled() {
+		if err := sortFilterNodes(root, options.Sort); err != nil {
+			return nil, err
+		}
+	}
+	var first *yaml.Node
+	if len(root.Content) > 0 {
+		first = root.Content[0]
+	}
+	formatNode(root, reflect.TypeOf(FiltersConfig{}))
+	keepHeadComment(&document, first)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&document); err != nil {
+		return nil, fmt.Errorf("encoding YAML: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("encoding YAML: %w", err)
+	}
+	return separateSections(buf.Bytes()), nil
+}
+
+// keepHeadComment keeps a comment written at the top of the file there. A
+// comment directly above the first key is decoded as the key's comment, and
+// would move with the key when it is no longer first. A comment separated
+// from the top one by a blank line belongs to the key and moves with it.
+func keepHeadComment(document *yaml.Node, first *yaml.Node) {
+	root := document.Content[0]
+	if first == nil || first == root.Content[0] || first.HeadComment == "" || document.HeadComment != "" {
+		return
+	}
+	document.HeadComment = first.HeadComment
+	first.HeadComment = ""
+}
+
+// MarshalConfig writes a configuration as canonical YAML, leaving out empty
+// sections
+func MarshalConfig(config FiltersConfig) ([]byte, error) {
+	var document yaml.Node
+	if err := document.Encode(config); err != nil {
+		return nil, fmt.Errorf("encoding YAML: %w", err)
+	}
+
+	content := document.Content[:0]
+	for i := 0; i+1 < len(document.Content); i += 2 {
+		if value := document.Content[i+1]; value.Kind != yaml.ScalarNode && len(value.Content) == 0 {
+			continue
+		}
+		content = append(content, document.Content[i], document.Content[i+1])
+	}
+	document.Content = content
+
+	output, err := yaml.Marshal(&document)
+	if err != nil {
+		return nil, fmt.Errorf("encoding YAML: %w", err)
+	}
+	return FormatConfig(output, FormatOptions{})
+}
+
+// separateSections puts a blank line before every top-level key after the
+// first, keeping the key's head comments attached to it
+func separateSections(content []byte) []byte {
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	out := make([][]byte, 0, len(lines)+8)
+
+	commentStart := -1 // index in out of the comment block preceding the current line
+	seenKey := false
+	for _, line := range lines {
+		switch {
+		case bytes.HasPrefix(line, []byte("#")):
+			if commentStart < 0 {
+				commentStart = len(out)
+			}
+		case len(line) > 0 && line[0] != ' ' && line[0] != '-' && line[0] != '\n':
+			if seenKey {
+				at := len(out)
+				if commentStart >= 0 {
+					at = commentStart
+				}
+				out = append(out[:at], append([][]byte{[]byte("\n")}, out[at:]...)...)
+			}
+			seenKey = true
+			commentStart = -1
+		default:
+			commentStart = -1
+		}
+		out = append(out, line)
+	}
+	return bytes.Join(out, nil)
+}
+
+// sortFilterNodes sorts the filters lists of a configuration or group
+// mapping and of its nested groups. Filters are ordered by their keys as
+// written, before templates and group label prefixes are applied.
+func sortFilterNodes(mapping *yaml.Node, options SortOptions) error {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		key, value := mapping.Content[i], mapping.Content[i+1]
+		if value.Kind != yaml.SequenceNode {
+			continue
+		}
+
+		switch key.Value {
+		case "filters":
+			if err := sortFilterSequence(value, options); err != nil {
+				return err
+			}
+		case "groups":
+			for _, group := range value.Content {
+				if err := sortFilterNodes(group, options); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// sortFilterSequence stably sorts the filter nodes of a filters list
+func sortFilterSequence(sequence *yaml.Node, options SortOptions) error {
+	type item struct {
+		node   *yaml.Node
+		filter Filter
+	}
+	items := make([]item, len(sequence.Content))
+	for i, node := range sequence.Content {
+		items[i].node = node
+		if err := node.Decode(&items[i].filter); err != nil {
+			return fmt.Errorf("line %d: decoding filter: %w", node.Line, err)
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return compareFilters(items[i].filter, items[j].filter, options) < 0
+	})
+	for i := range items {
+		sequence.Content[i] = items[i].node
+	}
+	return nil
+}
+
+// formatNode formats a node holding a value of the given Go type
+func formatNode(node *yaml.Node, t reflect.Type) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Struct:
+		sortMapping(node, keyOrder(t))
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			node.Content[i].Style = 0 // keys are plain identifiers
+			if field, ok := fieldByYAMLName(t, node.Content[i].Value); ok {
+				formatNode(node.Content[i+1], field.Type)
+			} else {
+				formatScalars(node.Content[i+1])
+			}
+		}
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Map:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			formatNode(node.Content[i+1], t.Elem())
+		}
+	case node.Kind == yaml.SequenceNode && t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Struct:
+		for _, item := range node.Content {
+			formatNode(item, t.Elem())
+		}
+	default:
+		formatScalars(node)
+	}
+}
+
+// keyOrder returns the canonical key order of a configuration type: filters
+// follow the feed property order, other types their field declarations
+func keyOrder(t reflect.Type) []string {
+	if t == reflect.TypeOf(Filter{}) {
+		return filterKeyOrder
+	}
+
+	keys := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			keys = append(keys, yamlFieldName(t.Field(i)))
+		}
+	}
+	return keys
+}
+
+// fieldByYAMLName finds the struct field decoded from a YAML key
+func fieldByYAMLName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if field := t.Field(i); field.IsExported() && yamlFieldName(field) == name {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// sortMapping reorders mapping entries by the given key order; unknown keys
+// keep their relative order after the known ones
+func sortMapping(mapping *yaml.Node, order []string) {
+	rank := make(map[string]int, len(order))
+	for i, key := range order {
+		rank[key] = i
+	}
+	position := func(key string) int {
+		if i, ok := rank[key]; ok {
+			return i
+		}
+		return len(order)
+	}
+
+	type entry struct{ key, value *yaml.Node }
+	entries := make([]entry, 0, len(mapping.Content)/2)
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		entries = append(entries, entry{mapping.Content[i], mapping.Content[i+1]})
+	}
+
+	// Insertion sort keeps the original order of equally ranked keys
+	for i := 1; i < len(entries); i++ {
+		for j := i; j > 0 && position(entries[j].key.Value) < position(entries[j-1].key.Value); j-- {
+			entries[j], entries[j-1] = entries[j-1], entries[j]
+		}
+	}
+
+	for i, e := range entries {
+		mapping.Content[2*i], mapping.Content[2*i+1] = e.key, e.value
+	}
+}
+
+// formatScalars double-quotes string values and leaves keys plain
+func formatScalars(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Tag == "!!str" && node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+			node.Style = yaml.DoubleQuotedStyle
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			formatScalars(node.Content[i+1])
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			formatScalars(item)
+		}
+	}
+}
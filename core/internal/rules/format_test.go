@@ -0,0 +1,179 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatConfig(t *testing.T) {
+	input := `# Personal filters
+
+filters:
+  # Reports from the team
+  - label: 'Reports'   # team label
+    shouldArchive: true
+    from: reports@example.com
+    use: [base]
+default:
+  shouldStar: true
+  "shouldArchive": true
+templates:
+  base:
+    label: Base
+    subject: Weekly
+author:
+  email: test@example.com
+  name: Test User
+`
+	expected := `# Personal filters
+
+author:
+  name: "Test User"
+  email: "test@example.com"
+
+default:
+  shouldArchive: true
+  shouldStar: true
+
+templates:
+  base:
+    subject: "Weekly"
+    label: "Base"
+
+filters:
+  # Reports from the team
+  - use: ["base"]
+    from: "reports@example.com"
+    shouldArchive: true
+    label: "Reports" # team label
+`
+
+	result, err := FormatConfig([]byte(input), FormatOptions{})
+	if err != nil {
+		t.Fatalf("FormatConfig failed: %v", err)
+	}
+	if string(result) != expected {
+		t.Errorf("Unexpected formatting:\n%s\nexpected:\n%s", result, expected)
+	}
+
+	again, err := FormatConfig(result, FormatOptions{})
+	if err != nil || string(again) != string(result) {
+		t.Errorf("Expected formatting to be idempotent, got:\n%s", again)
+	}
+}
+
+func TestFormatConfig_KeepsLeadingComment(t *testing.T) {
+	input := `# Personal filters
+# Generated by hand
+filters:
+  - from: "a@example.com"
+    label: "A"
+author:
+  name: "Test User"
+  email: "test@example.com"
+`
+	expected := `# Personal filters
+# Generated by hand
+
+author:
+  name: "Test User"
+  email: "test@example.com"
+
+filters:
+  - from: "a@example.com"
+    label: "A"
+`
+
+	result, err := FormatConfig([]byte(input), FormatOptions{})
+	if err != nil {
+		t.Fatalf("FormatConfig failed: %v", err)
+	}
+	if string(result) != expected {
+		t.Errorf("Unexpected formatting:\n%s\nexpected:\n%s", result, expected)
+	}
+
+	again, err := FormatConfig(result, FormatOptions{})
+	if err != nil || string(again) != string(result) {
+		t.Errorf("Expected formatting to be idempotent, got:\n%s", again)
+	}
+}
+
+func TestFormatConfig_KeepsStructuredCriteriaAndUnknownKeys(t *testing.T) {
+	input := `author:
+  name: "Test User"
+  email: "test@example.com"
+filters:
+  - label: "Shop"
+    from:
+      none: [noreply@shop.com]
+      any: [shop.com, store.com]
+    custom: value
+`
+	result, err := FormatConfig([]byte(input), FormatOptions{})
+	if err != nil {
+		t.Fatalf("FormatConfig failed: %v", err)
+	}
+
+	output := string(result)
+	if !strings.Contains(output, `none: ["noreply@shop.com"]`) || !strings.Contains(output, `any: ["shop.com", "store.com"]`) {
+		t.Errorf("Expected criteria operators to keep their order with quoted values, got:\n%s", output)
+	}
+	if strings.Index(output, "from:") > strings.Index(output, "label:") || strings.Index(output, "custom:") < strings.Index(output, "label:") {
+		t.Errorf("Expected from, label, then unknown keys, got:\n%s", output)
+	}
+}
+
+func TestFormatConfig_Errors(t *testing.T) {
+	tests := []struct {
+		name, input, expected string
+	}{
+		{"empty", "", "configuration is empty"},
+		{"syntax", "author: [", "YAML syntax error"},
+		{"not a mapping", "- a\n- b\n", "configuration must be a YAML mapping"},
+		{"several documents", "author: {}\n---\nauthor: {}\n", "single YAML document"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := FormatConfig([]byte(tt.input), FormatOptions{})
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected error containing %q, got: %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestFormatConfig_Sort(t *testing.T) {
+	input := `author:
+  name: "Test User"
+  email: "test@example.com"
+filters:
+  # Work reports
+  - from: "b@example.com"
+    label: "Work/Reports"
+  - from: "a@example.com"
+    label: "Home"
+groups:
+  - name: shop
+    filters:
+      - from: "z@shop.com"
+        label: "Shop/Z"
+      - from: "y@shop.com"
+        label: "Shop/Y"
+`
+	result, err := FormatConfig([]byte(input), FormatOptions{Sort: SortOptions{By: SortFrom}})
+	if err != nil {
+		t.Fatalf("FormatConfig failed: %v", err)
+	}
+
+	output := string(result)
+	if strings.Index(output, `"Home"`) > strings.Index(output, "# Work reports") {
+		t.Errorf("Expected Home filter first, got:\n%s", output)
+	}
+	if !strings.Contains(output, "  # Work reports\n  - from: \"b@example.com\"") {
+		t.Errorf("Expected comment to move with its filter, got:\n%s", output)
+	}
+	if strings.Index(output, "y@shop.com") > strings.Index(output, "z@shop.com") {
+		t.Errorf("Expected group filters sorted, got:\n%s", output)
+	}
+}
@@ -0,0 +1,97 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FilterGroup groups filters sharing defaults and a label prefix. Groups can
+// be nested; defaults resolve from the filter to the innermost group, then
+// outwards to the global defaults.
+type FilterGroup struct {
+	Name        string        `yaml:"name"`
+	Defaults    Defaults      `yaml:"default,omitempty" toml:"default"`
+	LabelPrefix string        `yaml:"labelPrefix,omitempty"`
+	Filters     []Filter      `yaml:"filters,omitempty"`
+	Groups      []FilterGroup `yaml:"groups,omitempty"`
+}
+
+// filterOrigin records where a filter was declared in the configuration
+type filterOrigin struct {
+	group       string     // slash-separated group path, empty for top-level filters
+	index       int        // position of the filter within its group
+	position    int        // index among the configured filters, set when they are normalized
+	defaults    []Defaults // group defaults, innermost first
+	labelPrefix string     // joined label prefixes of the enclosing groups
+	node        *yaml.Node // YAML mapping of the filter, nil when not decoded
+}
+
+// filterRef describes a filter in error messages
+func filterRef(index int, filter Filter) string {
+	if filter.origin.group == "" {
+		return fmt.Sprintf("filter %d", index)
+	}
+	return fmt.Sprintf("group '%s' filter %d", filter.origin.group, filter.origin.index)
+}
+
+// flattenGroups appends the filters of every group to the top-level filters,
+// recording their group path, defaults and label prefix
+func flattenGroups(config FiltersConfig) FiltersConfig {
+	if len(config.Groups) == 0 {
+		return config
+	}
+
+	filters := make([]Filter, 0, len(config.Filters))
+	filters = append(filters, config.Filters...)
+	for i, group := range config.Groups {
+		filters = appendGroupFilters(filters, group, groupName(group, i), "", nil)
+	}
+
+	config.Filters = filters
+	config.Groups = nil
+	return config
+}
+
+// appendGroupFilters flattens a group and its nested groups
+func appendGroupFilters(filters []Filter, group FilterGroup, path, labelPrefix string, defaults []Defaults) []Filter {
+	labelPrefix = joinLabel(labelPrefix, group.LabelPrefix)
+
+	// Innermost defaults come first so they take precedence
+	chain := make([]Defaults, 0, len(defaults)+1)
+	chain = append(chain, group.Defaults)
+	chain = append(chain, defaults...)
+
+	for i, filter := range group.Filters {
+		filter.origin = filterOrigin{group: path, index: i, defaults: chain, labelPrefix: labelPrefix, node: filter.origin.node}
+		filters = append(filters, filter)
+	}
+
+	for i, nested := range group.Groups {
+		filters = appendGroupFilters(filters, nested, path+"/"+groupName(nested, i), labelPrefix, chain)
+	}
+	return filters
+}
+
+// groupName returns the group name, falling back to its position
+func groupName(group FilterGroup, index int) string {
+	if name := strings.TrimSpace(group.Name); name != "" {
+		return name
+	}
+	return fmt.Sprintf("#%d", index)
+}
+
+// joinLabel joins label path segments with "/"
+func joinLabel(prefix, label string) string {
+	prefix = strings.TrimSuffix(prefix, "/")
+	label = strings.TrimPrefix(label, "/")
+	switch {
+	case prefix == "":
+		return label
+	case label == "":
+		return prefix
+	default:
+		return prefix + "/" + label
+	}
+}
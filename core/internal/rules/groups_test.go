@@ -0,0 +1,122 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/carlosrabelo/grc/core/internal/testutils"
+)
+
+func TestLoadConfig_Groups(t *testing.T) {
+	content := `author:
+  name: "Test User"
+  email: "test@example.com"
+default:
+  shouldNeverSpam: true
+filters:
+  - from: "boss@corp.com"
+    label: "Work"
+groups:
+  - name: marketing
+    labelPrefix: "@Marketing"
+    default:
+      shouldArchive: true
+    filters:
+      - from: "news@shop.com"
+        label: "Newsletters"
+    groups:
+      - name: deals
+        labelPrefix: "Deals"
+        default:
+          shouldStar: true
+        filters:
+          - from: "deals@shop.com"
+            label: "Shop"
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+
+	config, err := LoadConfig(tmpFile)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	feed := GenerateFeed(config, time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC))
+	if len(feed.Entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(feed.Entries))
+	}
+
+	top := feed.Entries[0].Properties
+	if !hasProperty(top, "label", "Work") || hasProperty(top, "shouldArchive", "true") {
+		t.Errorf("Expected top-level filter to ignore group defaults, got %+v", top)
+	}
+
+	marketing := feed.Entries[1].Properties
+	for name, value := range map[string]string{"label": "@Marketing/Newsletters", "shouldArchive": "true", "shouldNeverSpam": "true"} {
+		if !hasProperty(marketing, name, value) {
+			t.Errorf("Expected %s=%s on group filter, got %+v", name, value, marketing)
+		}
+	}
+
+	deals := feed.Entries[2].Properties
+	for name, value := range map[string]string{"label": "@Marketing/Deals/Shop", "shouldArchive": "true", "shouldStar": "true", "shouldNeverSpam": "true"} {
+		if !hasProperty(deals, name, value) {
+			t.Errorf("Expected %s=%s on nested group filter, got %+v", name, value, deals)
+		}
+	}
+}
+
+func TestLoadConfig_GroupErrorPath(t *testing.T) {
+	content := `author:
+  name: "Test User"
+  email: "test@example.com"
+groups:
+  - name: finance
+    groups:
+      - name: invoices
+        filters:
+          - subject: "Invoice"
+            label: "Invoices"
+          - from: "not-an-address"
+            label: "Invoices"
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+
+	_, err := LoadConfig(tmpFile)
+	if err == nil || !strings.Contains(err.Error(), "group 'finance/invoices' filter 1: 'from' field") {
+		t.Errorf("Expected error with group path, got: %v", err)
+	}
+}
+
+func TestApplyDefaults_LayerPrecedence(t *testing.T) {
+	inner := Defaults{ShouldStar: testutils.BoolPtr(true)}
+	outer := Defaults{ShouldArchive: testutils.BoolPtr(true), ShouldStar: testutils.BoolPtr(true)}
+
+	filter := applyDefaults(Filter{ShouldStar: testutils.BoolPtr(false)}, inner, outer)
+
+	if filter.ShouldStar == nil || *filter.ShouldStar {
+		t.Errorf("Expected explicit filter value to win over all layers")
+	}
+	if filter.ShouldArchive == nil || !*filter.ShouldArchive {
+		t.Errorf("Expected outer layer to fill remaining defaults")
+	}
+}
+
+func TestJoinLabel(t *testing.T) {
+	tests := []struct {
+		prefix, label, expected string
+	}{
+		{"", "Label", "Label"},
+		{"@Work", "", "@Work"},
+		{"@Work", "Reports", "@Work/Reports"},
+		{"@Work/", "/Reports", "@Work/Reports"},
+	}
+
+	for _, tt := range tests {
+		if result := joinLabel(tt.prefix, tt.label); result != tt.expected {
+			t.Errorf("joinLabel(%q, %q) = %q, expected %q", tt.prefix, tt.label, result, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package rules
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ============================================================================
+// Configuration Input Formats
+// ============================================================================
+
+// Configuration formats read by LoadConfig and DecodeConfigFormat
+const (
+	ConfigYAML = "yaml"
+	ConfigJSON = "json"
+	ConfigTOML = "toml"
+)
+
+// configExtensions maps file extensions to configuration formats
+var configExtensions = map[string]string{
+	".yaml": ConfigYAML,
+	".yml":  ConfigYAML,
+	".json": ConfigJSON,
+	".toml": ConfigTOML,
+}
+
+// tomlStartRegex matches a first TOML line: a table header or key = value
+var tomlStartRegex = regexp.MustCompile(`^(?:\[|[A-Za-z0-9_"'.-][A-Za-z0-9_"'. -]*=)`)
+
+// DetectConfigFormat guesses the format of configuration content: JSON when
+// it starts with '{', TOML when its first line is a [table] header or a
+// key = value pair, and YAML otherwise
+func DetectConfigFormat(content []byte) string {
+	trimmed := bytes.TrimLeft(bytes.TrimPrefix(content, []byte("\ufeff")), " \t\r\n")
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		return ConfigJSON
+	}
+
+	for _, line := range strings.Split(string(trimmed), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if tomlStartRegex.MatchString(line) {
+			return ConfigTOML
+		}
+		break
+	}
+	return ConfigYAML
+}
+
+// DecodeConfigFormat decodes configuration content of the given format
+// without validating it. Every format rejects unknown keys and reports
+// problems with their line in the source.
+func DecodeConfigFormat(content []byte, format string) (FiltersConfig, error) {
+	var config FiltersConfig
+	document, err := decodeFormat(content, format, &config)
+	if err != nil {
+		return FiltersConfig{}, err
+	}
+	if document != nil {
+		attachSource(&config, document)
+	}
+	return config, nil
+}
+
+// decodeFormat strictly decodes content of a configuration format into
+// target, returning the node tree of the content for source positions
+func decodeFormat(content []byte, format string, target any) (*yaml.Node, error) {
+	switch format {
+	case ConfigYAML:
+		return decodeYAML(content, "YAML", target)
+	case ConfigJSON:
+		return decodeJSON(content, target)
+	case ConfigTOML:
+		return decodeTOML(content, target)
+	default:
+		return nil, fmt.Errorf("unknown configuration format '%s' (use %s, %s or %s)", format, ConfigYAML, ConfigJSON, ConfigTOML)
+	}
+}
+
+// ParseConfigFormat parses and validates configuration content of the given format
+func ParseConfigFormat(content []byte, format string) (FiltersConfig, error) {
+	config, err := DecodeConfigFormat(content, format)
+	if err != nil {
+		return FiltersConfig{}, err
+	}
+	return PrepareConfig(config)
+}
+
+// configFormatForPath returns the format of a configuration file from its
+// extension, or "" for files without one, whose content is sniffed
+func configFormatForPath(filePath string) (string, error) {
+	extension := strings.ToLower(filepath.Ext(filePath))
+	if extension == "" {
+		return "", nil
+	}
+	if format, ok := configExtensions[extension]; ok {
+		return format, nil
+	}
+	return "", fmt.Errorf("input file %s must have .yaml, .yml, .json or .toml extension", filePath)
+}
+
+// parseYAMLContent decodes the YAML content to FiltersConfig structure
+func parseYAMLContent(fileContent []byte) (FiltersConfig, error) {
+	return DecodeConfigFormat(fileContent, ConfigYAML)
+}
+
+// decodeJSON decodes JSON content. JSON is checked with encoding/json first,
+// so YAML-only syntax is rejected, and then decoded as the YAML it is a
+// subset of, sharing the strict decoding and error positions.
+func decodeJSON(content []byte, target any) (*yaml.Node, error) {
+	var value any
+	if err := json.Unmarshal(content, &value); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line, column := offsetPosition(content, syntaxErr.Offset)
+			err = fmt.Errorf("line %d, column %d: %s", line, column, syntaxErr.Error())
+		}
+		return nil, &ParseError{Err: fmt.Errorf("JSON syntax error: %w", err)}
+	}
+
+	// Tabs may only appear between tokens in valid JSON, where YAML flow
+	// content treats them as spaces; replacing them keeps every offset
+	content, shifts := normalizeJSONEscapes(bytes.ReplaceAll(content, []byte("\t"), []byte(" ")))
+	document, err := decodeYAML(content, "JSON", target)
+	if document != nil {
+		shifts.restore(document)
+	}
+	return document, err
+}
+
+// jsonShift records escapes shortened by normalizeJSONEscapes on a line:
+// after normalized column, positions moved delta runes to the left
+type jsonShift struct {
+	column int
+	delta  int
+}
+
+// jsonShifts maps lines to the escapes shortened on them
+type jsonShifts map[int][]jsonShift
+
+// normalizeJSONEscapes rewrites the string escapes of valid JSON that YAML
+// double-quoted scalars reject: "\/" becomes "/", surrogate pairs become
+// "\U" escapes and lone surrogates become U+FFFD, as encoding/json decodes
+// them. Lines are kept; the columns the rewrite shortens are returned.
+func normalizeJSONEscapes(content []byte) ([]byte, jsonShifts) {
+	if !bytes.Contains(content, []byte(`\`)) {
+		return content, nil
+	}
+
+	shifts := jsonShifts{}
+	output := make([]byte, 0, len(content))
+	line, column := 1, 1
+	inString := false
+	for i := 0; i < len(content); {
+		c := content[i]
+		if inString && c == '\\' && i+1 < len(content) {
+			escape, length := jsonEscape(content[i:])
+			if delta := utf8.RuneCount(content[i:i+length]) - utf8.RuneCountInString(escape); delta > 0 {
+				shifts[line] = append(shifts[line], jsonShift{column: column, delta: delta})
+			}
+			output = append(output, escape...)
+			column += utf8.RuneCountInString(escape)
+			i += length
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = !inString
+		case '\n':
+			line, column = line+1, 0
+		}
+		_, size := utf8.DecodeRune(content[i:])
+		output = append(output, content[i:i+size]...)
+		column++
+		i += size
+	}
+	return output, shifts
+}
+
+// jsonEscape returns the YAML form of the escape at the start of text and
+// the length of the JSON escape
+func jsonEscape(text []byte) (string, int) {
+	switch text[1] {
+	case '/':
+		return "/", 2
+	case 'u':
+		r := jsonEscapeRune(text)
+		if !utf16.IsSurrogate(r) {
+			return string(text[:6]), 6
+		}
+		if low := jsonEscapeRune(text[6:]); r < 0xdc00 && low >= 0xdc00 && low <= 0xdfff {
+			return fmt.Sprintf(`\U%08X`, utf16.DecodeRune(r, low)), 12
+		}
+		return `\uFFFD`, 6
+	}
+	return string(text[:2]), 2
+}
+
+// jsonEscapeRune decodes a \uXXXX escape, or returns -1
+func jsonEscapeRune(text []byte) rune {
+	if len(text) < 6 || text[0] != '\\' || text[1] != 'u' {
+		return -1
+	}
+	value, err := strconv.ParseUint(string(text[2:6]), 16, 16)
+	if err != nil {
+		return -1
+	}
+	return rune(value)
+}
+
+// restore moves the columns of a node tree back to the JSON source
+func (s jsonShifts) restore(node *yaml.Node) {
+	if len(s) == 0 {
+		return
+	}
+	column := node.Column
+	for _, shift := range s[node.Line] {
+		if shift.column < column {
+			node.Column += shift.delta
+		}
+	}
+	for _, child := range node.Content {
+		s.restore(child)
+	}
+}
+
+// decodeYAML strictly decodes YAML content into target, naming the source
+// format in errors
+func decodeYAML(content []byte, format string, target any) (*yaml.Node, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(target); err != nil {
+		// Try to extract line/column info from yaml.v3 error
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			return nil, &ParseError{Err: fmt.Errorf("%s validation error: %s", format, strings.Join(typeErr.Errors, "; "))}
+		}
+		return nil, &ParseError{Err: fmt.Errorf("%s syntax error: %w", format, err)}
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, nil
+	}
+	return &document, nil
+}
+
+// offsetPosition converts a byte offset into a line and column
+func offsetPosition(content []byte, offset int64) (int, int) {
+	before := content[:min(int(offset), len(content))]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
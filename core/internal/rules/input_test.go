@@ -0,0 +1,182 @@
+package rules
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/carlosrabelo/grc/core/internal/testutils"
+)
+
+const inputYAML = `author:
+  name: "Test User"
+  email: "test@example.com"
+default:
+  shouldNeverSpam: true
+filters:
+  - from: ["a@example.com", "b@example.com"]
+    label: "Work"
+  - subject: "Invoice"
+    shouldArchive: true
+`
+
+const inputJSON = `{
+	"author": {"name": "Test User", "email": "test@example.com"},
+	"default": {"shouldNeverSpam": true},
+	"filters": [
+		{"from": ["a@example.com", "b@example.com"], "label": "Work"},
+		{"subject": "Invoice", "shouldArchive": true}
+	]
+}
+`
+
+const inputTOML = `[author]
+name = "Test User"
+email = "test@example.com"
+
+[default]
+shouldNeverSpam = true
+
+[[filters]]
+from = ["a@example.com", "b@example.com"]
+label = "Work"
+
+[[filters]]
+subject = "Invoice"
+shouldArchive = true
+`
+
+func TestLoadConfig_Formats(t *testing.T) {
+	want, err := ParseConfig([]byte(inputYAML))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{"json", "config.json", inputJSON},
+		{"toml", "config.toml", inputTOML},
+		{"sniffed json", "config", inputJSON},
+		{"sniffed toml", "config", inputTOML},
+		{"sniffed yaml", "config", inputYAML},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpFile := testutils.CreateTempFile(t, tt.file, tt.content)
+			defer testutils.CleanupFile(tmpFile)
+
+			got, err := LoadConfig(tmpFile)
+			if err != nil {
+				t.Fatalf("LoadConfig failed: %v", err)
+			}
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			if gotFeed, wantFeed := GenerateFeed(got, now), GenerateFeed(want, now); !reflect.DeepEqual(gotFeed, wantFeed) {
+				t.Errorf("Expected feed %+v, got %+v", wantFeed, gotFeed)
+			}
+		})
+	}
+}
+
+func TestDetectConfigFormat(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"\ufeff  {\"author\": {}}", ConfigJSON},
+		{"# comment\n[author]\nname = \"x\"\n", ConfigTOML},
+		{"title = \"x\"\n", ConfigTOML},
+		{"\"quoted key\" = 1\n", ConfigTOML},
+		{"author:\n  name: x\n", ConfigYAML},
+		{"- a\n", ConfigYAML},
+		{"query: a = b\n", ConfigYAML},
+		{"", ConfigYAML},
+	}
+
+	for _, tt := range tests {
+		if got := DetectConfigFormat([]byte(tt.content)); got != tt.want {
+			t.Errorf("DetectConfigFormat(%q) = %s, want %s", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeConfigFormat_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		content string
+		message string
+	}{
+		{"json unknown field", ConfigJSON, "{\n  \"author\": {\"name\": \"A\"},\n  \"filters\": [{\"labl\": \"x\"}]\n}", "JSON validation error: line 3: field labl not found in type rules.Filter"},
+		{"json syntax", ConfigJSON, "{\n  \"author\": {\"name\": \"A\",,}\n}", "JSON syntax error: line 2, column 27"},
+		{"json trailing data", ConfigJSON, "{} {}", "JSON syntax error"},
+		{"toml unknown field", ConfigTOML, "[author]\nname = \"A\"\n\n[[filters]]\nlabl = \"x\"\n", "TOML validation error: line 5: field labl not found in type rules.Filter"},
+		{"toml unknown section", ConfigTOML, "[autor]\nname = \"A\"\n", "line 1: field autor not found in type rules.FiltersConfig"},
+		{"toml key case", ConfigTOML, "[[filters]]\nLabel = \"x\"\n", "TOML validation error: line 2: field Label not found in type rules.Filter"},
+		{"toml wrong type", ConfigTOML, "[[filters]]\nlabel = [1]\n", "TOML validation error: line 2: incompatible types"},
+		{"toml criteria", ConfigTOML, "[[filters]]\nfrom = []\n", "TOML validation error: line 2, column 9: criteria list must not be empty"},
+		{"toml syntax", ConfigTOML, "[author\n", "TOML syntax error: line 1"},
+		{"unknown format", "ini", "", "unknown configuration format 'ini'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeConfigFormat([]byte(tt.content), tt.format)
+			if err == nil || !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("Expected error containing %q, got: %v", tt.message, err)
+			}
+			var parseErr *ParseError
+			if tt.format != "ini" && !errors.As(err, &parseErr) {
+				t.Errorf("Expected a *ParseError, got %T", err)
+			}
+		})
+	}
+}
+
+func TestDecodeConfigFormat_JSONEscapes(t *testing.T) {
+	content := "{\n  \"author\": {\"name\": \"A\", \"email\": \"a@example.com\"},\n" +
+		"  \"filters\": [\n" +
+		"    {\"label\": \"News\\/Daily \\ud83d\\ude00 \\ud800\", \"from\": \"x@example.com\", \"forwardTo\": \"nope\"}\n" +
+		"  ]\n}\n"
+
+	config, err := DecodeConfigFormat([]byte(content), ConfigJSON)
+	if err != nil {
+		t.Fatalf("DecodeConfigFormat failed: %v", err)
+	}
+	if want := "News/Daily \U0001F600 \uFFFD"; config.Filters[0].Label != want {
+		t.Errorf("Expected label %q, got %q", want, config.Filters[0].Label)
+	}
+
+	_, err = ParseConfigFormat([]byte(content), ConfigJSON)
+	got := ValidationErrors(err)
+	column := strings.Index(strings.Split(content, "\n")[3], `"forwardTo"`) + 1
+	if len(got) != 1 || got[0].Line != 4 || got[0].Column != column {
+		t.Errorf("Expected a forwardTo error at line 4, column %d, got %+v", column, got)
+	}
+}
+
+func TestParseConfigFormat_ValidationPositions(t *testing.T) {
+	tests := []struct {
+		format  string
+		content string
+		line    int
+	}{
+		{ConfigJSON, "{\n  \"author\": {\"name\": \"A\", \"email\": \"a@example.com\"},\n  \"filters\": [\n    {\"from\": \"x@example.com\",\n     \"forwardTo\": \"nope\"}\n  ]\n}\n", 5},
+		{ConfigTOML, "[author]\nname = \"A\"\nemail = \"a@example.com\"\n\n[[filters]]\nfrom = \"x@example.com\"\nforwardTo = \"nope\"\n", 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			_, err := ParseConfigFormat([]byte(tt.content), tt.format)
+			got := ValidationErrors(err)
+			if len(got) != 1 || got[0].Field != "forwardTo" || got[0].Line != tt.line {
+				t.Errorf("Expected a forwardTo error at line %d, got %+v (%v)", tt.line, got, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package rules
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Gmail account-level limits used when the configuration does not override them
+const (
+	// DefaultMaxCriteriaLength is the longest criteria string Gmail reliably accepts
+	DefaultMaxCriteriaLength = 1500
+	// DefaultMaxFilters is the number of filters Gmail allows per account
+	DefaultMaxFilters = 1000
+	// DefaultMaxForwardingAddresses is the number of forwarding addresses Gmail allows
+	DefaultMaxForwardingAddresses = 20
+	// DefaultMaxXMLBytes is the largest filters file grc generates without warning
+	DefaultMaxXMLBytes = 1 << 20
+)
+
+// Limit enforcement modes
+const (
+	LimitsWarn = "warn"
+	LimitsFail = "fail"
+)
+
+// maxOffenders is how many offenders are listed per violated limit
+const maxOffenders = 5
+
+// Limits configures the Gmail account limits checked while generating the feed.
+// Zero values use the Gmail defaults and negative values disable a check.
+type Limits struct {
+	// MaxCriteriaLength is the longest allowed criteria string; longer OR-lists
+	// are split into several entries
+	MaxCriteriaLength int `yaml:"maxCriteriaLength,omitempty"`
+	// MaxFilters is the maximum number of generated entries
+	MaxFilters int `yaml:"maxFilters,omitempty"`
+	// MaxXMLBytes is the maximum size of the generated XML document
+	MaxXMLBytes int `yaml:"maxXMLBytes,omitempty"`
+	// MaxForwardingAddresses is the maximum number of distinct forwardTo targets
+	MaxForwardingAddresses int `yaml:"maxForwardingAddresses,omitempty"`
+	// OnExceed selects whether violations warn (default) or fail the run
+	OnExceed string `yaml:"onExceed,omitempty"`
+}
+
+// LimitViolation describes a limit exceeded by the generated feed
+type LimitViolation struct {
+	Limit     string   // name of the limit in the limits section
+	Actual    int      // measured value (the worst one for per-entry limits)
+	Max       int      // configured maximum
+	Offenders []string // worst offenders, largest first
+}
+
+// String describes the violation and its worst offenders
+func (v LimitViolation) String() string {
+	message := fmt.Sprintf("%s exceeded: %d > %d", v.Limit, v.Actual, v.Max)
+	if len(v.Offenders) > 0 {
+		message += " (" + strings.Join(v.Offenders, "; ") + ")"
+	}
+	return message
+}
+
+// LimitError reports the limits exceeded when onExceed is "fail"
+type LimitError struct {
+	Violations []LimitViolation
+}
+
+// Error lists every violated limit
+func (e *LimitError) Error() string {
+	lines := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		lines = append(lines, violation.String())
+	}
+	return "Gmail limits exceeded: " + strings.Join(lines, "; ")
+}
+
+// Fail reports whether violations must fail the run
+func (l Limits) Fail() bool {
+	return l.OnExceed == LimitsFail
+}
+
+// criteriaLength returns the effective criteria length limit
+func (l Limits) criteriaLength() int {
+	return limitOrDefault(l.MaxCriteriaLength, DefaultMaxCriteriaLength)
+}
+
+// limitOrDefault returns the default for zero values
+func limitOrDefault(value, fallback int) int {
+	if value == 0 {
+		return fallback
+	}
+	return value
+}
+
+// validateLimits validates the limits section
+func validateLimits(config FiltersConfig) []error {
+	switch onExceed := config.Limits.OnExceed; onExceed {
+	case "", LimitsWarn, LimitsFail:
+		return nil
+	default:
+		return []error{sectionError(config, "limits", "onExceed", onExceed, RuleEnum,
+			fmt.Sprintf("limits: onExceed must be '%s' or '%s', got '%s'", LimitsWarn, LimitsFail, onExceed))}
+	}
+}
+
+// CheckLimits checks the generated feed against the configured Gmail limits
+func CheckLimits(feed Feed, limits Limits) ([]LimitViolation, error) {
+	var violations []LimitViolation
+
+	if limit := limitOrDefault(limits.MaxFilters, DefaultMaxFilters); limit > 0 && len(feed.Entries) > limit {
+		violations = append(violations, LimitViolation{
+			Limit:  "maxFilters",
+			Actual: len(feed.Entries),
+			Max:    limit,
+		})
+	}
+
+	if limit := limitOrDefault(limits.MaxXMLBytes, DefaultMaxXMLBytes); limit > 0 {
+		output, err := MarshalFeed(feed)
+		if err != nil {
+			return nil, err
+		}
+		if len(output) > limit {
+			violations = append(violations, LimitViolation{
+				Limit:     "maxXMLBytes",
+				Actual:    len(output),
+				Max:       limit,
+				Offenders: largestEntries(feed),
+			})
+		}
+	}
+
+	if violation, ok := checkForwardingAddresses(feed, limitOrDefault(limits.MaxForwardingAddresses, DefaultMaxForwardingAddresses)); ok {
+		violations = append(violations, violation)
+	}
+
+	if violation, ok := checkCriteriaLength(feed, limits.criteriaLength()); ok {
+		violations = append(violations, violation)
+	}
+
+	return violations, nil
+}
+
+// checkForwardingAddresses counts distinct forwardTo targets
+func checkForwardingAddresses(feed Feed, limit int) (LimitViolation, bool) {
+	if limit <= 0 {
+		return LimitViolation{}, false
+	}
+
+	counts := make(map[string]int)
+	for _, entry := range feed.Entries {
+		for _, prop := range entry.Properties {
+			if prop.Name == "forwardTo" {
+				counts[strings.ToLower(prop.Value)]++
+			}
+		}
+	}
+	if len(counts) <= limit {
+		return LimitViolation{}, false
+	}
+
+	addresses := make([]string, 0, len(counts))
+	for address := range counts {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		if counts[addresses[i]] != counts[addresses[j]] {
+			return counts[addresses[i]] > counts[addresses[j]]
+		}
+		return addresses[i] < addresses[j]
+	})
+
+	offenders := make([]string, 0, maxOffenders)
+	for _, address := range addresses[:min(len(addresses), maxOffenders)] {
+		offenders = append(offenders, fmt.Sprintf("%s used by %d entries", address, counts[address]))
+	}
+
+	return LimitViolation{Limit: "maxForwardingAddresses", Actual: len(counts), Max: limit, Offenders: offenders}, true
+}
+
+// checkCriteriaLength finds criteria longer than the limit that could not be split
+func checkCriteriaLength(feed Feed, limit int) (LimitViolation, bool) {
+	if limit <= 0 {
+		return LimitViolation{}, false
+	}
+
+	type offender struct {
+		entry  int
+		name   string
+		length int
+	}
+
+	var offenders []offender
+	for i, entry := range feed.Entries {
+		for _, prop := range entry.Properties {
+			if criteriaProperties[prop.Name] && len(prop.Value) > limit {
+				offenders = append(offenders, offender{entry: i, name: prop.Name, length: len(prop.Value)})
+			}
+		}
+	}
+	if len(offenders) == 0 {
+		return LimitViolation{}, false
+	}
+
+	sort.SliceStable(offenders, func(i, j int) bool { return offenders[i].length > offenders[j].length })
+
+	descriptions := make([]string, 0, maxOffenders)
+	for _, o := range offenders[:min(len(offenders), maxOffenders)] {
+		descriptions = append(descriptions, fmt.Sprintf("entry %d '%s' has %d characters", o.entry, o.name, o.length))
+	}
+
+	return LimitViolation{Limit: "maxCriteriaLength", Actual: offenders[0].length, Max: limit, Offenders: descriptions}, true
+}
+
+// largestEntries lists the entries contributing most to the XML size
+func largestEntries(feed Feed) []string {
+	sizes := make([]int, len(feed.Entries))
+	order := make([]int, len(feed.Entries))
+	for i, entry := range feed.Entries {
+		order[i] = i
+		for _, prop := range entry.Properties {
+			sizes[i] += len(prop.Name) + len(prop.Value)
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool { return sizes[order[i]] > sizes[order[j]] })
+
+	offenders := make([]string, 0, maxOffenders)
+	for _, index := range order[:min(len(order), maxOffenders)] {
+		offenders = append(offenders, fmt.Sprintf("entry %d has %d bytes of properties", index, sizes[index]))
+	}
+	return offenders
+}
+
+// criteriaProperties lists the Gmail properties holding criteria strings
+var criteriaProperties = map[string]bool{
+	"from":               true,
+	"to":                 true,
+	"subject":            true,
+	"hasTheWord":         true,
+	"doesNotHaveTheWord": true,
+	"list":               true,
+	"query":              true,
+}
@@ -0,0 +1,101 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/carlosrabelo/grc/core/internal/testutils"
+)
+
+func TestCheckLimits_WithinDefaults(t *testing.T) {
+	feed := testFeed(Filter{From: "a@example.com", Label: "Test"})
+
+	violations, err := CheckLimits(feed, Limits{})
+	if err != nil {
+		t.Fatalf("CheckLimits failed: %v", err)
+	}
+	if len(violations) != 0 {
+		t.Errorf("Expected no violations, got %+v", violations)
+	}
+}
+
+func TestCheckLimits_MaxFilters(t *testing.T) {
+	feed := testFeed(
+		Filter{From: "a@example.com", Label: "A"},
+		Filter{From: "b@example.com", Label: "B"},
+		Filter{From: "c@example.com", Label: "C"},
+	)
+
+	violations, err := CheckLimits(feed, Limits{MaxFilters: 2})
+	if err != nil {
+		t.Fatalf("CheckLimits failed: %v", err)
+	}
+	if len(violations) != 1 || violations[0].Limit != "maxFilters" || violations[0].Actual != 3 {
+		t.Errorf("Expected maxFilters violation, got %+v", violations)
+	}
+}
+
+func TestCheckLimits_ForwardingAddresses(t *testing.T) {
+	filters := make([]Filter, 0, 4)
+	for i := 0; i < 3; i++ {
+		filters = append(filters, Filter{From: Criterion(fmt.Sprintf("s%d@example.com", i)), ForwardTo: fmt.Sprintf("f%d@example.com", i)})
+	}
+	filters = append(filters, Filter{From: "extra@example.com", ForwardTo: "f0@example.com"})
+
+	violations, err := CheckLimits(testFeed(filters...), Limits{MaxForwardingAddresses: 2})
+	if err != nil {
+		t.Fatalf("CheckLimits failed: %v", err)
+	}
+	if len(violations) != 1 || violations[0].Limit != "maxForwardingAddresses" {
+		t.Fatalf("Expected maxForwardingAddresses violation, got %+v", violations)
+	}
+	if !strings.HasPrefix(violations[0].Offenders[0], "f0@example.com used by 2 entries") {
+		t.Errorf("Expected most used address first, got %v", violations[0].Offenders)
+	}
+}
+
+func TestCheckLimits_CriteriaLengthAndXMLSize(t *testing.T) {
+	feed := testFeed(
+		Filter{Query: strings.Repeat("x", 200), Label: "Long"},
+		Filter{Subject: "short", Label: "Short", ShouldStar: testutils.BoolPtr(true)},
+	)
+
+	violations, err := CheckLimits(feed, Limits{MaxCriteriaLength: 100, MaxXMLBytes: 100})
+	if err != nil {
+		t.Fatalf("CheckLimits failed: %v", err)
+	}
+
+	names := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		names = append(names, violation.Limit)
+	}
+	if strings.Join(names, ",") != "maxXMLBytes,maxCriteriaLength" {
+		t.Fatalf("Expected XML size and criteria length violations, got %v", names)
+	}
+	if !strings.Contains(violations[1].String(), "entry 0 'query' has 200 characters") {
+		t.Errorf("Expected offending entry in message, got %s", violations[1].String())
+	}
+	if !strings.HasPrefix(violations[0].Offenders[0], "entry 0") {
+		t.Errorf("Expected largest entry first, got %v", violations[0].Offenders)
+	}
+}
+
+func TestLoadConfig_InvalidOnExceed(t *testing.T) {
+	content := `author:
+  name: "Test User"
+  email: "test@example.com"
+limits:
+  onExceed: "explode"
+filters:
+  - from: "a@example.com"
+    label: "Test"
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+
+	_, err := LoadConfig(tmpFile)
+	if err == nil || !strings.Contains(err.Error(), "onExceed must be") {
+		t.Errorf("Expected onExceed validation error, got: %v", err)
+	}
+}
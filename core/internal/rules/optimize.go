@@ -0,0 +1,100 @@
+package rules
+
+import "strings"
+
+// optimizeFilters merges filters that share every action and every criterion
+// but one, OR-ing the differing criterion. Merged criteria are packed into
+// chunks no longer than maxLength (no limit when maxLength <= 0). The merged
+// filter takes the position of the first filter in its group.
+func optimizeFilters(filters []Filter, maxLength int) []Filter {
+	for _, criterion := range splittableCriteria {
+		filters = mergeOnCriterion(filters, criterion.field, maxLength)
+	}
+	return filters
+}
+
+// mergeOnCriterion merges the filters that differ only in the given criterion
+func mergeOnCriterion(filters []Filter, field func(*Filter) *Criterion, maxLength int) []Filter {
+	groups := make(map[string][]int)
+	order := make([]string, 0, len(filters))
+	for i := range filters {
+		signature, ok := mergeSignature(filters[i], field)
+		if !ok {
+			continue
+		}
+		if _, exists := groups[signature]; !exists {
+			order = append(order, signature)
+		}
+		groups[signature] = append(groups[signature], i)
+	}
+
+	merged := make(map[int][]Filter)
+	skipped := make(map[int]bool)
+	for _, signature := range order {
+		members := groups[signature]
+		if len(members) < 2 {
+			continue
+		}
+
+		alternatives := make([]string, 0, len(members))
+		seen := make(map[string]bool)
+		for _, index := range members {
+			parts, _ := splitTopLevelOr(string(*field(&filters[index])))
+			for _, part := range parts {
+				if !seen[part] {
+					seen[part] = true
+					alternatives = append(alternatives, part)
+				}
+			}
+			skipped[index] = true
+		}
+
+		chunks := []string{strings.Join(alternatives, " OR ")}
+		if maxLength > 0 {
+			chunks = chunkAlternatives(alternatives, maxLength)
+		}
+
+		first := members[0]
+		for _, chunk := range chunks {
+			filter := filters[first]
+			*field(&filter) = Criterion(chunk)
+			merged[first] = append(merged[first], filter)
+		}
+	}
+
+	result := make([]Filter, 0, len(filters))
+	for i, filter := range filters {
+		if replacement, ok := merged[i]; ok {
+			result = append(result, replacement...)
+			continue
+		}
+		if !skipped[i] {
+			result = append(result, filter)
+		}
+	}
+	return result
+}
+
+// mergeSignature identifies filters that are equal except for the given criterion.
+// It reports false when the criterion is empty or is not a plain OR-list.
+func mergeSignature(filter Filter, field func(*Filter) *Criterion) (string, bool) {
+	value := string(*field(&filter))
+	if value == "" {
+		return "", false
+	}
+	if _, ok := splitTopLevelOr(value); !ok {
+		return "", false
+	}
+
+	*field(&filter) = ""
+	props := buildFilterProperties(filter)
+
+	var signature strings.Builder
+	for _, prop := range props {
+		signature.WriteString(prop.Name)
+		signature.WriteByte('=')
+		signature.WriteString(prop.Value)
+		signature.WriteByte(0)
+	}
+	return signature.String(), true
+}
@@ -0,0 +1,86 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/carlosrabelo/grc/core/internal/testutils"
+)
+
+func TestOptimizeFilters_MergesSameActions(t *testing.T) {
+	filters := []Filter{
+		{From: "a@news.com", Label: "Newsletters", ShouldArchive: testutils.BoolPtr(true)},
+		{From: "b@news.com", Label: "Newsletters", ShouldArchive: testutils.BoolPtr(true)},
+		{From: "boss@corp.com", Label: "Work"},
+		{From: "c@news.com OR d@news.com", Label: "Newsletters", ShouldArchive: testutils.BoolPtr(true)},
+	}
+
+	result := optimizeFilters(filters, 0)
+
+	if len(result) != 2 {
+		t.Fatalf("Expected 2 filters after optimization, got %d: %+v", len(result), result)
+	}
+	if result[0].From != "a@news.com OR b@news.com OR c@news.com OR d@news.com" {
+		t.Errorf("Expected merged senders, got %q", result[0].From)
+	}
+	if result[0].Label != "Newsletters" || result[0].ShouldArchive == nil || !*result[0].ShouldArchive {
+		t.Errorf("Expected merged filter to keep actions, got %+v", result[0])
+	}
+	if result[1].From != "boss@corp.com" {
+		t.Errorf("Expected unrelated filter to keep its position, got %+v", result[1])
+	}
+}
+
+func TestOptimizeFilters_KeepsDifferentCriteriaApart(t *testing.T) {
+	filters := []Filter{
+		{From: "a@news.com", Subject: "Weekly", Label: "Newsletters"},
+		{From: "b@news.com", Subject: "Daily", Label: "Newsletters"},
+		{From: "c@news.com", Label: "Newsletters", ShouldStar: testutils.BoolPtr(true)},
+		{Subject: "Weekly", Label: "Newsletters"},
+	}
+
+	result := optimizeFilters(filters, 0)
+	if len(result) != len(filters) {
+		t.Errorf("Expected no merge when more than one criterion or the actions differ, got %+v", result)
+	}
+}
+
+func TestOptimizeFilters_RespectsLengthLimit(t *testing.T) {
+	filters := []Filter{
+		{From: "aaaa@news.com", Label: "Newsletters"},
+		{From: "bbbb@news.com", Label: "Newsletters"},
+		{From: "cccc@news.com", Label: "Newsletters"},
+	}
+
+	result := optimizeFilters(filters, 30)
+	if len(result) != 2 {
+		t.Fatalf("Expected merged filters to be chunked into 2 entries, got %+v", result)
+	}
+	for _, filter := range result {
+		if len(filter.From) > 30 {
+			t.Errorf("Merged criteria %q exceeds the limit", filter.From)
+		}
+	}
+}
+
+func TestBuildFeed_OptimizeReport(t *testing.T) {
+	config := FiltersConfig{
+		Author: Author{Name: "Test User", Email: "test@example.com"},
+		Filters: []Filter{
+			{From: "a@news.com", Label: "Newsletters"},
+			{From: "b@news.com", Label: "Newsletters"},
+		},
+	}
+
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	feed, report := BuildFeed(config, now, FeedOptions{Optimize: true})
+	if report.Filters != 2 || report.Optimized != 1 || len(feed.Entries) != 1 {
+		t.Errorf("Expected 2 filters optimized into 1 entry, got report %+v", report)
+	}
+
+	feed, report = BuildFeed(config, now, FeedOptions{})
+	if report.Optimized != 0 || len(feed.Entries) != 2 {
+		t.Errorf("Expected optimization to be opt-in, got report %+v", report)
+	}
+}
@@ -0,0 +1,281 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ============================================================================
+// Organization Policy Files
+// ============================================================================
+
+// OrgPolicy is a policy kept in its own file, so one set of rules can be
+// enforced on the configurations of a whole organization. It changes no
+// filter: CheckOrgPolicy only reports the filters breaking it.
+type OrgPolicy struct {
+	// Name identifies the policy in violations, the file name by default
+	Name string `yaml:"name,omitempty"`
+	// Forwarding restricts forwarding like the policy section of a configuration
+	Forwarding Policy `yaml:"forwarding,omitempty"`
+	// Rules restrict the actions of filters by sender domain
+	Rules []PolicyRule `yaml:"rules,omitempty"`
+}
+
+// PolicyRule restricts the actions of the filters matching mail from some
+// domains
+type PolicyRule struct {
+	// Name identifies the rule in violations, "rule N" by default
+	Name string `yaml:"name,omitempty"`
+	// From lists the sender domains, with their subdomains, the rule applies
+	// to; a rule without From applies to every filter
+	From StringList `yaml:"from,omitempty"`
+	// Forbid lists the actions the filters must not take
+	Forbid StringList `yaml:"forbid,omitempty"`
+	// Require lists the actions the filters must take
+	Require StringList `yaml:"require,omitempty"`
+}
+
+// LoadOrgPolicy reads and validates a YAML, JSON or TOML policy file
+func LoadOrgPolicy(filePath string) (OrgPolicy, error) {
+	format, err := configFormatForPath(filePath)
+	if err != nil {
+		return OrgPolicy{}, err
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return OrgPolicy{}, fmt.Errorf("reading file: %w", err)
+	}
+
+	if format == "" {
+		format = DetectConfigFormat(content)
+	}
+	var policy OrgPolicy
+	document, err := decodeFormat(content, format, &policy)
+	if err != nil {
+		return OrgPolicy{}, err
+	}
+
+	if policy.Name == "" {
+		policy.Name = strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	}
+	if errs := validateOrgPolicy(policy, document); len(errs) > 0 {
+		return OrgPolicy{}, errors.Join(errs...)
+	}
+	return policy, nil
+}
+
+// validateOrgPolicy checks the domains and actions named by a policy,
+// positioning errors with the decoded document when there is one
+func validateOrgPolicy(policy OrgPolicy, document *yaml.Node) []error {
+	root := document
+	if root != nil && root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+
+	var errs []error
+	_, forwarding := MappingEntry(root, "forwarding")
+	for _, problem := range policy.Forwarding.domainProblems() {
+		key, _ := MappingEntry(forwarding, problem.field)
+		errs = append(errs, policyFileError(problem.field, problem.value, RuleAddress,
+			"forwarding: "+problem.message, key))
+	}
+
+	_, rules := MappingEntry(root, "rules")
+	for i, rule := range policy.Rules {
+		var node *yaml.Node
+		if rules != nil && rules.Kind == yaml.SequenceNode && i < len(rules.Content) {
+			node = rules.Content[i]
+		}
+		ref := rule.ref(i)
+
+		if len(rule.Forbid) == 0 && len(rule.Require) == 0 {
+			errs = append(errs, policyFileError("", "", RuleAction,
+				fmt.Sprintf("%s must forbid or require at least one action", ref), node))
+		}
+		for _, domain := range rule.From {
+			if !isDomain(domain) {
+				key, _ := MappingEntry(node, "from")
+				errs = append(errs, policyFileError("from", domain, RuleAddress,
+					fmt.Sprintf("%s: from: '%s' is not a domain", ref, domain), key))
+			}
+		}
+		for _, list := range []struct {
+			field   string
+			actions StringList
+		}{{"forbid", rule.Forbid}, {"require", rule.Require}} {
+			for _, action := range list.actions {
+				if !isActionField(action) {
+					key, _ := MappingEntry(node, list.field)
+					errs = append(errs, policyFileError(list.field, action, RuleEnum,
+						fmt.Sprintf("%s: %s: unknown action '%s' (use %s)", ref, list.field, action, strings.Join(actionFields, ", ")), key))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// policyFileError builds a validation error of a policy file
+func policyFileError(field, value, rule, message string, node *yaml.Node) *ValidationError {
+	err := &ValidationError{Filter: -1, Field: field, Value: value, Rule: rule, Message: "policy file: " + message}
+	return err.at(node)
+}
+
+// CheckOrgPolicy evaluates a policy against every normalized filter of a
+// configuration, with defaults, templates and groups applied. Each broken
+// rule is reported as a *ValidationError naming the filter and the rule,
+// joined with errors.Join; nil means the configuration complies.
+func CheckOrgPolicy(policy OrgPolicy, config FiltersConfig) error {
+	config, err := PrepareConfig(config)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i, filter := range NormalizeFilters(config) {
+		for _, problem := range policy.Forwarding.check(filter) {
+			errs = append(errs, policy.violation(i, filter, "forwarding", problem))
+		}
+		for j, rule := range policy.Rules {
+			for _, problem := range rule.check(filter) {
+				errs = append(errs, policy.violation(i, filter, rule.ref(j), problem))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// violation builds the validation error of a filter breaking a policy rule
+func (p OrgPolicy) violation(index int, filter Filter, rule string, problem policyProblem) error {
+	return filterError(index, filter, problem.field, problem.value, RulePolicy,
+		fmt.Sprintf("%s: policy '%s' %s: %s", filterRef(index, filter), p.Name, rule, problem.message))
+}
+
+// ref names the rule in messages
+func (r PolicyRule) ref(index int) string {
+	if r.Name != "" {
+		return fmt.Sprintf("rule '%s'", r.Name)
+	}
+	return fmt.Sprintf("rule %d", index)
+}
+
+// check returns the problems of a normalized filter the rule applies to
+func (r PolicyRule) check(filter Filter) []policyProblem {
+	scope := ""
+	if len(r.From) > 0 {
+		domain := matchSender(filter, r.From)
+		if domain == "" {
+			return nil
+		}
+		scope = " for mail from " + domain
+	}
+
+	var problems []policyProblem
+	for _, action := range r.Forbid {
+		if value := actionValue(filter, action); value != "" {
+			problems = append(problems, policyProblem{action, value,
+				fmt.Sprintf("%s is forbidden%s", action, scope)})
+		}
+	}
+	for _, action := range r.Require {
+		if actionValue(filter, action) == "" {
+			problems = append(problems, policyProblem{action, "",
+				fmt.Sprintf("%s is required%s", action, scope)})
+		}
+	}
+	return problems
+}
+
+// fromOperatorRegex matches the from: operators of a search expression with
+// their value, a single term or a (...) or {...} group; negated -from:
+// operators are left out
+var fromOperatorRegex = regexp.MustCompile(`(?i)(?:^|[\s(])from:(\([^)]*\)|\{[^}]*\}|"[^"]*"|[^\s()]+)`)
+
+// matchSender returns the entry of domains matching a sender named by the
+// filter, in its from criterion or in from: operators of hasTheWord and
+// query, or "" when none does
+func matchSender(filter Filter, domains []string) string {
+	for _, sender := range filterSenders(filter) {
+		domain := strings.ToLower(sender)
+		if at := strings.LastIndex(domain, "@"); at >= 0 {
+			domain = domain[at+1:]
+		}
+		if entry := matchDomain(domain, domains); entry != "" {
+			return entry
+		}
+	}
+	return ""
+}
+
+// filterSenders lists the senders a filter matches mail from. Excluded
+// senders, negated or listed under none, are left out.
+func filterSenders(filter Filter) []string {
+	senders, ok := positiveCriteriaTerms(string(filter.From))
+	if !ok {
+		for _, term := range strings.Fields(string(filter.From)) {
+			if term = strings.TrimLeft(term, "({"); !strings.HasPrefix(term, "-") {
+				senders = append(senders, term)
+			}
+		}
+	}
+
+	for _, expression := range []string{string(filter.HasTheWord), filter.Query} {
+		for _, match := range fromOperatorRegex.FindAllStringSubmatch(expression, -1) {
+			value := strings.Trim(match[1], `(){}"`)
+			for _, term := range strings.Fields(value) {
+				if term == "OR" || term == "AND" || strings.HasPrefix(term, "-") {
+					continue
+				}
+				senders = append(senders, strings.Trim(term, `"`))
+			}
+		}
+	}
+	return senders
+}
+
+// actionValue returns the value of an action taken by a filter, or "" when
+// the filter does not take it
+func actionValue(filter Filter, action string) string {
+	flags := map[string]*bool{
+		"shouldArchive":               filter.ShouldArchive,
+		"shouldMarkAsRead":            filter.ShouldMarkAsRead,
+		"shouldStar":                  filter.ShouldStar,
+		"shouldNeverSpam":             filter.ShouldNeverSpam,
+		"shouldAlwaysMarkAsImportant": filter.ShouldAlwaysMarkAsImportant,
+		"shouldNeverMarkAsImportant":  filter.ShouldNeverMarkAsImportant,
+		"shouldTrash":                 filter.ShouldTrash,
+	}
+	if flag, ok := flags[action]; ok {
+		if isTrue(flag) {
+			return "true"
+		}
+		return ""
+	}
+
+	switch action {
+	case "label":
+		return filter.Label
+	case "smartLabel":
+		return filter.SmartLabel
+	case "forwardTo":
+		return filter.ForwardTo
+	}
+	return ""
+}
+
+// isActionField reports whether name is a filter action key
+func isActionField(name string) bool {
+	for _, field := range actionFields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
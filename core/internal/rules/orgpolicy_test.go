@@ -0,0 +1,181 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/carlosrabelo/grc/core/internal/testutils"
+)
+
+const orgPolicyFile = `name: corp
+forwarding:
+  allowedForwardDomains: ["corp.com"]
+rules:
+  - name: no-trash-corp
+    from: "@corp.com"
+    forbid: shouldTrash
+  - from: ["corp.com", "corp.io"]
+    require: [shouldNeverSpam]
+`
+
+func writePolicyFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write policy: %v", err)
+	}
+	return path
+}
+
+func TestCheckOrgPolicy(t *testing.T) {
+	policy, err := LoadOrgPolicy(writePolicyFile(t, "corp.yaml", orgPolicyFile))
+	if err != nil {
+		t.Fatalf("LoadOrgPolicy failed: %v", err)
+	}
+
+	content := `author:
+  name: "Test User"
+  email: "me@corp.com"
+default:
+  shouldNeverSpam: true
+filters:
+  - from: "boss@corp.com"
+    label: "Boss"
+  - from: ["alerts@mail.corp.com", "x@example.com"]
+    shouldTrash: true
+    shouldNeverSpam: false
+  - from: "news@shop.com"
+    forwardTo: "me@gmail.com"
+  - from: "a@corp.io"
+    label: "IO"
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+	config, err := LoadConfig(tmpFile)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	errs := ValidationErrors(CheckOrgPolicy(policy, config))
+	expected := []struct {
+		filter  int
+		field   string
+		line    int
+		message string
+	}{
+		{1, "shouldTrash", 10, "filter 1: policy 'corp' rule 'no-trash-corp': shouldTrash is forbidden for mail from @corp.com"},
+		{1, "shouldNeverSpam", 11, "filter 1: policy 'corp' rule 1: shouldNeverSpam is required for mail from corp.com"},
+		{2, "forwardTo", 13, "filter 2: policy 'corp' forwarding: forwardTo 'me@gmail.com' is outside the allowed forward domains (corp.com)"},
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d violations, got: %v", len(expected), errs)
+	}
+	for i, want := range expected {
+		got := errs[i]
+		if got.Filter != want.filter || got.Field != want.field || got.Line != want.line || got.Rule != RulePolicy || got.Message != want.message {
+			t.Errorf("Violation %d: unexpected %+v", i, *got)
+		}
+	}
+
+	config.Filters = config.Filters[:1]
+	if err := CheckOrgPolicy(policy, config); err != nil {
+		t.Errorf("Expected a compliant configuration, got: %v", err)
+	}
+}
+
+func TestCheckOrgPolicy_ExcludedSenders(t *testing.T) {
+	policy, err := LoadOrgPolicy(writePolicyFile(t, "corp.yaml", orgPolicyFile))
+	if err != nil {
+		t.Fatalf("LoadOrgPolicy failed: %v", err)
+	}
+
+	content := `author:
+  name: "Test User"
+  email: "me@corp.com"
+filters:
+  - from:
+      any: ["@spam.com"]
+      none: ["boss@corp.com"]
+    shouldTrash: true
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+	config, err := LoadConfig(tmpFile)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if err := CheckOrgPolicy(policy, config); err != nil {
+		t.Errorf("Expected excluded senders to be ignored, got: %v", err)
+	}
+}
+
+func TestLoadOrgPolicy_Formats(t *testing.T) {
+	files := map[string]string{
+		"corp.json": `{"forwarding": {"forbiddenForwardDomains": ["gmail.com"]}, "rules": [{"from": "corp.com", "forbid": "shouldTrash"}]}`,
+		"corp.toml": "[forwarding]\nforbiddenForwardDomains = [\"gmail.com\"]\n\n[[rules]]\nfrom = \"corp.com\"\nforbid = \"shouldTrash\"\n",
+	}
+	for name, content := range files {
+		policy, err := LoadOrgPolicy(writePolicyFile(t, name, content))
+		if err != nil {
+			t.Errorf("%s: LoadOrgPolicy failed: %v", name, err)
+			continue
+		}
+		if policy.Name != "corp" || len(policy.Rules) != 1 || policy.Rules[0].Forbid[0] != "shouldTrash" || policy.Forwarding.ForbiddenForwardDomains[0] != "gmail.com" {
+			t.Errorf("%s: unexpected policy %+v", name, policy)
+		}
+	}
+}
+
+func TestLoadOrgPolicy_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		message string
+		line    int
+	}{
+		{"unknown key", "rules:\n  - from: corp.com\n    deny: shouldTrash\n", "field deny not found", 0},
+		{"bad domain", "forwarding:\n  allowedForwardDomains: [\"not a domain\"]\n", "forwarding: allowedForwardDomains: 'not a domain' is not a domain", 2},
+		{"unknown action", "rules:\n  - name: x\n    require: shouldExplode\n", "rule 'x': require: unknown action 'shouldExplode'", 3},
+		{"no action", "rules:\n  - from: corp.com\n", "rule 0 must forbid or require at least one action", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadOrgPolicy(writePolicyFile(t, "policy.yaml", tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.message) {
+				t.Fatalf("Expected error containing %q, got: %v", tt.message, err)
+			}
+			if tt.line > 0 {
+				if errs := ValidationErrors(err); len(errs) != 1 || errs[0].Line != tt.line {
+					t.Errorf("Expected one error on line %d, got: %+v", tt.line, errs)
+				}
+			}
+		})
+	}
+}
+
+func TestMatchSender_SearchOperators(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected string
+	}{
+		{"from criterion", Filter{From: "a@x.com OR boss@corp.com"}, "corp.com"},
+		{"hasTheWord operator", Filter{HasTheWord: "from:boss@corp.com"}, "corp.com"},
+		{"query group", Filter{Query: "subject:report from:(a@x.com OR b@mail.corp.com)"}, "corp.com"},
+		{"braced group", Filter{HasTheWord: "urgent FROM:{@corp.com a@x.com}"}, "corp.com"},
+		{"negated operator", Filter{HasTheWord: "-from:boss@corp.com"}, ""},
+		{"other operator", Filter{HasTheWord: "to:boss@corp.com"}, ""},
+		{"negated sender", Filter{From: "@spam.com -boss@corp.com"}, ""},
+		{"negated group", Filter{From: "@spam.com -{boss@corp.com ceo@corp.com}"}, ""},
+		{"double negation", Filter{From: "-(a@x.com -boss@corp.com)"}, "corp.com"},
+		{"unparsed negation", Filter{From: "@spam.com (-boss@corp.com"}, ""},
+	}
+	for _, tt := range tests {
+		if got := matchSender(tt.filter, []string{"corp.com"}); got != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, got)
+		}
+	}
+}
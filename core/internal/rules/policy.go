@@ -0,0 +1,239 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ============================================================================
+// Forwarding Policy
+// ============================================================================
+
+// Policy restricts what filters forwarding mail may do. Violations fail
+// validation like any other configuration error.
+type Policy struct {
+	// AllowedForwardDomains lists the only domains, and their subdomains,
+	// forwardTo may point to
+	AllowedForwardDomains []string `yaml:"allowedForwardDomains,omitempty"`
+	// ForbiddenForwardDomains lists domains forwardTo must never point to
+	ForbiddenForwardDomains []string `yaml:"forbiddenForwardDomains,omitempty"`
+	// RequireForwardLabel makes forwarding filters apply a label, so forwarded
+	// mail can be found in the mailbox
+	RequireForwardLabel bool `yaml:"requireForwardLabel,omitempty"`
+	// ForbidForwardAndTrash rejects filters forwarding and deleting the same
+	// mail, which leaves the only copy outside the mailbox
+	ForbidForwardAndTrash bool `yaml:"forbidForwardAndTrash,omitempty"`
+}
+
+// policyProblem is a policy rule broken by a filter
+type policyProblem struct {
+	field   string
+	value   string
+	message string
+}
+
+// ForwardAudit describes a filter forwarding mail and how it fares against
+// the forwarding policy
+type ForwardAudit struct {
+	Filter      int      // index among the flattened filters
+	Ref         string   // filter reference used in error messages
+	Line        int      // line of the filter in the configuration, 0 if unknown
+	ForwardTo   string   // effective forwarding address, defaults applied
+	Description string   // plain-language description of the filter
+	Problems    []string // broken policy rules, empty when compliant
+
+	// errs holds the validation errors of the broken rules
+	errs []error
+}
+
+// validatePolicy checks the policy section and every filter against it
+func validatePolicy(config FiltersConfig) []error {
+	errs := validatePolicySection(config)
+	if len(errs) > 0 {
+		return errs
+	}
+
+	for i, filter := range config.Filters {
+		normalized := normalizeFilter(filter, config.Defaults)
+		for _, problem := range config.Policy.check(normalized) {
+			errs = append(errs, filterError(i, filter, problem.field, problem.value, RulePolicy,
+				fmt.Sprintf("%s: policy: %s", filterRef(i, filter), problem.message)))
+		}
+	}
+	return errs
+}
+
+// validatePolicySection checks the domains listed in the policy section
+func validatePolicySection(config FiltersConfig) []error {
+	var errs []error
+	for _, problem := range config.Policy.domainProblems() {
+		errs = append(errs, sectionError(config, "policy", problem.field, problem.value, RuleAddress,
+			"policy: "+problem.message))
+	}
+	return errs
+}
+
+// domainProblems returns the entries of the domain lists that are not domains
+func (p Policy) domainProblems() []policyProblem {
+	var problems []policyProblem
+	lists := []struct {
+		field   string
+		domains []string
+	}{
+		{"allowedForwardDomains", p.AllowedForwardDomains},
+		{"forbiddenForwardDomains", p.ForbiddenForwardDomains},
+	}
+	for _, list := range lists {
+		for _, domain := range list.domains {
+			if !isDomain(domain) {
+				problems = append(problems, policyProblem{list.field, domain,
+					fmt.Sprintf("%s: '%s' is not a domain", list.field, domain)})
+			}
+		}
+	}
+	return problems
+}
+
+// check returns the policy rules broken by a normalized filter
+func (p Policy) check(filter Filter) []policyProblem {
+	forwardTo := filter.ForwardTo
+	if forwardTo == "" {
+		return nil
+	}
+
+	var problems []policyProblem
+	domain := addressDomain(forwardTo)
+	if len(p.AllowedForwardDomains) > 0 && matchDomain(domain, p.AllowedForwardDomains) == "" {
+		problems = append(problems, policyProblem{"forwardTo", forwardTo,
+			fmt.Sprintf("forwardTo '%s' is outside the allowed forward domains (%s)",
+				forwardTo, strings.Join(p.AllowedForwardDomains, ", "))})
+	}
+	if forbidden := matchDomain(domain, p.ForbiddenForwardDomains); forbidden != "" {
+		problems = append(problems, policyProblem{"forwardTo", forwardTo,
+			fmt.Sprintf("forwardTo '%s' is in the forbidden forward domain '%s'", forwardTo, forbidden)})
+	}
+	if p.RequireForwardLabel && filter.Label == "" {
+		problems = append(problems, policyProblem{"label", "",
+			fmt.Sprintf("filters forwarding to '%s' must apply a label", forwardTo)})
+	}
+	if p.ForbidForwardAndTrash && isTrue(filter.ShouldTrash) {
+		problems = append(problems, policyProblem{"shouldTrash", "true",
+			fmt.Sprintf("filters forwarding to '%s' must not trash the message", forwardTo)})
+	}
+	return problems
+}
+
+// AuditForwards lists every filter forwarding mail with the policy rules it
+// breaks. The configuration is validated except for the policy, so broken
+// rules are listed instead of failing; AuditError reports them as errors.
+// The prepared configuration is returned as well, with its policy, so
+// callers need not resolve its variables and secrets again.
+func AuditForwards(config FiltersConfig) ([]ForwardAudit, FiltersConfig, error) {
+	if errs := validatePolicySection(config); len(errs) > 0 {
+		return nil, FiltersConfig{}, errors.Join(errs...)
+	}
+
+	policy := config.Policy
+	config.Policy = Policy{}
+	prepared, err := PrepareConfig(config)
+	if err != nil {
+		return nil, FiltersConfig{}, err
+	}
+	prepared.Policy = policy
+
+	var audits []ForwardAudit
+	for i, filter := range NormalizeFilters(prepared) {
+		if filter.ForwardTo == "" {
+			continue
+		}
+		audit := ForwardAudit{
+			Filter:      i,
+			Ref:         filterRef(i, filter),
+			ForwardTo:   filter.ForwardTo,
+			Description: ExplainFilter(filter),
+		}
+		if filter.origin.node != nil {
+			audit.Line = filter.origin.node.Line
+		}
+		for _, problem := range policy.check(filter) {
+			audit.Problems = append(audit.Problems, problem.message)
+			audit.errs = append(audit.errs, filterError(i, filter, problem.field, problem.value, RulePolicy,
+				fmt.Sprintf("%s: policy: %s", audit.Ref, problem.message)))
+		}
+		audits = append(audits, audit)
+	}
+	return audits, prepared, nil
+}
+
+// AuditError returns the policy violations found by AuditForwards as
+// *ValidationError values joined with errors.Join, or nil when every
+// forwarding filter complies
+func AuditError(audits []ForwardAudit) error {
+	var errs []error
+	for _, audit := range audits {
+		errs = append(errs, audit.errs...)
+	}
+	return errors.Join(errs...)
+}
+
+// WriteAudit writes the forwarding audit as text
+func WriteAudit(w io.Writer, audits []ForwardAudit) error {
+	var b strings.Builder
+	if len(audits) == 0 {
+		b.WriteString("No filter forwards mail.\n")
+	} else {
+		violating := 0
+		for _, audit := range audits {
+			if len(audit.Problems) > 0 {
+				violating++
+			}
+		}
+		fmt.Fprintf(&b, "Forwarding rules: %d, %d violating the policy\n", len(audits), violating)
+
+		for _, audit := range audits {
+			location := audit.Ref
+			if audit.Line > 0 {
+				location += fmt.Sprintf(" (line %d)", audit.Line)
+			}
+			status := "ok"
+			if len(audit.Problems) > 0 {
+				status = "VIOLATION"
+			}
+			fmt.Fprintf(&b, "\n%s: forwards to %s [%s]\n  %s\n", location, audit.ForwardTo, status, audit.Description)
+			for _, problem := range audit.Problems {
+				fmt.Fprintf(&b, "  - %s\n", problem)
+			}
+		}
+	}
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("writing audit: %w", err)
+	}
+	return nil
+}
+
+// isDomain reports whether a policy entry names a domain, optionally
+// written with a leading "@"
+func isDomain(entry string) bool {
+	return domainRegex.MatchString(entry) && !strings.HasPrefix(entry, "*")
+}
+
+// addressDomain returns the lowercased domain of an email address
+func addressDomain(address string) string {
+	_, domain, _ := strings.Cut(strings.TrimSpace(address), "@")
+	return strings.ToLower(domain)
+}
+
+// matchDomain returns the entry of domains that domain equals or is a
+// subdomain of, or "" when none matches. Entries may start with "@".
+func matchDomain(domain string, domains []string) string {
+	for _, entry := range domains {
+		candidate := strings.ToLower(strings.TrimPrefix(entry, "@"))
+		if domain == candidate || strings.HasSuffix(domain, "."+candidate) {
+			return entry
+		}
+	}
+	return ""
+}
@@ -0,0 +1,172 @@
+package rules
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/carlosrabelo/grc/core/internal/testutils"
+)
+
+const policyConfig = `author:
+  name: "Test User"
+  email: "me@corp.com"
+policy:
+  allowedForwardDomains: ["corp.com"]
+  forbiddenForwardDomains: ["@legacy.corp.com"]
+  requireForwardLabel: true
+  forbidForwardAndTrash: true
+filters:
+  - from: "boss@corp.com"
+    label: "Boss"
+    forwardTo: "assistant@mail.corp.com"
+  - from: "alerts@corp.com"
+    forwardTo: "me.private@gmail.com"
+    shouldTrash: true
+  - from: "old@corp.com"
+    label: "Old"
+    forwardTo: "archive@legacy.corp.com"
+  - from: "x@example.com"
+    label: "X"
+`
+
+func TestValidatePolicy(t *testing.T) {
+	tmpFile := testutils.CreateTempYAMLFile(t, policyConfig)
+	defer testutils.CleanupFile(tmpFile)
+
+	_, err := LoadConfig(tmpFile)
+	errs := ValidationErrors(err)
+
+	expected := []struct {
+		filter  int
+		field   string
+		line    int
+		message string
+	}{
+		{1, "forwardTo", 14, "outside the allowed forward domains (corp.com)"},
+		{1, "label", 13, "must apply a label"},
+		{1, "shouldTrash", 15, "must not trash the message"},
+		{2, "forwardTo", 18, "in the forbidden forward domain '@legacy.corp.com'"},
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d policy errors, got: %v", len(expected), err)
+	}
+	for i, want := range expected {
+		got := errs[i]
+		if got.Filter != want.filter || got.Field != want.field || got.Rule != RulePolicy || got.Line != want.line {
+			t.Errorf("Error %d: unexpected %+v", i, *got)
+		}
+		if !strings.Contains(got.Message, want.message) {
+			t.Errorf("Error %d: expected %q in %q", i, want.message, got.Message)
+		}
+	}
+}
+
+func TestValidatePolicy_Defaults(t *testing.T) {
+	content := `author:
+  name: "Test User"
+  email: "me@corp.com"
+policy:
+  forbiddenForwardDomains: ["gmail.com"]
+default:
+  forwardTo: "me@gmail.com"
+filters:
+  - from: "x@example.com"
+    label: "X"
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+
+	_, err := LoadConfig(tmpFile)
+	if errs := ValidationErrors(err); len(errs) != 1 || errs[0].Rule != RulePolicy || errs[0].Filter != 0 {
+		t.Errorf("Expected a policy error from the default forwardTo, got: %v", err)
+	}
+}
+
+func TestValidatePolicy_InvalidDomain(t *testing.T) {
+	content := `author:
+  name: "Test User"
+  email: "me@corp.com"
+policy:
+  allowedForwardDomains: ["corp.com", "not a domain"]
+filters:
+  - from: "x@example.com"
+    label: "X"
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+
+	_, err := LoadConfig(tmpFile)
+	errs := ValidationErrors(err)
+	if len(errs) != 1 || errs[0].Rule != RuleAddress || errs[0].Value != "not a domain" {
+		t.Errorf("Expected an invalid domain error, got: %v", err)
+	}
+}
+
+func TestMatchDomain(t *testing.T) {
+	tests := []struct {
+		domain   string
+		domains  []string
+		expected string
+	}{
+		{"corp.com", []string{"corp.com"}, "corp.com"},
+		{"mail.corp.com", []string{"@Corp.com"}, "@Corp.com"},
+		{"notcorp.com", []string{"corp.com"}, ""},
+		{"corp.com.evil.com", []string{"corp.com"}, ""},
+		{"", []string{"corp.com"}, ""},
+	}
+	for _, tt := range tests {
+		if got := matchDomain(tt.domain, tt.domains); got != tt.expected {
+			t.Errorf("matchDomain(%q, %v) = %q, expected %q", tt.domain, tt.domains, got, tt.expected)
+		}
+	}
+}
+
+func TestAuditForwards(t *testing.T) {
+	tmpFile := testutils.CreateTempYAMLFile(t, policyConfig)
+	defer testutils.CleanupFile(tmpFile)
+
+	config, err := ReadConfig(tmpFile)
+	if err != nil {
+		t.Fatalf("ReadConfig failed: %v", err)
+	}
+	audits, prepared, err := AuditForwards(config)
+	if err != nil {
+		t.Fatalf("AuditForwards failed: %v", err)
+	}
+	if len(prepared.Filters) != 4 || len(prepared.Policy.AllowedForwardDomains) != 1 {
+		t.Errorf("Expected the prepared configuration with its policy, got %+v", prepared)
+	}
+	errs := ValidationErrors(AuditError(audits))
+	if len(errs) != 4 || errs[0].Rule != RulePolicy || errs[0].Filter != 1 || errs[0].Line != 14 {
+		t.Errorf("Expected the four violations as validation errors, got: %v", errs)
+	}
+	if len(audits) != 3 {
+		t.Fatalf("Expected 3 forwarding filters, got %+v", audits)
+	}
+	if audits[0].Line != 10 || len(audits[0].Problems) != 0 || len(audits[1].Problems) != 3 || len(audits[2].Problems) != 1 {
+		t.Errorf("Unexpected audits: %+v", audits)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteAudit(&buf, audits); err != nil {
+		t.Fatalf("WriteAudit failed: %v", err)
+	}
+	output := buf.String()
+	for _, want := range []string{
+		"Forwarding rules: 3, 2 violating the policy",
+		"filter 0 (line 10): forwards to assistant@mail.corp.com [ok]",
+		"filter 1 (line 13): forwards to me.private@gmail.com [VIOLATION]",
+		"  - filters forwarding to 'me.private@gmail.com' must not trash the message",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in audit:\n%s", want, output)
+		}
+	}
+
+	buf.Reset()
+	WriteAudit(&buf, nil)
+	if buf.String() != "No filter forwards mail.\n" {
+		t.Errorf("Unexpected empty audit: %q", buf.String())
+	}
+}
@@ -0,0 +1,62 @@
+package rules
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ============================================================================
+// Redaction
+// ============================================================================
+
+// minRedactedLength is the shortest sensitive value replaced in text;
+// shorter values would mangle unrelated words. The -redact help and the
+// README state this limit.
+const minRedactedLength = 4
+
+// addressRegex finds email addresses in free text
+var addressRegex = regexp.MustCompile(`[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}`)
+
+// Redactor hides email addresses and sensitive values in logs and reports.
+// Each one becomes a placeholder derived from its hash, so the same address
+// keeps the same placeholder and diffs of redacted output stay meaningful.
+type Redactor struct {
+	values []string
+}
+
+// NewRedactor returns a redactor hiding email addresses and the given values
+func NewRedactor(values ...string) *Redactor {
+	r := &Redactor{}
+	r.Add(values...)
+	return r
+}
+
+// Add registers more sensitive values, e.g. SensitiveValues of a configuration
+func (r *Redactor) Add(values ...string) {
+	for _, value := range values {
+		if len(value) >= minRedactedLength {
+			r.values = append(r.values, value)
+		}
+	}
+	// Longer values first, so values containing others are replaced whole
+	sort.SliceStable(r.values, func(i, j int) bool { return len(r.values[i]) > len(r.values[j]) })
+}
+
+// Redact returns text with addresses and sensitive values replaced
+func (r *Redactor) Redact(text string) string {
+	for _, value := range r.values {
+		if strings.Contains(text, value) {
+			text = strings.ReplaceAll(text, value, redactedPlaceholder(value))
+		}
+	}
+	return addressRegex.ReplaceAllStringFunc(text, redactedPlaceholder)
+}
+
+// redactedPlaceholder returns the stable placeholder of a value
+func redactedPlaceholder(value string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(value)))
+	return fmt.Sprintf("<redacted:%x>", sum[:3])
+}
@@ -0,0 +1,29 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactor(t *testing.T) {
+	redactor := NewRedactor("Jane Doe", "abc")
+	text := "Jane Doe <jane@corp.com> forwards to Jane.Doe+x@example.com, abc stays"
+
+	redacted := redactor.Redact(text)
+	for _, leaked := range []string{"Jane Doe", "jane@corp.com", "example.com"} {
+		if strings.Contains(redacted, leaked) {
+			t.Errorf("Expected %q to be redacted, got: %s", leaked, redacted)
+		}
+	}
+	if !strings.Contains(redacted, "abc stays") {
+		t.Errorf("Expected values shorter than %d characters to be kept, got: %s", minRedactedLength, redacted)
+	}
+
+	// Placeholders are stable, so redacted output can still be diffed
+	if first, second := redactor.Redact("a@b.com"), redactor.Redact("x A@B.com"); !strings.HasSuffix(second, first) {
+		t.Errorf("Expected the same placeholder for the same address, got %s and %s", first, second)
+	}
+	if redactor.Redact("a@b.com") == redactor.Redact("c@b.com") {
+		t.Error("Expected different addresses to get different placeholders")
+	}
+}
@@ -1,7 +1,6 @@
 package rules
 
 import (
-	"bytes"
 	"encoding/xml"
 	"errors"
 	"fmt"
@@ -39,29 +38,51 @@ var domainRegex = regexp.MustCompile(`^((\*)?@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}|[a-z
 // Data Types - YAML Configuration
 // ============================================================================
 
-// Defaults defines default options that can be reused in filters
+// Defaults defines default options that can be reused in filters. Boolean
+// fields are tri-state: nil leaves the filter untouched, while true and false
+// are both applied to filters that do not set the field themselves.
 type Defaults struct {
-	ShouldArchive               bool `yaml:"shouldArchive"`
-	ShouldMarkAsRead            bool `yaml:"shouldMarkAsRead"`
-	ShouldStar                  bool `yaml:"shouldStar"`
-	ShouldNeverSpam             bool `yaml:"shouldNeverSpam"`
-	ShouldAlwaysMarkAsImportant bool `yaml:"shouldAlwaysMarkAsImportant"`
-	ShouldNeverMarkAsImportant  bool `yaml:"shouldNeverMarkAsImportant"`
-	ShouldTrash                 bool `yaml:"shouldTrash"`
-	HasAttachment               bool `yaml:"hasAttachment"`
+	ShouldArchive               *bool `yaml:"shouldArchive,omitempty"`
+	ShouldMarkAsRead            *bool `yaml:"shouldMarkAsRead,omitempty"`
+	ShouldStar                  *bool `yaml:"shouldStar,omitempty"`
+	ShouldNeverSpam             *bool `yaml:"shouldNeverSpam,omitempty"`
+	ShouldAlwaysMarkAsImportant *bool `yaml:"shouldAlwaysMarkAsImportant,omitempty"`
+	ShouldNeverMarkAsImportant  *bool `yaml:"shouldNeverMarkAsImportant,omitempty"`
+	ShouldTrash                 *bool `yaml:"shouldTrash,omitempty"`
+
+	// Deprecated: HasAttachment is a criterion; use FiltersConfig.DefaultCriteria.
+	// It is still parsed so that older configurations load with a warning.
+	HasAttachment *bool `yaml:"hasAttachment,omitempty"`
+
+	// String actions
+	LabelPrefix string `yaml:"labelPrefix,omitempty"`
+	SmartLabel  string `yaml:"smartLabel,omitempty"`
+	ForwardTo   string `yaml:"forwardTo,omitempty"`
 }
 
 // Filter represents a Gmail filter coming from the YAML file
 type Filter struct {
+	// Templates merged into the filter before defaults are applied
+	Use StringList `yaml:"use,omitempty"`
+	// InheritDefaults set to false skips the global and group defaults
+	InheritDefaults *bool `yaml:"inheritDefaults,omitempty"`
+
 	// Filtering criteria
-	From               string `yaml:"from,omitempty"`
-	To                 string `yaml:"to,omitempty"`
-	Subject            string `yaml:"subject,omitempty"`
-	HasTheWord         string `yaml:"hasTheWord,omitempty"`
-	DoesNotHaveTheWord string `yaml:"doesNotHaveTheWord,omitempty"`
-	List               string `yaml:"list,omitempty"`
-	Query              string `yaml:"query,omitempty"`
-	HasAttachment      *bool  `yaml:"hasAttachment,omitempty"`
+	From               Criterion `yaml:"from,omitempty"`
+	To                 Criterion `yaml:"to,omitempty"`
+	Subject            Criterion `yaml:"subject,omitempty"`
+	HasTheWord         Criterion `yaml:"hasTheWord,omitempty"`
+	DoesNotHaveTheWord string    `yaml:"doesNotHaveTheWord,omitempty"`
+	List               Criterion `yaml:"list,omitempty"`
+	Query              string    `yaml:"query,omitempty"`
+	HasAttachment      *bool     `yaml:"hasAttachment,omitempty"`
+	ExcludeChats       *bool     `yaml:"excludeChats,omitempty"`
+	Size               string    `yaml:"size,omitempty"`
+
+	// Negated criteria, rendered into doesNotHaveTheWord
+	NotFrom    Criterion `yaml:"notFrom,omitempty"`
+	NotTo      Criterion `yaml:"notTo,omitempty"`
+	NotSubject Criterion `yaml:"notSubject,omitempty"`
 
 	// Filter actions
 	Label                       string `yaml:"label,omitempty"`
@@ -74,19 +95,41 @@ type Filter struct {
 	ShouldAlwaysMarkAsImportant *bool  `yaml:"shouldAlwaysMarkAsImportant,omitempty"`
 	ShouldNeverMarkAsImportant  *bool  `yaml:"shouldNeverMarkAsImportant,omitempty"`
 	ShouldTrash                 *bool  `yaml:"shouldTrash,omitempty"`
+
+	// Where the filter was declared, set when groups are flattened
+	origin filterOrigin
 }
 
 // Author represents the author block used in Gmail export
 type Author struct {
-	Name  string `yaml:"name" xml:"name"`
-	Email string `yaml:"email" xml:"email"`
+	Name  string `yaml:"name" xml:"name" json:"name"`
+	Email string `yaml:"email" xml:"email" json:"email"`
 }
 
 // FiltersConfig defines how to build the Gmail filters feed
 type FiltersConfig struct {
-	Author   Author   `yaml:"author"`
-	Defaults Defaults `yaml:"default"`
-	Filters  []Filter `yaml:"filters"`
+	Vars            map[string]string `yaml:"vars,omitempty"`
+	Secrets         string            `yaml:"secrets,omitempty"`
+	Author          Author            `yaml:"author"`
+	Defaults        Defaults          `yaml:"default" toml:"default"`
+	DefaultCriteria CriteriaDefaults  `yaml:"defaultCriteria,omitempty"`
+	Templates       map[string]Filter `yaml:"templates,omitempty"`
+	Limits          Limits            `yaml:"limits,omitempty"`
+	Policy          Policy            `yaml:"policy,omitempty"`
+	Filters         []Filter          `yaml:"filters"`
+	Groups          []FilterGroup     `yaml:"groups,omitempty"`
+
+	// Warnings collected while loading the configuration
+	Warnings []string `yaml:"-" toml:"-"`
+
+	// Set once PrepareConfig has resolved and validated the configuration
+	prepared bool
+	// Root mapping of the decoded YAML, used to position validation errors
+	source *yaml.Node
+	// Directory of the configuration file, base of relative file references
+	baseDir string
+	// Values read through external references, for redaction
+	sensitive []string
 }
 
 // ============================================================================
@@ -131,9 +174,21 @@ type Property struct {
 // Main Public API
 // ============================================================================
 
-// LoadConfig reads and validates a YAML configuration file
+// LoadConfig reads and validates a YAML, JSON or TOML configuration file.
+// The format follows the extension; files without one are sniffed.
 func LoadConfig(filePath string) (FiltersConfig, error) {
-	if err := validateYAMLExtension(filePath); err != nil {
+	config, err := ReadConfig(filePath)
+	if err != nil {
+		return FiltersConfig{}, err
+	}
+	return PrepareConfig(config)
+}
+
+// ReadConfig reads a configuration file without validating it, like
+// DecodeConfig; relative file references resolve against its directory
+func ReadConfig(filePath string) (FiltersConfig, error) {
+	format, err := configFormatForPath(filePath)
+	if err != nil {
 		return FiltersConfig{}, err
 	}
 
@@ -142,7 +197,64 @@ func LoadConfig(filePath string) (FiltersConfig, error) {
 		return FiltersConfig{}, err
 	}
 
-	config, err := parseYAMLContent(fileContent)
+	if format == "" {
+		format = DetectConfigFormat(fileContent)
+	}
+	config, err := DecodeConfigFormat(fileContent, format)
+	if err != nil {
+		return FiltersConfig{}, err
+	}
+	config.baseDir = filepath.Dir(filePath)
+	return config, nil
+}
+
+// ParseConfig parses and validates YAML configuration content
+func ParseConfig(content []byte) (FiltersConfig, error) {
+	config, err := DecodeConfig(content)
+	if err != nil {
+		return FiltersConfig{}, err
+	}
+	return PrepareConfig(config)
+}
+
+// ParseConfigIn parses and validates YAML configuration content like
+// ParseConfig, resolving relative file references against dir
+func ParseConfigIn(content []byte, dir string) (FiltersConfig, error) {
+	config, err := DecodeConfig(content)
+	if err != nil {
+		return FiltersConfig{}, err
+	}
+	config.baseDir = dir
+	return PrepareConfig(config)
+}
+
+// DecodeConfig decodes YAML configuration content without validating it
+func DecodeConfig(content []byte) (FiltersConfig, error) {
+	return parseYAMLContent(content)
+}
+
+// PrepareConfig resolves groups, templates and variables of a decoded or
+// programmatically built configuration and validates the result. Groups,
+// templates and variables of prepared configurations are not resolved again,
+// but their filters are always revalidated, since callers may edit them.
+func PrepareConfig(config FiltersConfig) (FiltersConfig, error) {
+	if config.prepared {
+		if err := validateConfiguration(config); err != nil {
+			return FiltersConfig{}, err
+		}
+		return config, nil
+	}
+
+	var err error
+	config = migrateDefaults(config)
+	config = flattenGroups(config)
+
+	config, err = expandTemplates(config)
+	if err != nil {
+		return FiltersConfig{}, err
+	}
+
+	config, err = interpolateConfig(config)
 	if err != nil {
 		return FiltersConfig{}, err
 	}
@@ -150,49 +262,105 @@ func LoadConfig(filePath string) (FiltersConfig, error) {
 	if err := validateConfiguration(config); err != nil {
 		return FiltersConfig{}, err
 	}
+	config.Warnings = append(config.Warnings, checkCriteriaDefaults(config)...)
+	config.prepared = true
 
 	return config, nil
 }
 
+// FeedOptions tunes how BuildFeed turns filters into entries
+type FeedOptions struct {
+	// Optimize merges filters that differ only in one criterion
+	Optimize bool
+	// Sort orders the entries instead of keeping YAML order
+	Sort SortOptions
+}
+
+// FeedReport describes how configured filters were turned into feed entries
+type FeedReport struct {
+	Filters   int           // number of configured filters
+	Entries   int           // number of generated entries
+	Optimized int           // number of filters left after optimization (when enabled)
+	Splits    []SplitResult // filters split because of oversized criteria
+}
+
 // GenerateFeed builds the Atom feed ready for XML serialization
 func GenerateFeed(config FiltersConfig, now time.Time) Feed {
+	feed, _ := BuildFeed(config, now, FeedOptions{})
+	return feed
+}
+
+// BuildFeed builds the Atom feed and reports how filters were turned into entries
+func BuildFeed(config FiltersConfig, now time.Time, options FeedOptions) (Feed, FeedReport) {
 	updated := now.Format(time.RFC3339)
-	
+
 	feed := createBaseFeed(config.Author, updated, now)
-	
-	for i, filterConfig := range config.Filters {
-		normalizedFilter := applyDefaults(filterConfig, config.Defaults)
+
+	maxLength := config.Limits.criteriaLength()
+	filters := NormalizeFilters(config)
+	report := FeedReport{Filters: len(filters)}
+	if options.Optimize {
+		filters = optimizeFilters(filters, maxLength)
+		report.Optimized = len(filters)
+	}
+	filters = SortFilters(filters, options.Sort)
+	filters, report.Splits = splitFilters(filters, maxLength)
+
+	for i, normalizedFilter := range filters {
 		entry := createFeedEntry(normalizedFilter, i, updated)
 		feed.Entries = append(feed.Entries, entry)
 	}
+	report.Entries = len(feed.Entries)
 
-	return feed
+	return feed, report
 }
 
-// SaveXML writes the feed to disk and refuses to overwrite files unless force is true
-func SaveXML(filePath string, feed Feed, force bool) error {
-	normalizedPath := ensureXMLExtension(filePath)
+// NormalizeFilters returns the configured filters, including those declared
+// in groups, with group and global defaults and criteria defaults applied
+func NormalizeFilters(config FiltersConfig) []Filter {
+	config = migrateDefaults(config)
+	config = flattenGroups(config)
+
+	filters := make([]Filter, 0, len(config.Filters))
+	for i, filterConfig := range config.Filters {
+		filterConfig.origin.position = i
+		normalized := normalizeFilter(filterConfig, config.Defaults)
+		filters = append(filters, applyCriteriaDefaults(normalized, config.DefaultCriteria))
+	}
+	return filters
+}
 
-	if err := validateFileOverwrite(normalizedPath, force); err != nil {
-		return err
+// normalizeFilter prefixes the label with the label prefix of its groups and
+// applies the filter's group defaults and then the global defaults
+func normalizeFilter(filter Filter, defaults Defaults) Filter {
+	if filter.Label != "" && filter.origin.labelPrefix != "" {
+		filter.Label = joinLabel(filter.origin.labelPrefix, filter.Label)
+		filter.origin.labelPrefix = ""
 	}
 
-	return writeXMLFile(normalizedPath, feed)
+	layers := make([]Defaults, 0, len(filter.origin.defaults)+1)
+	layers = append(layers, filter.origin.defaults...)
+	layers = append(layers, defaults)
+	return applyDefaults(filter, layers...)
+}
+
+// SaveXML writes the feed to disk and refuses to overwrite files unless force is true
+func SaveXML(filePath string, feed Feed, force bool) error {
+	return SaveFeed(filePath, feed, xmlEncoder{}, force)
+}
+
+// SaveFeed writes the feed to disk with an encoder, adding the encoder's
+// extension to paths without one, and refuses to overwrite files unless
+// force is true
+func SaveFeed(filePath string, feed Feed, encoder Encoder, force bool) error {
+	_, err := SaveFeedWith(filePath, feed, encoder, SaveOptions{Force: force})
+	return err
 }
 
 // ============================================================================
 // Reading and Validation Functions
 // ============================================================================
 
-// validateYAMLExtension checks if the file has a valid YAML extension
-func validateYAMLExtension(filePath string) error {
-	lowerPath := strings.ToLower(filePath)
-	if !strings.HasSuffix(lowerPath, ".yaml") && !strings.HasSuffix(lowerPath, ".yml") {
-		return fmt.Errorf("input file %s must have .yaml or .yml extension", filePath)
-	}
-	return nil
-}
-
 // readFileContent reads the file content
 func readFileContent(filePath string) ([]byte, error) {
 	file, err := os.ReadFile(filePath)
@@ -202,45 +370,45 @@ func readFileContent(filePath string) ([]byte, error) {
 	return file, nil
 }
 
-// parseYAMLContent decodes the YAML content to FiltersConfig structure
-func parseYAMLContent(fileContent []byte) (FiltersConfig, error) {
-	var config FiltersConfig
-	decoder := yaml.NewDecoder(bytes.NewReader(fileContent))
-	decoder.KnownFields(true)
-	if err := decoder.Decode(&config); err != nil {
-		// Try to extract line/column info from yaml.v3 error
-		var typeErr *yaml.TypeError
-		if errors.As(err, &typeErr) {
-			return FiltersConfig{}, fmt.Errorf("YAML validation error: %s", strings.Join(typeErr.Errors, "; "))
-		}
-		return FiltersConfig{}, fmt.Errorf("YAML syntax error: %w", err)
-	}
-	return config, nil
-}
-
-// validateConfiguration validates the complete configuration
+// validateConfiguration validates the complete configuration, joining every
+// problem found as a *ValidationError
 func validateConfiguration(config FiltersConfig) error {
-	if err := validateAuthorData(config.Author); err != nil {
-		return err
-	}
+	errs := validateAuthorData(config)
 
 	if len(config.Filters) == 0 {
-		return fmt.Errorf("at least one filter is required")
+		errs = append(errs, sectionError(config, "filters", "", "", RuleRequired, "at least one filter is required"))
 	}
 
-	return validateAllFilters(config.Filters, config.Defaults)
+	errs = append(errs, validateDefaults(config)...)
+	errs = append(errs, validateLimits(config)...)
+	errs = append(errs, validateAllFilters(config.Filters, config.Defaults)...)
+	errs = append(errs, validatePolicy(config)...)
+
+	return errors.Join(errs...)
 }
 
 // validateAuthorData validates the author data
-func validateAuthorData(author Author) error {
+func validateAuthorData(config FiltersConfig) []error {
+	author := config.Author
 	if strings.TrimSpace(author.Name) == "" {
-		return fmt.Errorf("author name is required")
+		return []error{sectionError(config, "author", "name", author.Name, RuleRequired, "author name is required")}
 	}
 	if strings.TrimSpace(author.Email) == "" {
-		return fmt.Errorf("author email is required")
+		return []error{sectionError(config, "author", "email", author.Email, RuleRequired, "author email is required")}
 	}
 	if !isValidEmail(author.Email) {
-		return fmt.Errorf("author email '%s' is not a valid email address", author.Email)
+		return []error{sectionError(config, "author", "email", author.Email, RuleEmail,
+			fmt.Sprintf("author email '%s' is not a valid email address", author.Email))}
+	}
+	return nil
+}
+
+// validateDefaults validates the string actions of the default block
+func validateDefaults(config FiltersConfig) []error {
+	forwardTo := config.Defaults.ForwardTo
+	if forwardTo != "" && !isValidEmail(forwardTo) {
+		return []error{sectionError(config, "default", "forwardTo", forwardTo, RuleEmail,
+			fmt.Sprintf("default: 'forwardTo' field '%s' is not a valid email address", forwardTo))}
 	}
 	return nil
 }
@@ -255,54 +423,88 @@ func isValidEmail(email string) bool {
 }
 
 // isValidEmailOrDomain validates email or domain-only patterns (e.g., @example.com, *@example.com)
-// Also supports multiple values separated by OR (e.g., "domain1.com OR domain2.com")
-func isValidEmailOrDomain(value string) bool {
-	value = strings.TrimSpace(value)
-	if value == "" {
-		return false
-	}
+// Also supports expressions combining several values, such as "domain1.com OR domain2.com"
+// or the rendered form of structured criteria lists, validating each term individually
+func isValidEmailOrDomain(value Criterion) bool {
+	_, ok := invalidAddressTerm(value)
+	return ok
+}
 
-	// Split by OR to support multiple values
-	parts := strings.Split(value, " OR ")
-	if len(parts) == 1 {
-		// Single value, validate directly
-		return emailRegex.MatchString(value) || domainRegex.MatchString(value)
+// invalidAddressTerm returns the first term that is not an email or domain pattern.
+// It reports false when the expression is malformed or a term is invalid.
+func invalidAddressTerm(value Criterion) (string, bool) {
+	terms, ok := criteriaTerms(string(value))
+	if !ok {
+		return "", false
 	}
 
-	// Multiple values, all must be valid
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		if part == "" || (!emailRegex.MatchString(part) && !domainRegex.MatchString(part)) {
-			return false
+	for _, term := range terms {
+		if !emailRegex.MatchString(term) && !domainRegex.MatchString(term) {
+			return term, false
 		}
 	}
 
-	return true
+	return "", true
+}
+
+// validateAddressCriterion validates an address criterion, naming the offending element
+func validateAddressCriterion(index int, filter Filter, field string, value Criterion) error {
+	if value == "" {
+		return nil
+	}
+
+	term, ok := invalidAddressTerm(value)
+	if ok {
+		return nil
+	}
+	message := fmt.Sprintf("%s: '%s' field '%s' is not a valid email address or domain pattern", filterRef(index, filter), field, value)
+	if term != "" && term != string(value) {
+		message += fmt.Sprintf(" (invalid element '%s')", term)
+	}
+	return filterError(index, filter, field, string(value), RuleAddress, message)
 }
 
 // validateAllFilters validates all filters in the configuration
-func validateAllFilters(filters []Filter, defaults Defaults) error {
+func validateAllFilters(filters []Filter, defaults Defaults) []error {
+	var errs []error
 	for i, filter := range filters {
-		normalized := applyDefaults(filter, defaults)
+		ref := filterRef(i, filter)
+		// Criteria defaults are left out: a filter must select messages on its own
+		normalized := normalizeFilter(filter, defaults)
 		if !hasCriteria(normalized) {
-			return fmt.Errorf("filter %d must define at least one condition", i)
+			errs = append(errs, filterError(i, filter, "", "", RuleCondition, ref+" must define at least one condition"))
 		}
 		if !hasAction(normalized) {
-			return fmt.Errorf("filter %d must define at least one action", i)
+			errs = append(errs, filterError(i, filter, "", "", RuleAction, ref+" must define at least one action"))
 		}
 
 		// Validate email fields if present (supports domain-only patterns like @example.com)
-		if filter.From != "" && !isValidEmailOrDomain(filter.From) {
-			return fmt.Errorf("filter %d: 'from' field '%s' is not a valid email address or domain pattern", i, filter.From)
+		addressCriteria := []struct {
+			field string
+			value Criterion
+		}{
+			{"from", filter.From},
+			{"to", filter.To},
+			{"notFrom", filter.NotFrom},
+			{"notTo", filter.NotTo},
+		}
+		for _, criterion := range addressCriteria {
+			if err := validateAddressCriterion(i, filter, criterion.field, criterion.value); err != nil {
+				errs = append(errs, err)
+			}
 		}
-		if filter.To != "" && !isValidEmailOrDomain(filter.To) {
-			return fmt.Errorf("filter %d: 'to' field '%s' is not a valid email address or domain pattern", i, filter.To)
+		if filter.Size != "" {
+			if _, err := ParseSize(filter.Size); err != nil {
+				errs = append(errs, filterError(i, filter, "size", filter.Size, RuleSize,
+					fmt.Sprintf("%s: 'size' field: %v", ref, err)))
+			}
 		}
-		if filter.ForwardTo != "" && !isValidEmail(filter.ForwardTo) {
-			return fmt.Errorf("filter %d: 'forwardTo' field '%s' is not a valid email address", i, filter.ForwardTo)
+		if normalized.ForwardTo != "" && !isValidEmail(normalized.ForwardTo) {
+			errs = append(errs, filterError(i, filter, "forwardTo", normalized.ForwardTo, RuleEmail,
+				fmt.Sprintf("%s: 'forwardTo' field '%s' is not a valid email address", ref, normalized.ForwardTo)))
 		}
 	}
-	return nil
+	return errs
 }
 
 // ============================================================================
@@ -354,14 +556,20 @@ func buildFilterProperties(filter Filter) []Property {
 	}
 
 	// Filtering criteria
-	addStringProperty("from", filter.From)
-	addStringProperty("to", filter.To)
-	addStringProperty("subject", filter.Subject)
-	addStringProperty("hasTheWord", filter.HasTheWord)
-	addStringProperty("doesNotHaveTheWord", filter.DoesNotHaveTheWord)
-	addStringProperty("list", filter.List)
+	addStringProperty("from", string(filter.From))
+	addStringProperty("to", string(filter.To))
+	addStringProperty("subject", string(filter.Subject))
+	addStringProperty("hasTheWord", string(filter.HasTheWord))
+	addStringProperty("doesNotHaveTheWord", negatedCriteria(filter))
+	addStringProperty("list", string(filter.List))
 	addStringProperty("query", filter.Query)
 	addBoolProperty("hasAttachment", filter.HasAttachment)
+	addBoolProperty("excludeChats", filter.ExcludeChats)
+
+	// Size criterion is exported as three separate properties
+	if size, err := ParseSize(filter.Size); err == nil {
+		props = append(props, size.properties()...)
+	}
 
 	// Filter actions
 	addBoolProperty("shouldArchive", filter.ShouldArchive)
@@ -396,42 +604,62 @@ func validateFileOverwrite(filePath string, force bool) error {
 	return nil
 }
 
-// writeXMLFile serializes and writes the XML feed to disk
-func writeXMLFile(filePath string, feed Feed) error {
+// MarshalFeed serializes the feed into the XML document imported by Gmail
+func MarshalFeed(feed Feed) ([]byte, error) {
 	output, err := xml.MarshalIndent(feed, "", "  ")
 	if err != nil {
-		return fmt.Errorf("generating XML: %w", err)
+		return nil, fmt.Errorf("generating XML: %w", err)
 	}
 
-	finalOutput := []byte(XMLHeader + string(output))
-	return os.WriteFile(filePath, finalOutput, 0o644)
+	return []byte(XMLHeader + string(output)), nil
 }
 
-// ensureXMLExtension ensures the file has .xml extension
-func ensureXMLExtension(filePath string) string {
+// ensureExtension adds the extension to paths without one
+func ensureExtension(filePath, extension string) string {
 	if filepath.Ext(filePath) == "" {
-		filePath += ".xml"
+		filePath += extension
 	}
 	return filePath
 }
 
-// applyDefaults applies default values to the filter
-func applyDefaults(filter Filter, defaults Defaults) Filter {
-	applyDefault := func(target **bool, defaultValue bool) {
-		if *target == nil && defaultValue {
-			trueVal := true
-			*target = &trueVal
+// applyDefaults applies default values to the filter. When several layers
+// are given, earlier layers take precedence over later ones. Filters with
+// inheritDefaults set to false are returned untouched.
+func applyDefaults(filter Filter, layers ...Defaults) Filter {
+	if filter.InheritDefaults != nil && !*filter.InheritDefaults {
+		return filter
+	}
+
+	applyDefault := func(target **bool, defaultValue *bool) {
+		if *target == nil && defaultValue != nil {
+			value := *defaultValue
+			*target = &value
+		}
+	}
+
+	applyStringDefault := func(target *string, defaultValue string) {
+		if *target == "" {
+			*target = defaultValue
 		}
 	}
 
-	applyDefault(&filter.ShouldArchive, defaults.ShouldArchive)
-	applyDefault(&filter.ShouldMarkAsRead, defaults.ShouldMarkAsRead)
-	applyDefault(&filter.ShouldStar, defaults.ShouldStar)
-	applyDefault(&filter.ShouldNeverSpam, defaults.ShouldNeverSpam)
-	applyDefault(&filter.ShouldAlwaysMarkAsImportant, defaults.ShouldAlwaysMarkAsImportant)
-	applyDefault(&filter.ShouldNeverMarkAsImportant, defaults.ShouldNeverMarkAsImportant)
-	applyDefault(&filter.ShouldTrash, defaults.ShouldTrash)
-	applyDefault(&filter.HasAttachment, defaults.HasAttachment)
+	labelPrefix := ""
+	for _, defaults := range layers {
+		applyDefault(&filter.ShouldArchive, defaults.ShouldArchive)
+		applyDefault(&filter.ShouldMarkAsRead, defaults.ShouldMarkAsRead)
+		applyDefault(&filter.ShouldStar, defaults.ShouldStar)
+		applyDefault(&filter.ShouldNeverSpam, defaults.ShouldNeverSpam)
+		applyDefault(&filter.ShouldAlwaysMarkAsImportant, defaults.ShouldAlwaysMarkAsImportant)
+		applyDefault(&filter.ShouldNeverMarkAsImportant, defaults.ShouldNeverMarkAsImportant)
+		applyDefault(&filter.ShouldTrash, defaults.ShouldTrash)
+		applyStringDefault(&filter.SmartLabel, defaults.SmartLabel)
+		applyStringDefault(&filter.ForwardTo, defaults.ForwardTo)
+		applyStringDefault(&labelPrefix, defaults.LabelPrefix)
+	}
+
+	if filter.Label != "" && labelPrefix != "" {
+		filter.Label = joinLabel(labelPrefix, filter.Label)
+	}
 
 	return filter
 }
@@ -441,18 +669,37 @@ func hasCriteria(filter Filter) bool {
 	// Checks string criteria
 	if filter.From != "" || filter.To != "" || filter.Subject != "" ||
 		filter.HasTheWord != "" || filter.DoesNotHaveTheWord != "" ||
-		filter.List != "" || filter.Query != "" {
+		filter.List != "" || filter.Query != "" || filter.Size != "" ||
+		filter.NotFrom != "" || filter.NotTo != "" || filter.NotSubject != "" {
 		return true
 	}
 
-	// Checks boolean criterion (both true and false are valid)
-	if filter.HasAttachment != nil {
+	// Checks boolean criteria (both true and false are valid)
+	if filter.HasAttachment != nil || filter.ExcludeChats != nil {
 		return true
 	}
 
 	return false
 }
 
+// negatedCriteria merges doesNotHaveTheWord with the notFrom/notTo/notSubject criteria
+func negatedCriteria(filter Filter) string {
+	terms := make([]string, 0, 4)
+	if filter.DoesNotHaveTheWord != "" {
+		terms = append(terms, filter.DoesNotHaveTheWord)
+	}
+	if filter.NotFrom != "" {
+		terms = append(terms, "from:("+string(filter.NotFrom)+")")
+	}
+	if filter.NotTo != "" {
+		terms = append(terms, "to:("+string(filter.NotTo)+")")
+	}
+	if filter.NotSubject != "" {
+		terms = append(terms, "subject:("+string(filter.NotSubject)+")")
+	}
+	return strings.Join(terms, " ")
+}
+
 // hasAction checks if the filter has at least one action defined
 func hasAction(filter Filter) bool {
 	// Checks string actions
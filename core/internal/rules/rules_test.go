@@ -44,7 +44,7 @@ func TestLoadConfig_InvalidExtension(t *testing.T) {
 	defer testutils.CleanupFile(tmpFile)
 
 	_, err := LoadConfig(tmpFile)
-	if err == nil || !strings.Contains(err.Error(), "must have .yaml or .yml extension") {
+	if err == nil || !strings.Contains(err.Error(), "must have .yaml, .yml, .json or .toml extension") {
 		t.Errorf("Expected extension error, got: %v", err)
 	}
 }
@@ -113,9 +113,9 @@ func TestGenerateFeed_DefaultBooleans(t *testing.T) {
 			Email: "test@example.com",
 		},
 		Defaults: Defaults{
-			ShouldArchive:    true,
-			ShouldMarkAsRead: false,
-			ShouldStar:       true,
+			ShouldArchive:    testutils.BoolPtr(true),
+			ShouldMarkAsRead: testutils.BoolPtr(false),
+			ShouldStar:       testutils.BoolPtr(true),
 		},
 		Filters: []Filter{
 			{
@@ -135,8 +135,11 @@ func TestGenerateFeed_DefaultBooleans(t *testing.T) {
 	if !hasProperty(props, "shouldStar", "true") {
 		t.Fatalf("Expected shouldStar property with value true")
 	}
-	if hasProperty(props, "shouldMarkAsRead", "false") {
-		t.Fatalf("Did not expect shouldMarkAsRead property when default is false")
+	if !hasProperty(props, "shouldMarkAsRead", "false") {
+		t.Fatalf("Expected shouldMarkAsRead property with value false when default is explicitly false")
+	}
+	if hasPropertyName(props, "shouldTrash") {
+		t.Fatalf("Did not expect shouldTrash property when no default is configured")
 	}
 }
 
@@ -147,7 +150,7 @@ func TestGenerateFeed_ExplicitFalsePreserved(t *testing.T) {
 			Email: "test@example.com",
 		},
 		Defaults: Defaults{
-			ShouldArchive: true,
+			ShouldArchive: testutils.BoolPtr(true),
 		},
 		Filters: []Filter{
 			{
@@ -208,6 +211,9 @@ func TestHasCriteria(t *testing.T) {
 		{"Has from", Filter{From: "test@example.com"}, true},
 		{"Has subject", Filter{Subject: "Test"}, true},
 		{"Has attachment", Filter{HasAttachment: testutils.BoolPtr(true)}, true},
+		{"Has size", Filter{Size: ">5MB"}, true},
+		{"Has excludeChats", Filter{ExcludeChats: testutils.BoolPtr(true)}, true},
+		{"Has notFrom", Filter{NotFrom: "@example.com"}, true},
 	}
 
 	for _, tt := range tests {
@@ -248,8 +254,8 @@ func TestGenerateFeed_HasAttachmentDefaults(t *testing.T) {
 			Name:  "Test User",
 			Email: "test@example.com",
 		},
-		Defaults: Defaults{
-			HasAttachment: true,
+		DefaultCriteria: CriteriaDefaults{
+			HasAttachment: testutils.BoolPtr(true),
 		},
 		Filters: []Filter{
 			{
@@ -274,8 +280,8 @@ func TestGenerateFeed_HasAttachmentExplicit(t *testing.T) {
 			Name:  "Test User",
 			Email: "test@example.com",
 		},
-		Defaults: Defaults{
-			HasAttachment: true,
+		DefaultCriteria: CriteriaDefaults{
+			HasAttachment: testutils.BoolPtr(true),
 		},
 		Filters: []Filter{
 			{
@@ -302,7 +308,7 @@ func TestGenerateFeed_HasAttachmentNotInDefaults(t *testing.T) {
 			Email: "test@example.com",
 		},
 		Defaults: Defaults{
-			ShouldArchive: true,
+			ShouldArchive: testutils.BoolPtr(true),
 		},
 		Filters: []Filter{
 			{
@@ -414,6 +420,23 @@ filters:
 	}
 }
 
+func TestLoadConfig_InvalidSize(t *testing.T) {
+	content := `author:
+  name: "Test User"
+  email: "valid@example.com"
+filters:
+  - size: "huge"
+    label: "Test"
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+
+	_, err := LoadConfig(tmpFile)
+	if err == nil || !strings.Contains(err.Error(), "'size' field") {
+		t.Errorf("Expected size validation error, got: %v", err)
+	}
+}
+
 func TestLoadConfig_DomainOnlyFromPattern(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -562,3 +585,147 @@ func hasProperty(props []Property, name, value string) bool {
 	}
 	return false
 }
+
+func hasPropertyName(props []Property, name string) bool {
+	for _, p := range props {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// testFeed generates the feed of a test author's filters at a fixed date,
+// one archiving filter by default. Criteria are never split, so tests see
+// one entry per filter.
+func testFeed(filters ...Filter) Feed {
+	if len(filters) == 0 {
+		filters = []Filter{{From: "a@example.com", Label: "A", ShouldArchive: testutils.BoolPtr(true)}}
+	}
+	config := FiltersConfig{
+		Author:  Author{Name: "Test User", Email: "test@example.com"},
+		Limits:  Limits{MaxCriteriaLength: -1},
+		Filters: filters,
+	}
+	return GenerateFeed(config, time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC))
+}
+
+func TestGenerateFeed_StringDefaults(t *testing.T) {
+	config := FiltersConfig{
+		Author: Author{Name: "Test User", Email: "test@example.com"},
+		Defaults: Defaults{
+			LabelPrefix: "@Me",
+			SmartLabel:  "^smartlabel_personal",
+			ForwardTo:   "archive@example.com",
+		},
+		Filters: []Filter{
+			{From: "a@example.com", Label: "Friends"},
+			{From: "b@example.com", Label: "Shop", SmartLabel: "^smartlabel_promo", ForwardTo: "shop@example.com"},
+		},
+	}
+
+	feed := GenerateFeed(config, time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC))
+
+	first := feed.Entries[0].Properties
+	for name, value := range map[string]string{"label": "@Me/Friends", "smartLabelToApply": "^smartlabel_personal", "forwardTo": "archive@example.com"} {
+		if !hasProperty(first, name, value) {
+			t.Errorf("Expected %s=%s from defaults, got %+v", name, value, first)
+		}
+	}
+
+	second := feed.Entries[1].Properties
+	for name, value := range map[string]string{"label": "@Me/Shop", "smartLabelToApply": "^smartlabel_promo", "forwardTo": "shop@example.com"} {
+		if !hasProperty(second, name, value) {
+			t.Errorf("Expected explicit %s=%s to win, got %+v", name, value, second)
+		}
+	}
+}
+
+func TestGenerateFeed_InheritDefaultsFalse(t *testing.T) {
+	config := FiltersConfig{
+		Author: Author{Name: "Test User", Email: "test@example.com"},
+		Defaults: Defaults{
+			ShouldArchive: testutils.BoolPtr(true),
+			LabelPrefix:   "@Me",
+		},
+		Filters: []Filter{
+			{From: "a@example.com", Label: "Inbox", InheritDefaults: testutils.BoolPtr(false)},
+		},
+	}
+
+	feed := GenerateFeed(config, time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC))
+	props := feed.Entries[0].Properties
+
+	if hasPropertyName(props, "shouldArchive") || !hasProperty(props, "label", "Inbox") {
+		t.Errorf("Expected filter to opt out of defaults, got %+v", props)
+	}
+}
+
+func TestLoadConfig_GroupDefaultForcesFalse(t *testing.T) {
+	content := `author:
+  name: "Test User"
+  email: "test@example.com"
+default:
+  shouldArchive: true
+groups:
+  - name: finance
+    default:
+      shouldArchive: false
+    filters:
+      - subject: "Invoice"
+        label: "Finance"
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+
+	config, err := LoadConfig(tmpFile)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	feed := GenerateFeed(config, time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC))
+	if !hasProperty(feed.Entries[0].Properties, "shouldArchive", "false") {
+		t.Errorf("Expected group default false to override global true, got %+v", feed.Entries[0].Properties)
+	}
+}
+
+func TestLoadConfig_InvalidDefaultForwardTo(t *testing.T) {
+	content := `author:
+  name: "Test User"
+  email: "test@example.com"
+default:
+  forwardTo: "not-an-email"
+filters:
+  - from: "a@example.com"
+    label: "Test"
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+
+	_, err := LoadConfig(tmpFile)
+	if err == nil || !strings.Contains(err.Error(), "default: 'forwardTo' field") {
+		t.Errorf("Expected default forwardTo validation error, got: %v", err)
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	content := []byte(`author:
+  name: "Test User"
+  email: "test@example.com"
+filters:
+  - from: "a@example.com"
+    label: "A"
+`)
+
+	config, err := ParseConfig(content)
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+	if len(config.Filters) != 1 || config.Filters[0].Label != "A" {
+		t.Errorf("Unexpected config: %+v", config)
+	}
+
+	if _, err := ParseConfig([]byte("author: [")); err == nil || !strings.Contains(err.Error(), "YAML syntax error") {
+		t.Errorf("Expected syntax error, got: %v", err)
+	}
+}
@@ -0,0 +1,375 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ============================================================================
+// JSON Schema
+// ============================================================================
+
+// SchemaDraft is the JSON Schema dialect of the generated schema. Draft-07 is
+// the most widely supported one among editors and CI validators.
+const SchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// SmartLabels lists the smartLabel values Gmail accepts
+var SmartLabels = []string{
+	"^smartlabel_personal",
+	"^smartlabel_social",
+	"^smartlabel_promo",
+	"^smartlabel_notification",
+	"^smartlabel_group",
+}
+
+// SmartLabelNames maps smartLabel values to the Gmail category they apply
+var SmartLabelNames = map[string]string{
+	"^smartlabel_personal":     "Primary",
+	"^smartlabel_social":       "Social",
+	"^smartlabel_promo":        "Promotions",
+	"^smartlabel_notification": "Updates",
+	"^smartlabel_group":        "Forums",
+}
+
+// schemaDescriptions documents every configuration key, indexed by Go type
+// name and YAML key
+var schemaDescriptions = map[string]string{
+	"FiltersConfig":                 "grc configuration describing Gmail filters",
+	"FiltersConfig.vars":            "Variables referenced as ${name} in string fields",
+	"FiltersConfig.secrets":         "YAML or JSON file, plain or encrypted with sops or age, holding the values of ${secret:key} references",
+	"FiltersConfig.author":          "Author of the exported filters",
+	"FiltersConfig.default":         "Actions applied to filters that do not set them",
+	"FiltersConfig.defaultCriteria": "Criteria added to filters that do not set them",
+	"FiltersConfig.templates":       "Reusable filter fragments, merged into filters with use",
+	"FiltersConfig.limits":          "Gmail account limits checked against the generated feed",
+	"FiltersConfig.policy":          "Rules every forwarding filter must follow",
+	"FiltersConfig.filters":         "Gmail filters",
+	"FiltersConfig.groups":          "Groups of filters sharing defaults and a label prefix",
+
+	"Author":       "Author block of the Gmail export",
+	"Author.name":  "Author name",
+	"Author.email": "Author email address",
+
+	"Defaults":                             "Default actions; omitted keys leave filters untouched",
+	"Defaults.shouldArchive":               "Skip the inbox",
+	"Defaults.shouldMarkAsRead":            "Mark as read",
+	"Defaults.shouldStar":                  "Star the message",
+	"Defaults.shouldNeverSpam":             "Never send to spam",
+	"Defaults.shouldAlwaysMarkAsImportant": "Always mark as important",
+	"Defaults.shouldNeverMarkAsImportant":  "Never mark as important",
+	"Defaults.shouldTrash":                 "Delete the message",
+	"Defaults.hasAttachment":               "Deprecated: use defaultCriteria.hasAttachment",
+	"Defaults.labelPrefix":                 "Prefix prepended to every label",
+	"Defaults.smartLabel":                  "Gmail category applied to filters without one",
+	"Defaults.forwardTo":                   "Address matching messages are forwarded to",
+
+	"CriteriaDefaults":               "Default criteria; they narrow what every filter matches",
+	"CriteriaDefaults.hasAttachment": "Match messages with (true) or without (false) attachments",
+	"CriteriaDefaults.excludeChats":  "Leave chat messages out of the match",
+
+	"Limits":                        "Gmail account limits; 0 uses the default, a negative value disables the check",
+	"Limits.maxCriteriaLength":      "Longest criteria string; longer OR-lists are split into several entries",
+	"Limits.maxFilters":             "Maximum number of generated entries",
+	"Limits.maxXMLBytes":            "Maximum size of the generated XML document",
+	"Limits.maxForwardingAddresses": "Maximum number of distinct forwardTo addresses",
+	"Limits.onExceed":               "Whether exceeded limits warn or fail the run",
+
+	"Filter":                             "Gmail filter; needs at least one criterion and one action",
+	"Filter.use":                         "Templates merged into the filter, in order",
+	"Filter.inheritDefaults":             "Set to false to skip the global and group defaults",
+	"Filter.from":                        "Sender address or domain",
+	"Filter.to":                          "Recipient address or domain",
+	"Filter.subject":                     "Words in the subject",
+	"Filter.hasTheWord":                  "Words anywhere in the message",
+	"Filter.doesNotHaveTheWord":          "Words the message must not contain",
+	"Filter.list":                        "Mailing list identifier",
+	"Filter.query":                       "Raw Gmail search query",
+	"Filter.hasAttachment":               "Match messages with (true) or without (false) attachments",
+	"Filter.excludeChats":                "Leave chat messages out of the match",
+	"Filter.size":                        "Message size, e.g. \">5MB\" or \"<100KB\"",
+	"Filter.notFrom":                     "Senders to exclude",
+	"Filter.notTo":                       "Recipients to exclude",
+	"Filter.notSubject":                  "Subjects to exclude",
+	"Filter.label":                       "Label applied to matching messages",
+	"Filter.smartLabel":                  "Gmail category applied to matching messages",
+	"Filter.forwardTo":                   "Address matching messages are forwarded to",
+	"Filter.shouldArchive":               "Skip the inbox",
+	"Filter.shouldMarkAsRead":            "Mark as read",
+	"Filter.shouldStar":                  "Star the message",
+	"Filter.shouldNeverSpam":             "Never send to spam",
+	"Filter.shouldAlwaysMarkAsImportant": "Always mark as important",
+	"Filter.shouldNeverMarkAsImportant":  "Never mark as important",
+	"Filter.shouldTrash":                 "Delete the message",
+
+	"Policy":                         "Forwarding policy; violations fail validation",
+	"Policy.allowedForwardDomains":   "Only domains, and their subdomains, forwardTo may point to",
+	"Policy.forbiddenForwardDomains": "Domains forwardTo must never point to",
+	"Policy.requireForwardLabel":     "Forwarding filters must apply a label",
+	"Policy.forbidForwardAndTrash":   "Forwarding filters must not trash the message",
+
+	"FilterGroup":             "Filters sharing defaults and a label prefix",
+	"FilterGroup.name":        "Group name used in error messages",
+	"FilterGroup.default":     "Actions applied to the group's filters that do not set them",
+	"FilterGroup.labelPrefix": "Prefix prepended to the labels of the group's filters",
+	"FilterGroup.filters":     "Filters of the group",
+	"FilterGroup.groups":      "Nested groups",
+}
+
+// schemaAddress accepts an email address or a whole ${name} reference,
+// which is only checked once interpolated
+var schemaAddress = map[string]any{
+	"anyOf": []any{
+		map[string]any{"format": "email"},
+		map[string]any{"pattern": `^\$\{[^}]+\}$`},
+	},
+}
+
+// schemaFieldOverrides adds constraints the Go types cannot express
+var schemaFieldOverrides = map[string]map[string]any{
+	"Author.email":        schemaAddress,
+	"Defaults.forwardTo":  schemaAddress,
+	"Defaults.smartLabel": {"enum": SmartLabels},
+	"Filter.forwardTo":    schemaAddress,
+	"Filter.smartLabel":   {"enum": SmartLabels},
+	"Filter.size":         {"pattern": `^[<>]\s*\d+\s*([bB]|[kK][bB]|[mM][bB])?$`},
+	"Limits.onExceed":     {"enum": []string{LimitsWarn, LimitsFail}},
+	"FiltersConfig.vars":  {"propertyNames": map[string]any{"pattern": varNameRegex.String()}},
+}
+
+// schemaRequired lists the keys every instance of a type must set
+var schemaRequired = map[string][]string{
+	"FiltersConfig": {"author"},
+	"Author":        {"name", "email"},
+}
+
+// JSONSchema returns the JSON Schema of the YAML configuration, indented and
+// ready to be written to a file
+func JSONSchema() ([]byte, error) {
+	data, err := json.MarshalIndent(GenerateSchema(), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encoding JSON schema: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// GenerateSchema builds the JSON Schema of FiltersConfig from the
+// configuration types
+func GenerateSchema() map[string]any {
+	generator := &schemaGenerator{definitions: map[string]any{
+		"criterion": criterionSchema(),
+	}}
+
+	root := generator.structSchema(reflect.TypeOf(FiltersConfig{}))
+	root["$schema"] = SchemaDraft
+	root["title"] = "grc configuration"
+
+	// Templates are filter fragments: they share the filter keys but none of
+	// the filter rules
+	filter := generator.definitions["filter"].(map[string]any)
+	template := make(map[string]any, len(filter))
+	for key, value := range filter {
+		template[key] = value
+	}
+	template["description"] = "Filter fragment merged into filters with use"
+	generator.definitions["filterTemplate"] = template
+	generator.definitions["filter"] = map[string]any{
+		"description": filter["description"],
+		"allOf": []any{
+			map[string]any{"$ref": "#/definitions/filterTemplate"},
+			requireAnyOf(append(criteriaFields, "use")),
+		},
+	}
+	properties := root["properties"].(map[string]any)
+	properties["templates"].(map[string]any)["additionalProperties"] = map[string]any{"$ref": "#/definitions/filterTemplate"}
+
+	// Without global defaults, top-level filters must define their own action
+	root["if"] = map[string]any{"not": map[string]any{"required": []string{"default"}}}
+	root["then"] = map[string]any{"properties": map[string]any{
+		"filters": map[string]any{"items": requireAnyOf(append(actionFields, "use"))},
+	}}
+
+	root["definitions"] = generator.definitions
+	return root
+}
+
+// schemaGenerator turns Go types into JSON Schema definitions
+type schemaGenerator struct {
+	definitions map[string]any
+}
+
+// typeSchema returns the schema of a Go type, registering struct definitions
+func (g *schemaGenerator) typeSchema(t reflect.Type) map[string]any {
+	switch t {
+	case reflect.TypeOf(Criterion("")):
+		return map[string]any{"$ref": "#/definitions/criterion"}
+	case reflect.TypeOf(StringList{}):
+		return map[string]any{"anyOf": []any{
+			map[string]any{"type": "string"},
+			map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		}}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.typeSchema(t.Elem())
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Int, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": g.typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.typeSchema(t.Elem())}
+	case reflect.Struct:
+		name := definitionName(t)
+		if _, ok := g.definitions[name]; !ok {
+			g.definitions[name] = nil // placeholder for recursive types
+			g.definitions[name] = g.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/definitions/" + name}
+	default:
+		panic(fmt.Sprintf("rules: no JSON schema for type %s", t))
+	}
+}
+
+// structSchema describes a struct through its YAML keys
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := yamlFieldName(field)
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+
+		key := t.Name() + "." + name
+		property := g.typeSchema(field.Type)
+		if description, ok := schemaDescriptions[key]; ok {
+			property["description"] = description
+		}
+		for constraint, value := range schemaFieldOverrides[key] {
+			property[constraint] = value
+		}
+		properties[name] = property
+	}
+
+	schema := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if description, ok := schemaDescriptions[t.Name()]; ok {
+		schema["description"] = description
+	}
+	if required, ok := schemaRequired[t.Name()]; ok {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// definitionName returns the definition name of a struct type, e.g. filterGroup
+func definitionName(t reflect.Type) string {
+	name := t.Name()
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// criterionSchema describes structured criteria: a string, a list of
+// alternatives or a nested any/all/none mapping
+func criterionSchema() map[string]any {
+	ref := map[string]any{"$ref": "#/definitions/criterion"}
+	return map[string]any{
+		"description": "Gmail search criterion: a string, a list of alternatives or an any/all/none mapping",
+		"anyOf": []any{
+			map[string]any{"type": "string", "minLength": 1},
+			map[string]any{"type": "array", "items": ref, "minItems": 1},
+			map[string]any{
+				"type":                 "object",
+				"properties":           map[string]any{"any": ref, "all": ref, "none": ref},
+				"additionalProperties": false,
+				"minProperties":        1,
+			},
+		},
+	}
+}
+
+// requireAnyOf requires at least one of the given keys
+func requireAnyOf(keys []string) map[string]any {
+	alternatives := make([]any, 0, len(keys))
+	for _, key := range keys {
+		alternatives = append(alternatives, map[string]any{"required": []string{key}})
+	}
+	return map[string]any{"anyOf": alternatives}
+}
+
+// FieldDoc documents a configuration key
+type FieldDoc struct {
+	Key         string
+	Type        string // boolean, string, integer, criterion, list or mapping
+	Description string
+}
+
+// configSections maps the section names accepted by FieldDocs to their types
+var configSections = map[string]reflect.Type{
+	"config":          reflect.TypeOf(FiltersConfig{}),
+	"author":          reflect.TypeOf(Author{}),
+	"default":         reflect.TypeOf(Defaults{}),
+	"defaultCriteria": reflect.TypeOf(CriteriaDefaults{}),
+	"limits":          reflect.TypeOf(Limits{}),
+	"policy":          reflect.TypeOf(Policy{}),
+	"filter":          reflect.TypeOf(Filter{}),
+	"group":           reflect.TypeOf(FilterGroup{}),
+}
+
+// FieldDocs lists the keys of a configuration section in declaration order.
+// Section is one of config, author, default, defaultCriteria, limits, policy,
+// filter or group; unknown sections have no keys.
+func FieldDocs(section string) []FieldDoc {
+	t, ok := configSections[section]
+	if !ok {
+		return nil
+	}
+
+	docs := make([]FieldDoc, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := yamlFieldName(field)
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		docs = append(docs, FieldDoc{
+			Key:         name,
+			Type:        fieldDocType(field.Type),
+			Description: schemaDescriptions[t.Name()+"."+name],
+		})
+	}
+	return docs
+}
+
+// fieldDocType names the kind of value a key accepts
+func fieldDocType(t reflect.Type) string {
+	switch t {
+	case reflect.TypeOf(Criterion("")):
+		return "criterion"
+	case reflect.TypeOf(StringList{}):
+		return "string or list"
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return fieldDocType(t.Elem())
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int64:
+		return "integer"
+	case reflect.Slice:
+		return "list"
+	case reflect.Map, reflect.Struct:
+		return "mapping"
+	default:
+		return "string"
+	}
+}
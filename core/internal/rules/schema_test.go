@@ -0,0 +1,118 @@
+package rules
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestJSONSchema_IsValidJSON(t *testing.T) {
+	data, err := JSONSchema()
+	if err != nil {
+		t.Fatalf("JSONSchema failed: %v", err)
+	}
+
+	var schema map[string]any
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("Expected valid JSON, got: %v", err)
+	}
+	if schema["$schema"] != SchemaDraft {
+		t.Errorf("Expected $schema %s, got %v", SchemaDraft, schema["$schema"])
+	}
+}
+
+func TestGenerateSchema_DescribesEveryKey(t *testing.T) {
+	schema := GenerateSchema()
+	definitions := schema["definitions"].(map[string]any)
+
+	objects := map[string]map[string]any{"root": schema}
+	for name, definition := range definitions {
+		if object, ok := definition.(map[string]any); ok && object["properties"] != nil {
+			objects[name] = object
+		}
+	}
+
+	for name, object := range objects {
+		for key, property := range object["properties"].(map[string]any) {
+			if property.(map[string]any)["description"] == nil {
+				t.Errorf("Expected description for %s.%s", name, key)
+			}
+		}
+	}
+}
+
+func TestGenerateSchema_FilterRules(t *testing.T) {
+	schema := GenerateSchema()
+	definitions := schema["definitions"].(map[string]any)
+
+	template := definitions["filterTemplate"].(map[string]any)
+	properties := template["properties"].(map[string]any)
+	if !reflect.DeepEqual(properties["smartLabel"].(map[string]any)["enum"], SmartLabels) {
+		t.Errorf("Expected smartLabel enum, got %v", properties["smartLabel"])
+	}
+	if !reflect.DeepEqual(properties["forwardTo"].(map[string]any)["anyOf"], schemaAddress["anyOf"]) {
+		t.Errorf("Expected forwardTo to take an email or a variable reference, got %v", properties["forwardTo"])
+	}
+	author := definitions["author"].(map[string]any)["properties"].(map[string]any)
+	if !reflect.DeepEqual(author["email"].(map[string]any)["anyOf"], schemaAddress["anyOf"]) {
+		t.Errorf("Expected author email to take an email or a variable reference, got %v", author["email"])
+	}
+	reference := regexp.MustCompile(schemaAddress["anyOf"].([]any)[1].(map[string]any)["pattern"].(string))
+	if !reference.MatchString("${secret:author.email}") || reference.MatchString("me@${domain}") {
+		t.Errorf("Expected the reference pattern to match whole references only")
+	}
+	if properties["from"].(map[string]any)["$ref"] != "#/definitions/criterion" {
+		t.Errorf("Expected from to reference the criterion definition, got %v", properties["from"])
+	}
+
+	filter := definitions["filter"].(map[string]any)
+	criteria := filter["allOf"].([]any)[1].(map[string]any)["anyOf"].([]any)
+	if len(criteria) != len(criteriaFields)+1 {
+		t.Errorf("Expected one alternative per criterion plus use, got %d", len(criteria))
+	}
+
+	then := schema["then"].(map[string]any)["properties"].(map[string]any)["filters"].(map[string]any)
+	actions := then["items"].(map[string]any)["anyOf"].([]any)
+	if len(actions) != len(actionFields)+1 {
+		t.Errorf("Expected one alternative per action plus use, got %d", len(actions))
+	}
+}
+
+func TestSchemaFieldLists_MatchFilter(t *testing.T) {
+	fields := map[string]bool{}
+	filterType := reflect.TypeOf(Filter{})
+	for i := 0; i < filterType.NumField(); i++ {
+		fields[yamlFieldName(filterType.Field(i))] = true
+	}
+
+	for _, name := range append(append([]string{}, criteriaFields...), actionFields...) {
+		if !fields[name] {
+			t.Errorf("Field list entry %q is not a Filter key", name)
+		}
+	}
+}
+
+func TestFieldDocs(t *testing.T) {
+	docs := FieldDocs("filter")
+	if len(docs) == 0 || docs[0].Key != "use" {
+		t.Fatalf("Expected filter keys in declaration order, got %+v", docs)
+	}
+
+	found := false
+	for _, doc := range docs {
+		if doc.Key == "shouldArchive" {
+			found = true
+			if doc.Type != "boolean" || doc.Description != "Skip the inbox" {
+				t.Errorf("Unexpected shouldArchive doc: %+v", doc)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Expected shouldArchive among filter keys")
+	}
+
+	if FieldDocs("unknown") != nil {
+		t.Errorf("Expected no keys for unknown section")
+	}
+}
@@ -0,0 +1,204 @@
+package rules
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ============================================================================
+// External References and Secrets
+// ============================================================================
+
+// Kinds of ${kind:key} references reading values from outside the configuration
+const (
+	ReferenceEnv    = "env"
+	ReferenceFile   = "file"
+	ReferenceSecret = "secret"
+)
+
+// ageHeaders start the binary and armored forms of age encrypted files
+var ageHeaders = [][]byte{
+	[]byte("age-encryption.org/v1"),
+	[]byte("-----BEGIN AGE ENCRYPTED FILE-----"),
+}
+
+// runDecrypt runs a decryption command and returns its standard output.
+// Tests replace it to avoid depending on sops and age.
+var runDecrypt = func(name string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	command := exec.Command(name, args...)
+	command.Stderr = &stderr
+	output, err := command.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("%s: %w: %s", name, err, message)
+		}
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return output, nil
+}
+
+// SensitiveValues returns the values a prepared configuration read through
+// ${env:}, ${file:} and ${secret:} references, for redaction
+func SensitiveValues(config FiltersConfig) []string {
+	return append([]string(nil), config.sensitive...)
+}
+
+// referenceResolver resolves external references, loading the secrets file
+// on first use and recording every value it returns
+type referenceResolver struct {
+	config     FiltersConfig
+	secrets    map[string]string
+	secretsErr error // failure loading the secrets file, kept so it is not retried
+	values     []string
+}
+
+// resolve returns the value of a ${kind:key} reference
+func (r *referenceResolver) resolve(kind, key string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("empty %s reference", kind)
+	}
+
+	var value string
+	switch kind {
+	case ReferenceEnv:
+		var ok bool
+		if value, ok = os.LookupEnv(key); !ok {
+			return "", fmt.Errorf("environment variable '%s' is not set", key)
+		}
+	case ReferenceFile:
+		content, err := os.ReadFile(r.path(key))
+		if err != nil {
+			return "", fmt.Errorf("reading file reference: %w", err)
+		}
+		value = strings.TrimRight(string(content), "\r\n")
+	case ReferenceSecret:
+		if r.secrets == nil && r.secretsErr == nil {
+			r.secrets, r.secretsErr = r.loadSecrets()
+		}
+		if r.secretsErr != nil {
+			return "", r.secretsErr
+		}
+		var ok bool
+		if value, ok = r.secrets[key]; !ok {
+			return "", fmt.Errorf("secret '%s' is not defined in %s", key, r.config.Secrets)
+		}
+	default:
+		return "", fmt.Errorf("unknown reference kind '%s' (use %s, %s or %s)", kind, ReferenceEnv, ReferenceFile, ReferenceSecret)
+	}
+
+	r.values = append(r.values, value)
+	return value, nil
+}
+
+// path resolves a file path relative to the configuration file, expanding ~/
+func (r *referenceResolver) path(name string) string {
+	if rest, ok := strings.CutPrefix(name, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	if filepath.IsAbs(name) || r.config.baseDir == "" {
+		return name
+	}
+	return filepath.Join(r.config.baseDir, name)
+}
+
+// loadSecrets reads the secrets file of the configuration
+func (r *referenceResolver) loadSecrets() (map[string]string, error) {
+	if r.config.Secrets == "" {
+		return nil, errors.New("secret references need a secrets file (set secrets)")
+	}
+	secrets, err := ReadSecretsFile(r.path(r.config.Secrets))
+	if err != nil {
+		return nil, fmt.Errorf("secrets file %s: %w", r.config.Secrets, err)
+	}
+	return secrets, nil
+}
+
+// ReadSecretsFile reads a YAML or JSON mapping of secrets, flattening nested
+// keys into dotted names. Files encrypted with sops are decrypted with
+// "sops --decrypt" and age files with "age --decrypt", using the identity in
+// SOPS_AGE_KEY_FILE or ~/.config/sops/age/keys.txt; plain files are read as
+// they are and should be kept out of version control.
+func ReadSecretsFile(filePath string) (map[string]string, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("reading file: %w", err)
+	}
+
+	if isAgeEncrypted(content) {
+		if content, err = runDecrypt("age", "--decrypt", "--identity", ageIdentityFile(), filePath); err != nil {
+			return nil, fmt.Errorf("decrypting: %w", err)
+		}
+	}
+
+	var document map[string]any
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("parsing: %w", err)
+	}
+	if _, encrypted := document["sops"]; encrypted {
+		if content, err = runDecrypt("sops", "--decrypt", filePath); err != nil {
+			return nil, fmt.Errorf("decrypting: %w", err)
+		}
+		document = nil
+		if err := yaml.Unmarshal(content, &document); err != nil {
+			return nil, fmt.Errorf("parsing decrypted content: %w", err)
+		}
+	}
+
+	secrets := map[string]string{}
+	flattenSecrets(secrets, "", document)
+	return secrets, nil
+}
+
+// flattenSecrets stores the scalar values of a mapping under dotted keys
+func flattenSecrets(secrets map[string]string, prefix string, value any) {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, item := range value {
+			name := key
+			if prefix != "" {
+				name = prefix + "." + key
+			}
+			flattenSecrets(secrets, name, item)
+		}
+	case nil:
+	default:
+		if prefix != "" {
+			secrets[prefix] = fmt.Sprint(value)
+		}
+	}
+}
+
+// isAgeEncrypted reports whether content is an age encrypted file
+func isAgeEncrypted(content []byte) bool {
+	for _, header := range ageHeaders {
+		if bytes.HasPrefix(content, header) {
+			return true
+		}
+	}
+	return false
+}
+
+// ageIdentityFile returns the age identity sops uses
+func ageIdentityFile() string {
+	if file := os.Getenv("SOPS_AGE_KEY_FILE"); file != "" {
+		return file
+	}
+	dir, _ := os.UserConfigDir()
+	return filepath.Join(dir, "sops", "age", "keys.txt")
+}
+
+// isExternalReference reports whether a reference name has the form kind:key
+func isExternalReference(name string) bool {
+	kind, _, ok := strings.Cut(name, ":")
+	return ok && (kind == ReferenceEnv || kind == ReferenceFile || kind == ReferenceSecret)
+}
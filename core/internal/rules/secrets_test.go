@@ -0,0 +1,151 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig_ExternalReferences(t *testing.T) {
+	t.Setenv("GRC_TEST_SENDER", "boss@corp.com")
+	dir := t.TempDir()
+	files := map[string]string{
+		"email.txt":    "me@corp.com\n",
+		"secrets.yaml": "forward:\n  personal: me.private@example.com\nauthor: Jane Doe\n",
+		"config.yaml": `secrets: secrets.yaml
+author:
+  name: "${secret:author}"
+  email: "${file:email.txt}"
+filters:
+  - from: "${env:GRC_TEST_SENDER}"
+    label: "Boss"
+    forwardTo: "${secret:forward.personal}"
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	config, err := LoadConfig(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if config.Author.Name != "Jane Doe" || config.Author.Email != "me@corp.com" {
+		t.Errorf("Unexpected author: %+v", config.Author)
+	}
+	filter := config.Filters[0]
+	if filter.From != "boss@corp.com" || filter.ForwardTo != "me.private@example.com" {
+		t.Errorf("Unexpected filter: %+v", filter)
+	}
+
+	sensitive := strings.Join(SensitiveValues(config), ",")
+	for _, value := range []string{"Jane Doe", "me@corp.com", "boss@corp.com", "me.private@example.com"} {
+		if !strings.Contains(sensitive, value) {
+			t.Errorf("Expected %s among the sensitive values, got %s", value, sensitive)
+		}
+	}
+}
+
+func TestLoadConfig_SecretInDefaults(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"secrets.yaml": "forward: me.private@example.com\n",
+		"config.yaml": `secrets: secrets.yaml
+author:
+  name: "Test User"
+  email: "test@example.com"
+default:
+  forwardTo: "${secret:forward}"
+filters:
+  - from: "boss@corp.com"
+    label: "Boss"
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	config, err := LoadConfig(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if filters := NormalizeFilters(config); filters[0].ForwardTo != "me.private@example.com" {
+		t.Errorf("Expected the secret to be forwarded to, got %q", filters[0].ForwardTo)
+	}
+	if sensitive := SensitiveValues(config); len(sensitive) != 1 || sensitive[0] != "me.private@example.com" {
+		t.Errorf("Expected the secret among the sensitive values, got %v", sensitive)
+	}
+}
+
+func TestInterpolateConfig_ReferenceErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		secrets string
+		value   string
+		message string
+	}{
+		{"unset env", "", "${env:GRC_TEST_UNSET_VARIABLE}", "environment variable 'GRC_TEST_UNSET_VARIABLE' is not set"},
+		{"missing file", "", "${file:missing.txt}", "reading file reference"},
+		{"no secrets file", "", "${secret:token}", "secret references need a secrets file"},
+		{"missing secrets file", "secrets.yaml", "${secret:token}", "secrets file secrets.yaml: reading file"},
+		{"unknown kind", "", "${vault:token}", "invalid variable name 'vault:token'"},
+		{"empty key", "", "${env:}", "empty env reference"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := FiltersConfig{
+				Secrets: tt.secrets,
+				baseDir: t.TempDir(),
+				Author:  Author{Name: "Test User", Email: "test@example.com"},
+				Filters: []Filter{{From: "a@example.com", Label: tt.value}},
+			}
+			_, err := interpolateConfig(config)
+			if err == nil || !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("Expected error containing %q, got: %v", tt.message, err)
+			}
+		})
+	}
+}
+
+func TestReadSecretsFile_Encrypted(t *testing.T) {
+	original := runDecrypt
+	defer func() { runDecrypt = original }()
+
+	var commands []string
+	runDecrypt = func(name string, args ...string) ([]byte, error) {
+		commands = append(commands, name+" "+strings.Join(args, " "))
+		return []byte(`{"token": "s3cr3t", "nested": {"port": 25}}`), nil
+	}
+
+	dir := t.TempDir()
+	sopsFile := filepath.Join(dir, "secrets.enc.yaml")
+	ageFile := filepath.Join(dir, "secrets.yaml.age")
+	os.WriteFile(sopsFile, []byte("token: ENC[AES256_GCM,data:abc]\nsops:\n  version: 3.8.1\n"), 0o600)
+	os.WriteFile(ageFile, []byte("age-encryption.org/v1\n-> X25519 abc\n"), 0o600)
+	t.Setenv("SOPS_AGE_KEY_FILE", "/keys/age.txt")
+
+	for _, file := range []string{sopsFile, ageFile} {
+		secrets, err := ReadSecretsFile(file)
+		if err != nil {
+			t.Fatalf("ReadSecretsFile(%s) failed: %v", file, err)
+		}
+		if secrets["token"] != "s3cr3t" || secrets["nested.port"] != "25" {
+			t.Errorf("Unexpected secrets from %s: %v", file, secrets)
+		}
+	}
+
+	want := []string{
+		"sops --decrypt " + sopsFile,
+		"age --decrypt --identity /keys/age.txt " + ageFile,
+	}
+	if strings.Join(commands, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected commands %v, got %v", want, commands)
+	}
+}
@@ -0,0 +1,86 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// sizeRegex matches size criteria like ">5MB", "< 200 KB" or ">1048576"
+var sizeRegex = regexp.MustCompile(`^([<>])\s*(\d+)\s*([a-zA-Z]*)$`)
+
+// Gmail property values for the size operator
+const (
+	sizeOperatorLarger  = "s_sl"
+	sizeOperatorSmaller = "s_ss"
+)
+
+// sizeUnits maps accepted unit suffixes to Gmail property values
+var sizeUnits = map[string]string{
+	"":   "s_sb",
+	"B":  "s_sb",
+	"KB": "s_skb",
+	"MB": "s_smb",
+}
+
+// SizeCriterion is the parsed form of the size criterion
+type SizeCriterion struct {
+	Operator string // s_sl (larger than) or s_ss (smaller than)
+	Value    int
+	Unit     string // s_sb, s_skb or s_smb
+}
+
+// ParseSize parses expressions like ">5MB" or "<100KB" into a SizeCriterion
+func ParseSize(value string) (SizeCriterion, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return SizeCriterion{}, fmt.Errorf("size is empty")
+	}
+
+	matches := sizeRegex.FindStringSubmatch(value)
+	if matches == nil {
+		return SizeCriterion{}, fmt.Errorf("size '%s' must look like '>5MB' or '<100KB'", value)
+	}
+
+	number, err := strconv.Atoi(matches[2])
+	if err != nil || number <= 0 {
+		return SizeCriterion{}, fmt.Errorf("size '%s' must be a positive number", value)
+	}
+
+	unit, ok := sizeUnits[strings.ToUpper(matches[3])]
+	if !ok {
+		return SizeCriterion{}, fmt.Errorf("size '%s' has unknown unit '%s' (use B, KB or MB)", value, matches[3])
+	}
+
+	operator := sizeOperatorLarger
+	if matches[1] == "<" {
+		operator = sizeOperatorSmaller
+	}
+
+	return SizeCriterion{Operator: operator, Value: number, Unit: unit}, nil
+}
+
+// String returns the criterion in the form ParseSize reads, e.g. ">5MB"
+func (s SizeCriterion) String() string {
+	operator := ">"
+	if s.Operator == sizeOperatorSmaller {
+		operator = "<"
+	}
+	unit := ""
+	for suffix, value := range sizeUnits {
+		if value == s.Unit && suffix != "" {
+			unit = suffix
+		}
+	}
+	return operator + strconv.Itoa(s.Value) + unit
+}
+
+// properties returns the size, sizeOperator and sizeUnit Gmail properties
+func (s SizeCriterion) properties() []Property {
+	return []Property{
+		{Name: "size", Value: strconv.Itoa(s.Value)},
+		{Name: "sizeOperator", Value: s.Operator},
+		{Name: "sizeUnit", Value: s.Unit},
+	}
+}
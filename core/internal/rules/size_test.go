@@ -0,0 +1,93 @@
+package rules
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected SizeCriterion
+		wantErr  bool
+	}{
+		{"Larger than megabytes", ">5MB", SizeCriterion{Operator: "s_sl", Value: 5, Unit: "s_smb"}, false},
+		{"Smaller than kilobytes", "<100KB", SizeCriterion{Operator: "s_ss", Value: 100, Unit: "s_skb"}, false},
+		{"Spaces and lowercase unit", "> 2 mb", SizeCriterion{Operator: "s_sl", Value: 2, Unit: "s_smb"}, false},
+		{"Bytes without unit", "<1024", SizeCriterion{Operator: "s_ss", Value: 1024, Unit: "s_sb"}, false},
+		{"Missing operator", "5MB", SizeCriterion{}, true},
+		{"Unknown unit", ">5GB", SizeCriterion{}, true},
+		{"Zero size", ">0MB", SizeCriterion{}, true},
+		{"Empty", "", SizeCriterion{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseSize(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Expected error for size '%s', got %+v", tt.value, result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error for size '%s': %v", tt.value, err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestGenerateFeed_SizeAndExcludeChats(t *testing.T) {
+	excludeChats := true
+	config := FiltersConfig{
+		Author: Author{Name: "Test User", Email: "test@example.com"},
+		Filters: []Filter{
+			{
+				Size:         ">5MB",
+				ExcludeChats: &excludeChats,
+				Label:        "Large",
+			},
+		},
+	}
+
+	feed := GenerateFeed(config, time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC))
+	props := feed.Entries[0].Properties
+
+	expected := map[string]string{
+		"size":         "5",
+		"sizeOperator": "s_sl",
+		"sizeUnit":     "s_smb",
+		"excludeChats": "true",
+	}
+	for name, value := range expected {
+		if !hasProperty(props, name, value) {
+			t.Errorf("Expected %s property with value %s, got %+v", name, value, props)
+		}
+	}
+}
+
+func TestGenerateFeed_NegatedCriteria(t *testing.T) {
+	config := FiltersConfig{
+		Author: Author{Name: "Test User", Email: "test@example.com"},
+		Filters: []Filter{
+			{
+				HasTheWord:         "invoice",
+				DoesNotHaveTheWord: "draft",
+				NotFrom:            "@noreply.com",
+				NotSubject:         "Reminder",
+				Label:              "Invoices",
+			},
+		},
+	}
+
+	feed := GenerateFeed(config, time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC))
+	props := feed.Entries[0].Properties
+
+	if !hasProperty(props, "doesNotHaveTheWord", "draft from:(@noreply.com) subject:(Reminder)") {
+		t.Errorf("Expected negated criteria merged into doesNotHaveTheWord, got %+v", props)
+	}
+}
@@ -0,0 +1,143 @@
+package rules
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ============================================================================
+// Filter Ordering
+// ============================================================================
+
+// Sort keys accepted in SortOptions.By
+const (
+	SortLabel  = "label"
+	SortFrom   = "from"
+	SortAction = "action"
+)
+
+// SortOptions selects the order of filters. The zero value keeps YAML order.
+type SortOptions struct {
+	By    string // SortLabel, SortFrom or SortAction; empty keeps YAML order
+	Group bool   // keep filters sharing a top-level label together
+}
+
+// enabled reports whether filters are reordered at all
+func (o SortOptions) enabled() bool {
+	return o.By != "" || o.Group
+}
+
+// Validate checks the sort key
+func (o SortOptions) Validate() error {
+	switch o.By {
+	case "", SortLabel, SortFrom, SortAction:
+		return nil
+	default:
+		return fmt.Errorf("unknown sort key '%s' (use %s, %s or %s)", o.By, SortLabel, SortFrom, SortAction)
+	}
+}
+
+// actionTypes ranks filters by their most significant action when sorting
+// by action
+var actionTypes = []func(Filter) bool{
+	func(f Filter) bool { return isTrue(f.ShouldTrash) },
+	func(f Filter) bool { return f.ForwardTo != "" },
+	func(f Filter) bool { return isTrue(f.ShouldArchive) },
+	func(f Filter) bool { return f.Label != "" },
+	func(f Filter) bool { return isTrue(f.ShouldStar) },
+	func(f Filter) bool {
+		return isTrue(f.ShouldAlwaysMarkAsImportant) || isTrue(f.ShouldNeverMarkAsImportant)
+	},
+	func(f Filter) bool { return isTrue(f.ShouldMarkAsRead) },
+	func(f Filter) bool { return isTrue(f.ShouldNeverSpam) },
+	func(f Filter) bool { return f.SmartLabel != "" },
+}
+
+// SortFilters returns the filters in the requested order. The sort is
+// stable: filters with equal keys keep their YAML order so diffs stay small.
+func SortFilters(filters []Filter, options SortOptions) []Filter {
+	sorted := make([]Filter, len(filters))
+	copy(sorted, filters)
+	if !options.enabled() {
+		return sorted
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return compareFilters(sorted[i], sorted[j], options) < 0
+	})
+	return sorted
+}
+
+// compareFilters orders two filters, returning a negative number when a
+// comes first
+func compareFilters(a, b Filter, options SortOptions) int {
+	if options.Group {
+		if result := compareOptional(labelRoot(a.Label), labelRoot(b.Label)); result != 0 {
+			return result
+		}
+	}
+
+	switch options.By {
+	case SortLabel:
+		return compareLabels(a.Label, b.Label)
+	case SortFrom:
+		return compareOptional(strings.ToLower(string(a.From)), strings.ToLower(string(b.From)))
+	case SortAction:
+		return actionRank(a) - actionRank(b)
+	default:
+		return 0
+	}
+}
+
+// compareLabels orders labels segment by segment, so a label tree stays
+// together, with unlabeled filters last
+func compareLabels(a, b string) int {
+	if a == "" || b == "" {
+		return compareOptional(a, b)
+	}
+
+	segmentsA := strings.Split(strings.ToLower(a), "/")
+	segmentsB := strings.Split(strings.ToLower(b), "/")
+	for i := 0; i < len(segmentsA) && i < len(segmentsB); i++ {
+		if result := strings.Compare(segmentsA[i], segmentsB[i]); result != 0 {
+			return result
+		}
+	}
+	return len(segmentsA) - len(segmentsB)
+}
+
+// compareOptional compares strings case-insensitively, empty strings last
+func compareOptional(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	default:
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+	}
+}
+
+// labelRoot returns the top-level label of a label path
+func labelRoot(label string) string {
+	root, _, _ := strings.Cut(label, "/")
+	return root
+}
+
+// actionRank returns the position of the filter's most significant action
+func actionRank(filter Filter) int {
+	for i, matches := range actionTypes {
+		if matches(filter) {
+			return i
+		}
+	}
+	return len(actionTypes)
+}
+
+// isTrue reports whether an optional boolean is set to true
+func isTrue(value *bool) bool {
+	return value != nil && *value
+}
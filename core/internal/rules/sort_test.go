@@ -0,0 +1,73 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/carlosrabelo/grc/core/internal/testutils"
+)
+
+func filterLabels(filters []Filter) string {
+	labels := make([]string, 0, len(filters))
+	for _, filter := range filters {
+		labels = append(labels, filter.Label)
+	}
+	return strings.Join(labels, ",")
+}
+
+func TestSortFilters(t *testing.T) {
+	filters := []Filter{
+		{From: "c@example.com", Label: "Work/Reports", ShouldArchive: testutils.BoolPtr(true)},
+		{From: "a@example.com", Label: "Work-Old"},
+		{From: "b@example.com", ShouldTrash: testutils.BoolPtr(true)},
+		{From: "d@example.com", Label: "work"},
+		{From: "a@example.com", Label: "Bills", ForwardTo: "me@example.com"},
+		{Subject: "no sender", Label: "Work/Alerts"},
+	}
+
+	tests := []struct {
+		name     string
+		options  SortOptions
+		expected string
+	}{
+		{"yaml order", SortOptions{}, "Work/Reports,Work-Old,,work,Bills,Work/Alerts"},
+		{"label tree", SortOptions{By: SortLabel}, "Bills,work,Work/Alerts,Work/Reports,Work-Old,"},
+		{"sender is stable", SortOptions{By: SortFrom}, "Work-Old,Bills,,Work/Reports,work,Work/Alerts"},
+		{"action type", SortOptions{By: SortAction}, ",Bills,Work/Reports,Work-Old,work,Work/Alerts"},
+		{"grouped by sender", SortOptions{By: SortFrom, Group: true}, "Bills,Work/Reports,work,Work/Alerts,Work-Old,"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := filterLabels(SortFilters(filters, tt.options)); result != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestSortOptions_Validate(t *testing.T) {
+	if err := (SortOptions{By: SortAction}).Validate(); err != nil {
+		t.Errorf("Expected action to be valid, got: %v", err)
+	}
+	err := SortOptions{By: "size"}.Validate()
+	if err == nil || !strings.Contains(err.Error(), "unknown sort key 'size'") {
+		t.Errorf("Expected unknown sort key error, got: %v", err)
+	}
+}
+
+func TestBuildFeed_Sort(t *testing.T) {
+	config := FiltersConfig{
+		Author: Author{Name: "Test User", Email: "test@example.com"},
+		Filters: []Filter{
+			{From: "b@example.com", Label: "Work"},
+			{From: "a@example.com", Label: "Home"},
+		},
+	}
+
+	feed, _ := BuildFeed(config, time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC), FeedOptions{Sort: SortOptions{By: SortLabel}})
+	if !hasProperty(feed.Entries[0].Properties, "label", "Home") || !hasProperty(feed.Entries[1].Properties, "label", "Work") {
+		t.Errorf("Expected entries sorted by label, got %+v", feed.Entries)
+	}
+}
@@ -0,0 +1,115 @@
+package rules
+
+import "strings"
+
+// SplitResult describes a filter that was split into several entries
+type SplitResult struct {
+	Filter  int      // index of the configured filter once groups are flattened, as in ValidationError
+	Ref     string   // the filter as validation errors name it, e.g. "group 'news' filter 1"
+	Fields  []string // criteria that exceeded the limit
+	Entries int      // number of entries produced
+}
+
+// splittableCriteria lists the criteria whose OR-lists can be distributed across entries
+var splittableCriteria = []struct {
+	name  string
+	field func(*Filter) *Criterion
+}{
+	{"from", func(f *Filter) *Criterion { return &f.From }},
+	{"to", func(f *Filter) *Criterion { return &f.To }},
+	{"subject", func(f *Filter) *Criterion { return &f.Subject }},
+	{"hasTheWord", func(f *Filter) *Criterion { return &f.HasTheWord }},
+	{"list", func(f *Filter) *Criterion { return &f.List }},
+}
+
+// splitFilters splits every filter holding criteria longer than maxLength,
+// reporting each one as validation errors name it. A merged filter is
+// reported as the first filter it was merged from.
+func splitFilters(filters []Filter, maxLength int) ([]Filter, []SplitResult) {
+	if maxLength <= 0 {
+		return filters, nil
+	}
+
+	result := make([]Filter, 0, len(filters))
+	var splits []SplitResult
+	for _, filter := range filters {
+		parts, fields := splitFilter(filter, maxLength)
+		if len(parts) > 1 {
+			ref := filterRef(filter.origin.position, filter)
+			splits = append(splits, SplitResult{Filter: filter.origin.position, Ref: ref, Fields: fields, Entries: len(parts)})
+		}
+		result = append(result, parts...)
+	}
+	return result, splits
+}
+
+// splitFilter splits the oversized OR-lists of a filter into chunks, keeping
+// every other criterion and all actions. Splitting several criteria produces
+// every combination of their chunks, which preserves the filter semantics.
+// Chunks may overlap, so a message can match several entries and get the
+// actions once per entry; filters that forward are never split, since the
+// message would be forwarded again for each entry it matches.
+func splitFilter(filter Filter, maxLength int) ([]Filter, []string) {
+	parts := []Filter{filter}
+	var fields []string
+	if filter.ForwardTo != "" {
+		return parts, fields
+	}
+
+	for _, criterion := range splittableCriteria {
+		value := string(*criterion.field(&filter))
+		if len(value) <= maxLength {
+			continue
+		}
+
+		alternatives, ok := splitTopLevelOr(value)
+		if !ok || len(alternatives) < 2 {
+			continue
+		}
+
+		chunks := chunkAlternatives(alternatives, maxLength)
+		if len(chunks) < 2 {
+			continue
+		}
+		fields = append(fields, criterion.name)
+
+		expanded := make([]Filter, 0, len(parts)*len(chunks))
+		for _, part := range parts {
+			for _, chunk := range chunks {
+				*criterion.field(&part) = Criterion(chunk)
+				expanded = append(expanded, part)
+			}
+		}
+		parts = expanded
+	}
+
+	return parts, fields
+}
+
+// chunkAlternatives packs alternatives into OR-lists no longer than maxLength.
+// An alternative that is longer than maxLength on its own gets its own chunk.
+func chunkAlternatives(alternatives []string, maxLength int) []string {
+	const separator = " OR "
+
+	var chunks []string
+	var current []string
+	currentLength := 0
+	for _, alternative := range alternatives {
+		extra := len(alternative)
+		if len(current) > 0 {
+			extra += len(separator)
+		}
+		if len(current) > 0 && currentLength+extra > maxLength {
+			chunks = append(chunks, strings.Join(current, separator))
+			current = nil
+			currentLength = 0
+			extra = len(alternative)
+		}
+		current = append(current, alternative)
+		currentLength += extra
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, strings.Join(current, separator))
+	}
+	return chunks
+}
@@ -0,0 +1,193 @@
+package rules
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/carlosrabelo/grc/core/internal/testutils"
+)
+
+func TestSplitTopLevelOr(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected []string
+		ok       bool
+	}{
+		{"Single term", "a.com", []string{"a.com"}, true},
+		{"OR list", "a.com OR b.com OR c.com", []string{"a.com", "b.com", "c.com"}, true},
+		{"Grouped alternative", "a.com OR (b.com c.com)", []string{"a.com", "(b.com c.com)"}, true},
+		{"Quoted alternative", `"Big Sale" OR Offer`, []string{`"Big Sale"`, "Offer"}, true},
+		{"Top-level AND", "(a.com OR b.com) -c.com", nil, false},
+		{"Malformed", "a.com OR", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, ok := splitTopLevelOr(tt.value)
+			if ok != tt.ok {
+				t.Fatalf("Expected ok=%v, got %v (%v)", tt.ok, ok, result)
+			}
+			if ok && !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestChunkAlternatives(t *testing.T) {
+	alternatives := []string{"aaaa.com", "bbbb.com", "cccc.com", "dddd.com"}
+
+	chunks := chunkAlternatives(alternatives, 20)
+	expected := []string{"aaaa.com OR bbbb.com", "cccc.com OR dddd.com"}
+	if !reflect.DeepEqual(chunks, expected) {
+		t.Errorf("Expected %v, got %v", expected, chunks)
+	}
+
+	for _, chunk := range chunks {
+		if len(chunk) > 20 {
+			t.Errorf("Chunk %q exceeds the limit", chunk)
+		}
+	}
+}
+
+func TestBuildFeed_SplitsOversizedCriteria(t *testing.T) {
+	senders := make([]string, 0, 10)
+	for i := 0; i < 10; i++ {
+		senders = append(senders, fmt.Sprintf("sender%d@example.com", i))
+	}
+
+	config := FiltersConfig{
+		Author: Author{Name: "Test User", Email: "test@example.com"},
+		Limits: Limits{MaxCriteriaLength: 100},
+		Filters: []Filter{
+			{From: Criterion(strings.Join(senders, " OR ")), Label: "Blocked", ShouldTrash: testutils.BoolPtr(true)},
+			{From: "short@example.com", Label: "Short"},
+		},
+	}
+
+	feed, report := BuildFeed(config, time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC), FeedOptions{})
+
+	if len(report.Splits) != 1 || report.Splits[0].Filter != 0 || report.Splits[0].Fields[0] != "from" {
+		t.Fatalf("Expected filter 0 to be split on 'from', got %+v", report.Splits)
+	}
+	if report.Filters != 2 || report.Entries != len(feed.Entries) || report.Entries != report.Splits[0].Entries+1 {
+		t.Errorf("Unexpected report counts: %+v", report)
+	}
+
+	seen := 0
+	for _, entry := range feed.Entries[:report.Splits[0].Entries] {
+		for _, prop := range entry.Properties {
+			if prop.Name == "from" {
+				if len(prop.Value) > 100 {
+					t.Errorf("Entry criteria exceeds the limit: %q", prop.Value)
+				}
+				seen += len(strings.Split(prop.Value, " OR "))
+			}
+		}
+		if !hasProperty(entry.Properties, "label", "Blocked") || !hasProperty(entry.Properties, "shouldTrash", "true") {
+			t.Errorf("Expected split entries to keep all actions, got %+v", entry.Properties)
+		}
+	}
+	if seen != len(senders) {
+		t.Errorf("Expected all %d senders across split entries, got %d", len(senders), seen)
+	}
+}
+
+func TestBuildFeed_SplitReportsConfiguredIndex(t *testing.T) {
+	senders := make([]string, 0, 10)
+	for i := 0; i < 10; i++ {
+		senders = append(senders, fmt.Sprintf("sender%d@example.com", i))
+	}
+
+	config := FiltersConfig{
+		Author: Author{Name: "Test User", Email: "test@example.com"},
+		Limits: Limits{MaxCriteriaLength: 100},
+		Filters: []Filter{
+			{From: "short@example.com", Label: "Zeta"},
+			{Subject: "invoice", Label: "Beta"},
+			{From: Criterion(strings.Join(senders, " OR ")), Label: "Alpha", ShouldTrash: testutils.BoolPtr(true)},
+		},
+	}
+
+	options := FeedOptions{Optimize: true, Sort: SortOptions{By: SortLabel}}
+	feed, report := BuildFeed(config, time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC), options)
+
+	if !hasProperty(feed.Entries[0].Properties, "label", "Alpha") {
+		t.Fatalf("Expected the split filter to be sorted first, got %+v", feed.Entries[0].Properties)
+	}
+	if len(report.Splits) != 1 || report.Splits[0].Filter != 2 {
+		t.Errorf("Expected configured filter 2 to be reported, got %+v", report.Splits)
+	}
+}
+
+func TestBuildFeed_SplitReportsGroupedFilter(t *testing.T) {
+	senders := make([]string, 0, 10)
+	for i := 0; i < 10; i++ {
+		senders = append(senders, fmt.Sprintf("sender%d@example.com", i))
+	}
+
+	config := FiltersConfig{
+		Author:  Author{Name: "Test User", Email: "test@example.com"},
+		Limits:  Limits{MaxCriteriaLength: 100},
+		Filters: []Filter{{From: "short@example.com", Label: "Short"}},
+		Groups: []FilterGroup{{
+			Name: "news",
+			Filters: []Filter{
+				{Subject: "digest", Label: "Digest"},
+				{From: Criterion(strings.Join(senders, " OR ")), Label: "Blocked"},
+			},
+		}},
+	}
+
+	_, report := BuildFeed(config, time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC), FeedOptions{})
+	if len(report.Splits) != 1 || report.Splits[0].Filter != 2 || report.Splits[0].Ref != "group 'news' filter 1" {
+		t.Errorf("Expected group 'news' filter 1 to be reported, got %+v", report.Splits)
+	}
+}
+
+func TestBuildFeed_ForwardingFiltersAreNotSplit(t *testing.T) {
+	words := make([]string, 0, 20)
+	for i := 0; i < 20; i++ {
+		words = append(words, fmt.Sprintf("invoice%d", i))
+	}
+
+	config := FiltersConfig{
+		Author: Author{Name: "Test User", Email: "test@example.com"},
+		Limits: Limits{MaxCriteriaLength: 50},
+		Filters: []Filter{
+			{Subject: Criterion(strings.Join(words, " OR ")), ForwardTo: "books@example.com"},
+		},
+	}
+
+	feed, report := BuildFeed(config, time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC), FeedOptions{})
+	if len(feed.Entries) != 1 || len(report.Splits) != 0 {
+		t.Fatalf("Expected the forwarding filter to stay one entry, got %d entries and %+v", len(feed.Entries), report.Splits)
+	}
+
+	violations, err := CheckLimits(feed, config.Limits)
+	if err != nil {
+		t.Fatalf("CheckLimits failed: %v", err)
+	}
+	if len(violations) != 1 || violations[0].Limit != "maxCriteriaLength" {
+		t.Errorf("Expected the oversized criteria to be reported, got %+v", violations)
+	}
+}
+
+func TestBuildFeed_SplittingDisabled(t *testing.T) {
+	config := FiltersConfig{
+		Author: Author{Name: "Test User", Email: "test@example.com"},
+		Limits: Limits{MaxCriteriaLength: -1},
+		Filters: []Filter{
+			{From: Criterion(strings.Repeat("a.com OR ", 500) + "b.com"), Label: "Test"},
+		},
+	}
+
+	feed, report := BuildFeed(config, time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC), FeedOptions{})
+	if len(feed.Entries) != 1 || len(report.Splits) != 0 {
+		t.Errorf("Expected splitting to be disabled, got %d entries", len(feed.Entries))
+	}
+}
@@ -0,0 +1,149 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StringList is a list of strings that can be written in YAML as a single
+// string or as a sequence
+type StringList []string
+
+// UnmarshalYAML accepts both "name" and ["name", ...]
+func (l *StringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		var value string
+		if err := node.Decode(&value); err != nil {
+			return err
+		}
+		*l = StringList{value}
+		return nil
+	}
+
+	var values []string
+	if err := node.Decode(&values); err != nil {
+		return err
+	}
+	*l = values
+	return nil
+}
+
+// UnmarshalTOML accepts both "name" and ["name", ...]
+func (l *StringList) UnmarshalTOML(value any) error {
+	switch v := value.(type) {
+	case string:
+		*l = StringList{v}
+		return nil
+	case []any:
+		values := make([]string, len(v))
+		for i, item := range v {
+			text, ok := item.(string)
+			if !ok {
+				return fmt.Errorf("expected a string or a list of strings")
+			}
+			values[i] = text
+		}
+		*l = values
+		return nil
+	}
+	return fmt.Errorf("expected a string or a list of strings")
+}
+
+// expandTemplates merges the templates named in each filter's use key into
+// the filter. Templates are applied in order, later ones overriding earlier
+// ones, and fields set explicitly on the filter always win.
+func expandTemplates(config FiltersConfig) (FiltersConfig, error) {
+	names := make([]string, 0, len(config.Templates))
+	for name := range config.Templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		template := config.Templates[name]
+		if len(template.Use) > 0 {
+			err := &ValidationError{Filter: -1, Field: "use", Value: strings.Join(template.Use, ", "), Rule: RuleTemplate,
+				Message: fmt.Sprintf("template '%s' cannot use other templates", name)}
+			_, section := MappingEntry(config.source, "templates")
+			key, node := MappingEntry(section, name)
+			if use, _ := MappingEntry(node, "use"); use != nil {
+				key = use
+			}
+			errs = append(errs, err.at(key))
+		}
+	}
+	if len(errs) > 0 {
+		return FiltersConfig{}, errors.Join(errs...)
+	}
+
+	filters := make([]Filter, len(config.Filters))
+	for i, filter := range config.Filters {
+		expanded, err := applyTemplates(filter, config.Templates)
+		if err != nil {
+			errs = append(errs, filterError(i, filter, "use", strings.Join(filter.Use, ", "), RuleTemplate,
+				fmt.Sprintf("%s: %v", filterRef(i, filter), err)))
+			continue
+		}
+		filters[i] = expanded
+	}
+	if len(errs) > 0 {
+		return FiltersConfig{}, errors.Join(errs...)
+	}
+	config.Filters = filters
+
+	return config, nil
+}
+
+// applyTemplates merges the filter's templates into it
+func applyTemplates(filter Filter, templates map[string]Filter) (Filter, error) {
+	if len(filter.Use) == 0 {
+		return filter, nil
+	}
+
+	var merged Filter
+	for _, name := range filter.Use {
+		template, ok := templates[name]
+		if !ok {
+			return Filter{}, fmt.Errorf("unknown template '%s'%s", name, availableTemplates(templates))
+		}
+		overlayFilter(&merged, template)
+	}
+	overlayFilter(&merged, filter)
+	merged.Use = nil
+	merged.origin = filter.origin
+
+	return merged, nil
+}
+
+// overlayFilter copies every non-zero field of source onto target
+func overlayFilter(target *Filter, source Filter) {
+	targetValue := reflect.ValueOf(target).Elem()
+	sourceValue := reflect.ValueOf(source)
+
+	for i := 0; i < sourceValue.NumField(); i++ {
+		field := sourceValue.Field(i)
+		if !field.IsZero() && targetValue.Field(i).CanSet() {
+			targetValue.Field(i).Set(field)
+		}
+	}
+}
+
+// availableTemplates describes the defined templates for error messages
+func availableTemplates(templates map[string]Filter) string {
+	if len(templates) == 0 {
+		return " (no templates are defined)"
+	}
+
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return " (available: " + strings.Join(names, ", ") + ")"
+}
@@ -0,0 +1,139 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/carlosrabelo/grc/core/internal/testutils"
+)
+
+func TestLoadConfig_Templates(t *testing.T) {
+	content := `author:
+  name: "Test User"
+  email: "test@example.com"
+templates:
+  newsletter:
+    label: "@Marketing/Newsletters"
+    shouldArchive: true
+    shouldMarkAsRead: true
+  starred:
+    shouldStar: true
+    shouldMarkAsRead: false
+filters:
+  - from: "news@shop.com"
+    use: newsletter
+  - from: "deals@shop.com"
+    use: [newsletter, starred]
+    label: "@Marketing/Deals"
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+
+	config, err := LoadConfig(tmpFile)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	feed := GenerateFeed(config, time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC))
+
+	first := feed.Entries[0].Properties
+	for name, value := range map[string]string{"label": "@Marketing/Newsletters", "shouldArchive": "true", "shouldMarkAsRead": "true"} {
+		if !hasProperty(first, name, value) {
+			t.Errorf("Expected %s=%s from template, got %+v", name, value, first)
+		}
+	}
+
+	second := feed.Entries[1].Properties
+	for name, value := range map[string]string{"label": "@Marketing/Deals", "shouldStar": "true", "shouldMarkAsRead": "false", "shouldArchive": "true"} {
+		if !hasProperty(second, name, value) {
+			t.Errorf("Expected %s=%s, got %+v", name, value, second)
+		}
+	}
+}
+
+func TestLoadConfig_UnknownTemplate(t *testing.T) {
+	content := `author:
+  name: "Test User"
+  email: "test@example.com"
+templates:
+  newsletter:
+    shouldArchive: true
+filters:
+  - from: "news@shop.com"
+    use: newslettr
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+
+	_, err := LoadConfig(tmpFile)
+	if err == nil || !strings.Contains(err.Error(), "filter 0: unknown template 'newslettr' (available: newsletter)") {
+		t.Errorf("Expected unknown template error, got: %v", err)
+	}
+}
+
+func TestLoadConfig_ReportsEveryUnknownTemplate(t *testing.T) {
+	content := `author:
+  name: "Test User"
+  email: "test@example.com"
+templates:
+  newsletter:
+    shouldArchive: true
+filters:
+  - from: "news@shop.com"
+    use: newslettr
+  - from: "deals@shop.com"
+    use: promo
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+
+	_, err := LoadConfig(tmpFile)
+	errs := ValidationErrors(err)
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 template errors, got: %v", err)
+	}
+	if errs[0].Filter != 0 || errs[0].Line != 9 || errs[1].Filter != 1 || errs[1].Line != 11 {
+		t.Errorf("Expected errors on filters 0 and 1 at lines 9 and 11, got %+v and %+v", errs[0], errs[1])
+	}
+}
+
+func TestLoadConfig_NestedTemplateRejected(t *testing.T) {
+	content := `author:
+  name: "Test User"
+  email: "test@example.com"
+templates:
+  base:
+    shouldArchive: true
+  newsletter:
+    use: base
+filters:
+  - from: "news@shop.com"
+    use: newsletter
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+
+	_, err := LoadConfig(tmpFile)
+	if err == nil || !strings.Contains(err.Error(), "template 'newsletter' cannot use other templates") {
+		t.Errorf("Expected nested template error, got: %v", err)
+	}
+}
+
+func TestApplyTemplates_ExplicitFieldsWin(t *testing.T) {
+	templates := map[string]Filter{
+		"archive": {ShouldArchive: testutils.BoolPtr(true), Label: "Archive"},
+	}
+	filter := Filter{From: "a@example.com", ShouldArchive: testutils.BoolPtr(false), Use: StringList{"archive"}}
+
+	result, err := applyTemplates(filter, templates)
+	if err != nil {
+		t.Fatalf("applyTemplates failed: %v", err)
+	}
+	if *result.ShouldArchive != false || result.Label != "Archive" || result.From != "a@example.com" {
+		t.Errorf("Expected explicit fields to win over the template, got %+v", result)
+	}
+	if result.Use != nil {
+		t.Errorf("Expected use to be cleared after expansion, got %v", result.Use)
+	}
+}
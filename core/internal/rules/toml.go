@@ -0,0 +1,344 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ============================================================================
+// TOML Input
+// ============================================================================
+
+// decodeTOML strictly decodes TOML content into target with
+// github.com/BurntSushi/toml, rejecting the keys no field takes. It returns
+// the document as a YAML node tree carrying the line and column of every
+// key, so configuration errors point into the TOML source.
+func decodeTOML(content []byte, target any) (*yaml.Node, error) {
+	if !utf8.Valid(content) {
+		return nil, &ParseError{Err: fmt.Errorf("TOML syntax error: content is not valid UTF-8")}
+	}
+	text := strings.TrimPrefix(string(content), "\ufeff")
+
+	// Decoding through a Primitive separates syntax errors from errors
+	// decoding the values into target
+	var document toml.Primitive
+	metadata, err := toml.Decode(text, &document)
+	if err != nil {
+		return nil, &ParseError{Err: fmt.Errorf("TOML syntax error: %w", tomlError(text, err))}
+	}
+	if err := metadata.PrimitiveDecode(document, target); err != nil {
+		return nil, &ParseError{Err: fmt.Errorf("TOML validation error: %w", tomlError(text, err))}
+	}
+
+	positions := locateTOMLKeys(text, metadata)
+	if problems := undecodedKeys(metadata, positions, reflect.TypeOf(target)); len(problems) > 0 {
+		return nil, &ParseError{Err: fmt.Errorf("TOML validation error: %s", strings.Join(problems, "; "))}
+	}
+
+	var values map[string]any
+	if err := metadata.PrimitiveDecode(document, &values); err != nil {
+		return nil, &ParseError{Err: fmt.Errorf("TOML validation error: %w", tomlError(text, err))}
+	}
+	root, err := positions.node(values, "", tomlPosition{line: 1, column: 1})
+	if err != nil {
+		return nil, &ParseError{Err: fmt.Errorf("TOML validation error: %w", err)}
+	}
+	return &yaml.Node{Kind: yaml.DocumentNode, Line: 1, Column: 1, Content: []*yaml.Node{root}}, nil
+}
+
+// tomlErrorRegex matches the prefix of errors decoding TOML values
+var tomlErrorRegex = regexp.MustCompile(`^toml: (?:(line \d+) )?(?:\(last key "[^"]*"\))?:? ?`)
+
+// tomlError words a TOML decoder error like the YAML decoder errors,
+// "line N: message"
+func tomlError(text string, err error) error {
+	// The decoder counts an error on a line break on the next line, so the
+	// position is taken from the offset of the error instead
+	var parseErr toml.ParseError
+	if errors.As(err, &parseErr) && parseErr.Position.Line > 0 {
+		line, column := offsetPosition([]byte(text), int64(parseErr.Position.Start))
+		return fmt.Errorf("line %d, column %d: %s", line, column, parseErr.Message)
+	}
+
+	message := err.Error()
+	if match := tomlErrorRegex.FindStringSubmatch(message); match != nil {
+		message = strings.TrimPrefix(message, match[0])
+		if match[1] != "" {
+			message = match[1] + ": " + message
+		}
+	}
+	return errors.New(message)
+}
+
+// undecodedKeys lists the keys matching no field of target, in the form
+// yaml.Decoder.KnownFields reports them: the keys the decoder left undecoded
+// and those it only matched ignoring case. Keys inside an unknown table are
+// covered by the table.
+func undecodedKeys(metadata toml.MetaData, positions *tomlPositions, target reflect.Type) []string {
+	undecoded := map[string]bool{}
+	for _, key := range metadata.Undecoded() {
+		undecoded[key.String()] = true
+	}
+
+	unknown := map[string]bool{}
+	var problems []string
+	for i, key := range metadata.Keys() {
+		if len(key) > 1 && unknown[key[:len(key)-1].String()] {
+			unknown[key.String()] = true
+			continue
+		}
+		// Keys below untyped values are kept as they are, not unknown
+		parent := tomlParentType(target, key)
+		if parent.Kind() != reflect.Struct {
+			continue
+		}
+		name := key[len(key)-1]
+		if _, ok := fieldByYAMLName(parent, name); ok && !undecoded[key.String()] {
+			continue
+		}
+
+		unknown[key.String()] = true
+		problems = append(problems, fmt.Sprintf("line %d: field %s not found in type %s",
+			positions.keys[i].line, name, parent))
+	}
+	return problems
+}
+
+// tomlParentType returns the type holding the last element of a key
+func tomlParentType(t reflect.Type, key toml.Key) reflect.Type {
+	for _, name := range key[:len(key)-1] {
+		t = tomlElemType(t)
+		switch t.Kind() {
+		case reflect.Struct:
+			field, ok := fieldByYAMLName(t, name)
+			if !ok {
+				return t
+			}
+			t = field.Type
+		case reflect.Map:
+			t = t.Elem()
+		default:
+			return t
+		}
+	}
+	return tomlElemType(t)
+}
+
+// tomlElemType strips the pointers and slices around a type
+func tomlElemType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	return t
+}
+
+// ============================================================================
+// TOML Source Positions
+// ============================================================================
+
+// tomlPosition is a one-based line and column, counted in runes like yaml.v3
+type tomlPosition struct {
+	line   int
+	column int
+}
+
+// tomlPositions maps the keys of a TOML document to their position in the
+// source. Paths join key names with dots and number the tables of arrays of
+// tables, as in "filters[1].label".
+type tomlPositions struct {
+	paths map[string]tomlPosition
+	keys  []tomlPosition // position of every metadata key, in document order
+}
+
+// tomlKeyRegex matches a bare or quoted key followed by its separator
+var tomlKeyRegex = regexp.MustCompile(`(?:^|[\s\[.{,])([A-Za-z0-9_-]+|"[^"\n]*"|'[^'\n]*')\s*(?:=|\.|\])`)
+
+// locateTOMLKeys finds every key of the metadata in the source. Keys are
+// listed in document order, so each one is searched after the previous one.
+func locateTOMLKeys(text string, metadata toml.MetaData) *tomlPositions {
+	positions := &tomlPositions{paths: map[string]tomlPosition{}}
+	tables := map[string]int{} // tables seen in each array of tables
+	offset := 0
+
+	for _, key := range metadata.Keys() {
+		path := ""
+		for i, name := range key {
+			path = joinTOMLPath(path, name)
+			if i < len(key)-1 && metadata.Type(key[:i+1]...) == "ArrayHash" {
+				path += "[" + strconv.Itoa(tables[path]-1) + "]"
+			}
+		}
+
+		position, end := findTOMLKey(text, offset, key[len(key)-1])
+		if end > 0 {
+			offset = end
+		}
+		positions.keys = append(positions.keys, position)
+		if position.line == 0 {
+			continue
+		}
+
+		if metadata.Type(key...) == "ArrayHash" {
+			tables[path]++
+			path += "[" + strconv.Itoa(tables[path]-1) + "]"
+		}
+		if _, ok := positions.paths[path]; !ok {
+			positions.paths[path] = position
+		}
+	}
+	return positions
+}
+
+// findTOMLKey finds a key name at or after offset, returning its position
+// and the offset following it, or a zero position when it is not found
+func findTOMLKey(text string, offset int, name string) (tomlPosition, int) {
+	for offset < len(text) {
+		match := tomlKeyRegex.FindStringSubmatchIndex(text[offset:])
+		if match == nil {
+			break
+		}
+		start, end := offset+match[2], offset+match[3]
+		offset += match[1] - 1
+
+		lineStart := strings.LastIndexByte(text[:start], '\n') + 1
+		if strings.Contains(text[lineStart:start], "#") || tomlKeyName(text[start:end]) != name {
+			continue
+		}
+		line := strings.Count(text[:start], "\n") + 1
+		return tomlPosition{line: line, column: utf8.RuneCountInString(text[lineStart:start]) + 1}, end
+	}
+	return tomlPosition{}, 0
+}
+
+// tomlKeyName returns the name of a bare or quoted key
+func tomlKeyName(token string) string {
+	if strings.HasPrefix(token, `"`) {
+		if name, err := strconv.Unquote(token); err == nil {
+			return name
+		}
+	}
+	return strings.Trim(token, `"'`)
+}
+
+// joinTOMLPath appends a key name to a path
+func joinTOMLPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// node converts a decoded TOML value into a YAML node placed at the position
+// of its key, or at the position of the enclosing value when the key was not
+// found
+func (p *tomlPositions) node(value any, path string, at tomlPosition) (*yaml.Node, error) {
+	if position, ok := p.paths[path]; ok {
+		at = position
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: at.line, Column: at.column}
+		for _, name := range p.sortedKeys(v, path) {
+			childPath := joinTOMLPath(path, name)
+			key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name, Line: at.line, Column: at.column}
+			if position, ok := p.paths[childPath]; ok {
+				key.Line, key.Column = position.line, position.column
+			}
+			child, err := p.node(v[name], childPath, tomlPosition{line: key.Line, column: key.Column})
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, key, child)
+		}
+		return node, nil
+	case []map[string]any:
+		items := make([]any, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		return p.node(items, path, at)
+	case []any:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Line: at.line, Column: at.column}
+		for i, item := range v {
+			child, err := p.node(item, path+"["+strconv.Itoa(i)+"]", at)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, child)
+		}
+		return node, nil
+	}
+
+	node, err := tomlScalar(value)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %w", at.line, err)
+	}
+	node.Line, node.Column = at.line, at.column
+	return node, nil
+}
+
+// sortedKeys orders the keys of a table as they appear in the source
+func (p *tomlPositions) sortedKeys(table map[string]any, path string) []string {
+	names := make([]string, 0, len(table))
+	for name := range table {
+		names = append(names, name)
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		a, aok := p.paths[joinTOMLPath(path, names[i])]
+		b, bok := p.paths[joinTOMLPath(path, names[j])]
+		switch {
+		case aok != bok:
+			return aok
+		case a.line != b.line:
+			return a.line < b.line
+		case a.column != b.column:
+			return a.column < b.column
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// tomlValueNode converts a decoded TOML value into a YAML node without
+// source positions
+func tomlValueNode(value any) (*yaml.Node, error) {
+	return (&tomlPositions{}).node(value, "", tomlPosition{})
+}
+
+// tomlScalar converts a decoded TOML scalar into a YAML scalar node
+func tomlScalar(value any) (*yaml.Node, error) {
+	switch v := value.(type) {
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v}, nil
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(v)}, nil
+	case int64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.FormatInt(v, 10)}, nil
+	case float64:
+		text := strconv.FormatFloat(v, 'g', -1, 64)
+		switch {
+		case math.IsNaN(v):
+			text = ".nan"
+		case math.IsInf(v, 1):
+			text = ".inf"
+		case math.IsInf(v, -1):
+			text = "-.inf"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: text}, nil
+	case time.Time:
+		return nil, fmt.Errorf("dates and times are not supported")
+	}
+	return nil, fmt.Errorf("unsupported value %v", value)
+}
@@ -0,0 +1,193 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestDecodeTOML(t *testing.T) {
+	content := `# settings
+title = "basic \"quoted\" \u00e9"
+path = 'C:\literal'
+count = 1_000
+hex = 0x1F
+ratio = 1.5
+enabled = true
+list = [
+  "a",
+  'b', # trailing comma allowed
+]
+inline = { name = "x", "quoted key" = 2 }
+site.owner = "me"
+text = """
+line one
+line two"""
+literal = '''
+'quoted' text'''
+
+[table.nested]
+value = -3
+
+[[items]]
+id = 1
+
+[[items]]
+id = 2
+`
+	var values map[string]any
+	document, err := decodeTOML([]byte(content), &values)
+	if err != nil {
+		t.Fatalf("decodeTOML failed: %v", err)
+	}
+
+	var got map[string]any
+	if err := document.Decode(&got); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	checks := map[string]any{
+		"title":   `basic "quoted" é`,
+		"path":    `C:\literal`,
+		"count":   1000,
+		"hex":     31,
+		"ratio":   1.5,
+		"enabled": true,
+		"text":    "line one\nline two",
+		"literal": "'quoted' text",
+	}
+	for key, want := range checks {
+		if got[key] != want {
+			t.Errorf("%s: expected %#v, got %#v", key, want, got[key])
+		}
+	}
+	if list, _ := got["list"].([]any); len(list) != 2 || list[1] != "b" {
+		t.Errorf("Unexpected list: %#v", got["list"])
+	}
+	if inline, _ := got["inline"].(map[string]any); inline["quoted key"] != 2 {
+		t.Errorf("Unexpected inline table: %#v", got["inline"])
+	}
+	if site, _ := got["site"].(map[string]any); site["owner"] != "me" {
+		t.Errorf("Unexpected dotted key: %#v", got["site"])
+	}
+	table, _ := got["table"].(map[string]any)
+	if nested, _ := table["nested"].(map[string]any); nested["value"] != -3 {
+		t.Errorf("Unexpected nested table: %#v", got["table"])
+	}
+	if items, _ := got["items"].([]any); len(items) != 2 {
+		t.Errorf("Expected 2 array table items, got %#v", got["items"])
+	}
+}
+
+func TestDecodeTOML_Positions(t *testing.T) {
+	content := `[author]
+name = "Me"
+
+[[filters]]
+  label = "Work"
+
+[[filters]]
+from = "news@example.com" # label = "not a key"
+label = "News"
+
+[[groups]]
+name = "team"
+
+[[groups.filters]]
+to = "team@example.com"
+`
+	var config FiltersConfig
+	document, err := decodeTOML([]byte(content), &config)
+	if err != nil {
+		t.Fatalf("decodeTOML failed: %v", err)
+	}
+	root := document.Content[0]
+
+	_, filters := MappingEntry(root, "filters")
+	if filters == nil || filters.Kind != yaml.SequenceNode || len(filters.Content) != 2 {
+		t.Fatalf("Expected a filters sequence, got %+v", filters)
+	}
+	_, groups := MappingEntry(root, "groups")
+	if groups == nil || len(groups.Content) != 1 {
+		t.Fatalf("Expected a groups sequence, got %+v", groups)
+	}
+	_, groupFilters := MappingEntry(groups.Content[0], "filters")
+	if groupFilters == nil || len(groupFilters.Content) != 1 {
+		t.Fatalf("Expected a group filters sequence, got %+v", groupFilters)
+	}
+
+	tests := []struct {
+		name   string
+		parent *yaml.Node
+		key    string
+		line   int
+		column int
+	}{
+		{"first array table", filters.Content[0], "label", 5, 3},
+		{"second array table", filters.Content[1], "from", 8, 1},
+		{"key after a comment naming it", filters.Content[1], "label", 9, 1},
+		{"nested array table", groupFilters.Content[0], "to", 15, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, _ := MappingEntry(tt.parent, tt.key)
+			if key == nil || key.Line != tt.line || key.Column != tt.column {
+				t.Errorf("Expected %s at line %d, column %d, got %+v", tt.key, tt.line, tt.column, key)
+			}
+		})
+	}
+}
+
+func TestDecodeTOML_Criteria(t *testing.T) {
+	yamlContent := `filters:
+  - from: ["a@example.com", "b@example.com"]
+    hasTheWord: {any: ["x", "y"]}
+    use: newsletter
+`
+	tomlContent := `[[filters]]
+from = ["a@example.com", "b@example.com"]
+hasTheWord = { any = ["x", "y"] }
+use = "newsletter"
+`
+	var fromYAML, fromTOML FiltersConfig
+	if _, err := decodeYAML([]byte(yamlContent), "YAML", &fromYAML); err != nil {
+		t.Fatalf("decodeYAML failed: %v", err)
+	}
+	if _, err := decodeTOML([]byte(tomlContent), &fromTOML); err != nil {
+		t.Fatalf("decodeTOML failed: %v", err)
+	}
+
+	want, got := fromYAML.Filters[0], fromTOML.Filters[0]
+	if got.From != want.From || got.HasTheWord != want.HasTheWord || strings.Join(got.Use, ",") != strings.Join(want.Use, ",") {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
+
+func TestDecodeTOML_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		message string
+	}{
+		{"duplicate key", "a = 1\na = 2\n", "TOML syntax error: line 2"},
+		{"duplicate table", "[a]\n[a]\n", "TOML syntax error: line 2"},
+		{"unterminated string", "a = \"x\n", "TOML syntax error: line 1"},
+		{"missing value", "a =\n", "TOML syntax error: line 1"},
+		{"leading zero", "a = 012\n", "TOML syntax error: line 1"},
+		{"date", "a = 1979-05-27\n", "line 1: dates and times are not supported"},
+		{"bad escape", "a = \"\\q\"\n", "TOML syntax error: line 1"},
+		{"trailing content", "a = 1 b\n", "TOML syntax error: line 1"},
+		{"invalid UTF-8", "a = \"\xff\"\n", "not valid UTF-8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var values map[string]any
+			_, err := decodeTOML([]byte(tt.content), &values)
+			if err == nil || !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("Expected error containing %q, got: %v", tt.message, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// varReferenceRegex matches ${name} references
+var varReferenceRegex = regexp.MustCompile(`\$\{[^}]*\}`)
+
+// varNameRegex validates variable names used in ${name} references
+var varNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// interpolateConfig replaces ${name} references in every string field of the
+// author, the global and group defaults and the filters. Names are resolved from the vars section first and then
+// from the environment; "$${" produces a literal "${". ${env:NAME},
+// ${file:path} and ${secret:key} read environment variables, files and the
+// secrets file only, and their values are recorded as sensitive.
+func interpolateConfig(config FiltersConfig) (FiltersConfig, error) {
+	names := make([]string, 0, len(config.Vars))
+	for name := range config.Vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		if !varNameRegex.MatchString(name) {
+			errs = append(errs, sectionError(config, "vars", name, name, RuleVariable,
+				fmt.Sprintf("vars: invalid variable name '%s'", name)))
+		}
+	}
+	if len(errs) > 0 {
+		return FiltersConfig{}, errors.Join(errs...)
+	}
+
+	references := &referenceResolver{config: config}
+	resolve := func(name string) (string, error) {
+		if kind, key, ok := strings.Cut(name, ":"); ok {
+			return references.resolve(kind, key)
+		}
+		if value, ok := config.Vars[name]; ok {
+			return value, nil
+		}
+		if value, ok := os.LookupEnv(name); ok {
+			return value, nil
+		}
+		return "", fmt.Errorf("undefined variable '%s'", name)
+	}
+
+	for _, err := range interpolateStruct(&config.Author, resolve) {
+		errs = append(errs, sectionError(config, "author", err.Field, err.Value, err.Rule, "author: "+err.Message))
+	}
+	for _, err := range interpolateStruct(&config.Defaults, resolve) {
+		errs = append(errs, sectionError(config, "default", err.Field, err.Value, err.Rule, "default: "+err.Message))
+	}
+
+	// Filters of a group share its chain of defaults, interpolated once
+	chains := map[*Defaults][]Defaults{}
+	reported := map[string]bool{}
+	filters := make([]Filter, len(config.Filters))
+	for i, filter := range config.Filters {
+		if len(filter.origin.defaults) > 0 {
+			chain, ok := chains[&filter.origin.defaults[0]]
+			if !ok {
+				chain = append([]Defaults(nil), filter.origin.defaults...)
+				for j := range chain {
+					for _, err := range interpolateStruct(&chain[j], resolve) {
+						message := fmt.Sprintf("group '%s': default: %s", filter.origin.group, err.Message)
+						if !reported[message] {
+							reported[message] = true
+							errs = append(errs, (&ValidationError{Filter: i, Field: err.Field, Value: err.Value, Rule: err.Rule,
+								Message: message}).at(filter.origin.node))
+						}
+					}
+				}
+				chains[&filter.origin.defaults[0]] = chain
+			}
+			filter.origin.defaults = chain
+		}
+
+		decoded := filter
+		fieldErrs := interpolateStruct(&filter, resolve)
+		for _, err := range fieldErrs {
+			errs = append(errs, filterError(i, filter, err.Field, err.Value, err.Rule, filterRef(i, filter)+": "+err.Message))
+		}
+		if len(fieldErrs) == 0 {
+			for _, err := range interpolateCriteria(config, &filter, decoded, resolve) {
+				errs = append(errs, filterError(i, filter, err.Field, err.Value, err.Rule, filterRef(i, filter)+": "+err.Message))
+			}
+		}
+		prefix, err := interpolateString(filter.origin.labelPrefix, resolve)
+		if err != nil {
+			errs = append(errs, filterError(i, filter, "labelPrefix", filter.origin.labelPrefix, RuleVariable,
+				fmt.Sprintf("group '%s': field 'labelPrefix': %v", filter.origin.group, err)))
+		}
+		filter.origin.labelPrefix = prefix
+		filters[i] = filter
+	}
+	if len(errs) > 0 {
+		return FiltersConfig{}, errors.Join(errs...)
+	}
+	config.Filters = filters
+	config.sensitive = append(config.sensitive, references.values...)
+
+	return config, nil
+}
+
+// interpolateStruct interpolates every exported string field of the struct
+// pointed to by target, reporting every field that failed
+func interpolateStruct(target any, resolve func(string) (string, error)) []*ValidationError {
+	value := reflect.ValueOf(target).Elem()
+	structType := value.Type()
+
+	var errs []*ValidationError
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		if field.Kind() != reflect.String || !field.CanSet() {
+			continue
+		}
+
+		interpolated, err := interpolateString(field.String(), resolve)
+		if err != nil {
+			name := yamlFieldName(structType.Field(i))
+			errs = append(errs, &ValidationError{Filter: -1, Field: name, Value: field.String(), Rule: RuleVariable,
+				Message: fmt.Sprintf("field '%s': %v", name, err)})
+			continue
+		}
+		field.SetString(interpolated)
+	}
+	return errs
+}
+
+// criterionType is the type of the filter criteria fields
+var criterionType = reflect.TypeOf(Criterion(""))
+
+// interpolateCriteria renders again the criteria of a filter written as
+// lists or any/all/none mappings, quoting each term once its references are
+// replaced. Decoding rendered them with the references in place, so a term
+// expanding to several words was left unquoted. Criteria changed since they
+// were decoded keep the value interpolated as a whole.
+func interpolateCriteria(config FiltersConfig, filter *Filter, decoded Filter, resolve func(string) (string, error)) []*ValidationError {
+	value := reflect.ValueOf(filter).Elem()
+	decodedValue := reflect.ValueOf(decoded)
+
+	var errs []*ValidationError
+	for i := 0; i < value.NumField(); i++ {
+		if value.Field(i).Type() != criterionType {
+			continue
+		}
+		name := yamlFieldName(value.Type().Field(i))
+		node := criteriaSource(config, decoded.origin.node, name)
+		if node == nil || node.Kind == yaml.ScalarNode {
+			continue
+		}
+		if source, err := renderCriteriaNode(node, nil); err != nil || source.text != decodedValue.Field(i).String() {
+			continue
+		}
+
+		rendered, err := renderCriteriaNode(node, resolve)
+		if err != nil {
+			rule := RuleVariable
+			if errors.Is(err, ErrCriteriaQuote) {
+				rule = RuleCriteria
+			}
+			errs = append(errs, &ValidationError{Filter: -1, Field: name, Value: decodedValue.Field(i).String(), Rule: rule,
+				Message: fmt.Sprintf("field '%s': %v", name, err)})
+			continue
+		}
+		value.Field(i).SetString(rendered.text)
+	}
+	return errs
+}
+
+// criteriaSource returns the source node a criteria field of a filter was
+// decoded from: the filter's own key, or the key of the last template it
+// uses that sets the field
+func criteriaSource(config FiltersConfig, node *yaml.Node, field string) *yaml.Node {
+	if key, value := MappingEntry(node, field); key != nil {
+		return value
+	}
+
+	var names StringList
+	if _, use := MappingEntry(node, "use"); use == nil || use.Decode(&names) != nil {
+		return nil
+	}
+	_, templates := MappingEntry(config.source, "templates")
+	for i := len(names) - 1; i >= 0; i-- {
+		_, template := MappingEntry(templates, names[i])
+		if key, value := MappingEntry(template, field); key != nil {
+			return value
+		}
+	}
+	return nil
+}
+
+// interpolateString replaces the ${name} references of a single value
+func interpolateString(value string, resolve func(string) (string, error)) (string, error) {
+	if !strings.Contains(value, "${") {
+		return value, nil
+	}
+
+	var result strings.Builder
+	for {
+		start := strings.Index(value, "${")
+		if start < 0 {
+			result.WriteString(value)
+			return result.String(), nil
+		}
+
+		// "$${" escapes a literal "${"
+		if start > 0 && value[start-1] == '$' {
+			result.WriteString(value[:start-1])
+			result.WriteString("${")
+			value = value[start+2:]
+			continue
+		}
+
+		end := strings.IndexByte(value[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated variable reference in '%s'", value)
+		}
+
+		name := value[start+2 : start+end]
+		if !varNameRegex.MatchString(name) && !isExternalReference(name) {
+			return "", fmt.Errorf("invalid variable name '%s'", name)
+		}
+		replacement, err := resolve(name)
+		if err != nil {
+			return "", err
+		}
+
+		result.WriteString(value[:start])
+		result.WriteString(replacement)
+		value = value[start+end+1:]
+	}
+}
+
+// yamlFieldName returns the YAML key of a struct field
+func yamlFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
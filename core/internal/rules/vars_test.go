@@ -0,0 +1,230 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/carlosrabelo/grc/core/internal/testutils"
+)
+
+func TestInterpolateString(t *testing.T) {
+	vars := map[string]string{"domain": "corp.com", "prefix": "@Work"}
+	resolve := func(name string) (string, error) {
+		if value, ok := vars[name]; ok {
+			return value, nil
+		}
+		return "", fmt.Errorf("undefined variable '%s'", name)
+	}
+
+	tests := []struct {
+		name     string
+		value    string
+		expected string
+		wantErr  string
+	}{
+		{"No references", "plain text", "plain text", ""},
+		{"Single reference", "@${domain}", "@corp.com", ""},
+		{"Multiple references", "${prefix}/${domain}", "@Work/corp.com", ""},
+		{"Escaped reference", "$${domain}", "${domain}", ""},
+		{"Undefined variable", "${missing}", "", "undefined variable 'missing'"},
+		{"Unterminated reference", "${domain", "", "unterminated variable reference"},
+		{"Invalid name", "${bad name}", "", "invalid variable name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := interpolateString(tt.value, resolve)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing %q, got: %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_Vars(t *testing.T) {
+	t.Setenv("GRC_TEST_FORWARD", "archive@corp.com")
+
+	content := `vars:
+  domain: "corp.com"
+  marketing: "@Marketing"
+author:
+  name: "Test User"
+  email: "me@${domain}"
+filters:
+  - from: ["news@${domain}", "promo@${domain}"]
+    label: "${marketing}/Newsletters"
+    forwardTo: "${GRC_TEST_FORWARD}"
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+
+	config, err := LoadConfig(tmpFile)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if config.Author.Email != "me@corp.com" {
+		t.Errorf("Expected interpolated author email, got %q", config.Author.Email)
+	}
+	filter := config.Filters[0]
+	if filter.From != "news@corp.com OR promo@corp.com" {
+		t.Errorf("Expected interpolated criteria, got %q", filter.From)
+	}
+	if filter.Label != "@Marketing/Newsletters" {
+		t.Errorf("Expected interpolated label, got %q", filter.Label)
+	}
+	if filter.ForwardTo != "archive@corp.com" {
+		t.Errorf("Expected environment fallback, got %q", filter.ForwardTo)
+	}
+}
+
+func TestLoadConfig_UndefinedVar(t *testing.T) {
+	content := `author:
+  name: "Test User"
+  email: "test@example.com"
+filters:
+  - from: "news@${grc_undefined_domain}"
+    label: "Test"
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+
+	_, err := LoadConfig(tmpFile)
+	if err == nil || !strings.Contains(err.Error(), "filter 0: field 'from': undefined variable 'grc_undefined_domain'") {
+		t.Errorf("Expected undefined variable error, got: %v", err)
+	}
+}
+
+func TestLoadConfig_ReportsEveryUndefinedVar(t *testing.T) {
+	content := `author:
+  name: "${grc_undefined_name}"
+  email: "test@example.com"
+filters:
+  - from: "news@${grc_undefined_domain}"
+    label: "${grc_undefined_label}"
+  - to: "${grc_undefined_to}"
+    label: "Test"
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+
+	_, err := LoadConfig(tmpFile)
+	if err == nil {
+		t.Fatal("Expected undefined variable errors")
+	}
+	for _, expected := range []string{
+		"author: field 'name': undefined variable 'grc_undefined_name'",
+		"filter 0: field 'from': undefined variable 'grc_undefined_domain'",
+		"filter 0: field 'label': undefined variable 'grc_undefined_label'",
+		"filter 1: field 'to': undefined variable 'grc_undefined_to'",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error containing %q, got: %v", expected, err)
+		}
+	}
+	if errs := ValidationErrors(err); len(errs) != 4 {
+		t.Errorf("Expected 4 validation errors, got %d: %v", len(errs), err)
+	}
+}
+
+func TestLoadConfig_InterpolatedEmailIsValidated(t *testing.T) {
+	content := `vars:
+  domain: "not a domain"
+author:
+  name: "Test User"
+  email: "test@example.com"
+filters:
+  - from: "news@${domain}"
+    label: "Test"
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+
+	_, err := LoadConfig(tmpFile)
+	if err == nil || !strings.Contains(err.Error(), "not a valid email address") {
+		t.Errorf("Expected interpolated value to be validated, got: %v", err)
+	}
+}
+
+func TestLoadConfig_VarsInDefaults(t *testing.T) {
+	content := `vars:
+  domain: "corp.com"
+author:
+  name: "Test User"
+  email: "test@example.com"
+default:
+  forwardTo: "archive@${domain}"
+  labelPrefix: "${domain}"
+groups:
+  - name: "team"
+    default:
+      forwardTo: "team@${domain}"
+    filters:
+      - from: "team@example.com"
+        label: "Team"
+filters:
+  - from: "news@example.com"
+    label: "News"
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+
+	config, err := LoadConfig(tmpFile)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	filters := NormalizeFilters(config)
+	if filters[0].ForwardTo != "archive@corp.com" || filters[0].Label != "corp.com/News" {
+		t.Errorf("Expected interpolated global defaults, got %+v", filters[0])
+	}
+	if filters[1].ForwardTo != "team@corp.com" {
+		t.Errorf("Expected interpolated group defaults, got %q", filters[1].ForwardTo)
+	}
+}
+
+func TestLoadConfig_UndefinedVarInDefaults(t *testing.T) {
+	content := `author:
+  name: "Test User"
+  email: "test@example.com"
+default:
+  forwardTo: "${grc_undefined_forward}"
+groups:
+  - name: "team"
+    default:
+      smartLabel: "${grc_undefined_smart}"
+    filters:
+      - from: "a@example.com"
+        label: "A"
+      - from: "b@example.com"
+        label: "B"
+`
+	tmpFile := testutils.CreateTempYAMLFile(t, content)
+	defer testutils.CleanupFile(tmpFile)
+
+	_, err := LoadConfig(tmpFile)
+	if err == nil {
+		t.Fatal("Expected undefined variable errors")
+	}
+	for _, expected := range []string{
+		"default: field 'forwardTo': undefined variable 'grc_undefined_forward'",
+		"group 'team': default: field 'smartLabel': undefined variable 'grc_undefined_smart'",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error containing %q, got: %v", expected, err)
+		}
+	}
+	if errs := ValidationErrors(err); len(errs) != 2 {
+		t.Errorf("Expected each error once, got %d: %v", len(errs), err)
+	}
+}
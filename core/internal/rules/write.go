@@ -0,0 +1,217 @@
+package rules
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ============================================================================
+// Safe File Writing
+// ============================================================================
+
+// backupTimeFormat stamps the backups of replaced files
+const backupTimeFormat = "20060102-150405"
+
+// SaveOptions controls how SaveFeedWith writes a feed
+type SaveOptions struct {
+	// Force replaces an existing file instead of failing
+	Force bool
+	// Backup keeps the replaced file as <name>.<timestamp>.bak
+	Backup bool
+}
+
+// Verifier is implemented by encoders that can check their own output. Feeds
+// written with such an encoder are parsed back before they replace a file.
+type Verifier interface {
+	// Verify checks that data decodes to the entries of feed
+	Verify(data []byte, feed Feed) error
+}
+
+// SaveFeedWith writes the feed to disk with an encoder, adding the encoder's
+// extension to paths without one. The file is replaced atomically, so an
+// interrupted run leaves either the previous file or the complete new one.
+// It returns the path of the backup, if one was made.
+func SaveFeedWith(filePath string, feed Feed, encoder Encoder, options SaveOptions) (string, error) {
+	normalizedPath := ensureExtension(filePath, encoder.Extension())
+
+	if err := validateFileOverwrite(normalizedPath, options.Force); err != nil {
+		return "", err
+	}
+
+	var output bytes.Buffer
+	if err := encoder.Encode(&output, feed); err != nil {
+		return "", err
+	}
+
+	// The backup is taken once the new content is written and verified, so
+	// failed runs leave no stray copies
+	backupPath := ""
+	beforeReplace := func(written []byte) error {
+		if verifier, ok := encoder.(Verifier); ok {
+			if err := verifier.Verify(written, feed); err != nil {
+				return fmt.Errorf("verifying written output: %w", err)
+			}
+		}
+		if options.Backup {
+			var err error
+			backupPath, err = backupFile(normalizedPath, time.Now())
+			return err
+		}
+		return nil
+	}
+
+	if err := writeFileAtomic(normalizedPath, output.Bytes(), 0o644, beforeReplace); err != nil {
+		return "", err
+	}
+	return backupPath, nil
+}
+
+// WriteFileAtomic replaces a file with data through a temporary file in the
+// same directory, synced to disk and renamed over the target. Existing files
+// keep their permissions; new files get perm.
+func WriteFileAtomic(filePath string, data []byte, perm os.FileMode) error {
+	return writeFileAtomic(filePath, data, perm, nil)
+}
+
+// writeFileAtomic writes the file atomically, calling beforeReplace, when
+// given, with the content read back from disk; an error keeps the target
+func writeFileAtomic(filePath string, data []byte, perm os.FileMode, beforeReplace func([]byte) error) (err error) {
+	if info, statErr := os.Stat(filePath); statErr == nil {
+		perm = info.Mode().Perm()
+	}
+
+	dir, name := filepath.Split(filePath)
+	if dir == "" {
+		dir = "."
+	}
+	temp, err := os.CreateTemp(dir, "."+name+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+	tempPath := temp.Name()
+	defer func() {
+		if err != nil {
+			temp.Close()
+			os.Remove(tempPath)
+		}
+	}()
+
+	if _, err = temp.Write(data); err != nil {
+		return fmt.Errorf("writing %s: %w", tempPath, err)
+	}
+	if err = temp.Sync(); err != nil {
+		return fmt.Errorf("syncing %s: %w", tempPath, err)
+	}
+	if err = temp.Chmod(perm); err != nil {
+		return fmt.Errorf("setting permissions of %s: %w", tempPath, err)
+	}
+	if err = temp.Close(); err != nil {
+		return fmt.Errorf("closing %s: %w", tempPath, err)
+	}
+
+	if beforeReplace != nil {
+		written, readErr := os.ReadFile(tempPath)
+		if readErr != nil {
+			return fmt.Errorf("reading back %s: %w", tempPath, readErr)
+		}
+		if err = beforeReplace(written); err != nil {
+			return err
+		}
+	}
+
+	if err = os.Rename(tempPath, filePath); err != nil {
+		return fmt.Errorf("replacing %s: %w", filePath, err)
+	}
+	syncDir(dir)
+	return nil
+}
+
+// backupFile copies an existing file to a timestamped .bak next to it,
+// returning "" when there is nothing to back up
+func backupFile(filePath string, now time.Time) (string, error) {
+	source, err := os.Open(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("backing up %s: %w", filePath, err)
+	}
+	defer source.Close()
+
+	info, err := source.Stat()
+	if err != nil {
+		return "", fmt.Errorf("backing up %s: %w", filePath, err)
+	}
+
+	backupPath := fmt.Sprintf("%s.%s.bak", filePath, now.Format(backupTimeFormat))
+	target, err := os.OpenFile(backupPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return "", fmt.Errorf("backing up %s: %w", filePath, err)
+	}
+	if _, err := io.Copy(target, source); err != nil {
+		target.Close()
+		return "", fmt.Errorf("backing up %s: %w", filePath, err)
+	}
+	if err := target.Sync(); err != nil {
+		target.Close()
+		return "", fmt.Errorf("backing up %s: %w", filePath, err)
+	}
+	if err := target.Close(); err != nil {
+		return "", fmt.Errorf("backing up %s: %w", filePath, err)
+	}
+	return backupPath, nil
+}
+
+// syncDir flushes a directory entry after a rename. Not every platform
+// supports it, so failures are ignored.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// ============================================================================
+// Output Verification
+// ============================================================================
+
+func (xmlEncoder) Verify(data []byte, feed Feed) error {
+	var written Feed
+	if err := xml.Unmarshal(data, &written); err != nil {
+		return fmt.Errorf("XML does not parse: %w", err)
+	}
+	return checkEntryCount(len(written.Entries), feed)
+}
+
+func (jsonEncoder) Verify(data []byte, feed Feed) error {
+	var written feedDocument
+	if err := json.Unmarshal(data, &written); err != nil {
+		return fmt.Errorf("JSON does not parse: %w", err)
+	}
+	return checkEntryCount(len(written.Filters), feed)
+}
+
+func (yamlEncoder) Verify(data []byte, feed Feed) error {
+	var written feedDocument
+	if err := yaml.Unmarshal(data, &written); err != nil {
+		return fmt.Errorf("YAML does not parse: %w", err)
+	}
+	return checkEntryCount(len(written.Filters), feed)
+}
+
+// checkEntryCount compares the number of entries read back with the feed
+func checkEntryCount(written int, feed Feed) error {
+	if written != len(feed.Entries) {
+		return fmt.Errorf("read back %d entries, expected %d", written, len(feed.Entries))
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package rules
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// truncatingEncoder writes half of the XML, like a run cut short
+type truncatingEncoder struct{ xmlEncoder }
+
+func (truncatingEncoder) Encode(w io.Writer, feed Feed) error {
+	output, err := MarshalFeed(feed)
+	if err != nil {
+		return err
+	}
+	return writeEncoded(w, output[:len(output)/2])
+}
+
+func TestSaveFeedWith_Backup(t *testing.T) {
+	dir := t.TempDir()
+	outputFile := filepath.Join(dir, "filters.xml")
+	if err := os.WriteFile(outputFile, []byte("previous"), 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	backupPath, err := SaveFeedWith(outputFile, testFeed(), xmlEncoder{}, SaveOptions{Force: true, Backup: true})
+	if err != nil {
+		t.Fatalf("SaveFeedWith failed: %v", err)
+	}
+
+	if !strings.HasPrefix(backupPath, outputFile+".") || !strings.HasSuffix(backupPath, ".bak") {
+		t.Errorf("Expected a timestamped backup next to the output, got %s", backupPath)
+	}
+	if backup, _ := os.ReadFile(backupPath); string(backup) != "previous" {
+		t.Errorf("Expected the backup to hold the previous content, got %q", backup)
+	}
+	content, _ := os.ReadFile(outputFile)
+	if !strings.Contains(string(content), `value="a@example.com"`) {
+		t.Errorf("Expected the new feed in the output, got:\n%s", content)
+	}
+	if info, _ := os.Stat(outputFile); info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected the replaced file to keep mode 0600, got %v", info.Mode().Perm())
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("Expected only the output and its backup, got %v", entries)
+	}
+}
+
+func TestSaveFeedWith_NoBackupForNewFile(t *testing.T) {
+	outputFile := filepath.Join(t.TempDir(), "filters")
+
+	backupPath, err := SaveFeedWith(outputFile, testFeed(), jsonEncoder{}, SaveOptions{Backup: true})
+	if err != nil {
+		t.Fatalf("SaveFeedWith failed: %v", err)
+	}
+	if backupPath != "" {
+		t.Errorf("Expected no backup for a new file, got %s", backupPath)
+	}
+	if _, err := os.Stat(outputFile + ".json"); err != nil {
+		t.Errorf("Expected the encoder's extension to be added: %v", err)
+	}
+}
+
+func TestSaveFeedWith_VerificationKeepsPreviousFile(t *testing.T) {
+	dir := t.TempDir()
+	outputFile := filepath.Join(dir, "filters.xml")
+	if err := os.WriteFile(outputFile, []byte("previous"), 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	_, err := SaveFeedWith(outputFile, testFeed(), truncatingEncoder{}, SaveOptions{Force: true, Backup: true})
+	if err == nil || !strings.Contains(err.Error(), "verifying written output: XML does not parse") {
+		t.Fatalf("Expected a verification error, got: %v", err)
+	}
+
+	if content, _ := os.ReadFile(outputFile); string(content) != "previous" {
+		t.Errorf("Expected the previous file to be kept, got %q", content)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected no temporary or backup files to be left, got %v", entries)
+	}
+}
+
+func TestEncoders_Verify(t *testing.T) {
+	feed := testFeed()
+	for _, name := range []string{FormatXML, FormatJSON, FormatYAML} {
+		encoder, _ := LookupEncoder(name)
+		verifier, ok := encoder.(Verifier)
+		if !ok {
+			t.Errorf("%s: expected the encoder to verify its output", name)
+			continue
+		}
+
+		var b strings.Builder
+		if err := encoder.Encode(&b, feed); err != nil {
+			t.Fatalf("%s: Encode failed: %v", name, err)
+		}
+		if err := verifier.Verify([]byte(b.String()), feed); err != nil {
+			t.Errorf("%s: Verify failed: %v", name, err)
+		}
+		if err := verifier.Verify([]byte(b.String()), Feed{}); err == nil || !strings.Contains(err.Error(), "read back 1 entries, expected 0") {
+			t.Errorf("%s: expected an entry count error, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package grc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/carlosrabelo/grc/core/internal/rules"
+)
+
+// Builder assembles a configuration in Go, e.g. from a contact list:
+//
+//	builder := grc.NewBuilder(grc.Author{Name: "CRM", Email: "crm@example.com"})
+//	for _, customer := range customers {
+//		builder.Add(grc.NewFilter().From(customer.Domains...).Label("Customers/" + customer.Name))
+//	}
+//	config, err := builder.Build()
+type Builder struct {
+	config Config
+	errs   []error // problems of the added filters' criteria terms
+}
+
+// NewBuilder starts a configuration exported under the given author
+func NewBuilder(author Author) *Builder {
+	return &Builder{config: Config{Author: author}}
+}
+
+// Defaults sets the actions applied to filters that do not set them
+func (b *Builder) Defaults(defaults Defaults) *Builder {
+	b.config.Defaults = defaults
+	return b
+}
+
+// DefaultCriteria sets the criteria added to filters that do not set them
+func (b *Builder) DefaultCriteria(criteria CriteriaDefaults) *Builder {
+	b.config.DefaultCriteria = criteria
+	return b
+}
+
+// Limits sets the Gmail limits checked by WithLimitCheck
+func (b *Builder) Limits(limits Limits) *Builder {
+	b.config.Limits = limits
+	return b
+}
+
+// Policy sets the forwarding policy the filters must follow
+func (b *Builder) Policy(policy Policy) *Builder {
+	b.config.Policy = policy
+	return b
+}
+
+// Var defines a variable referenced as ${name} in filter fields
+func (b *Builder) Var(name, value string) *Builder {
+	if b.config.Vars == nil {
+		b.config.Vars = map[string]string{}
+	}
+	b.config.Vars[name] = value
+	return b
+}
+
+// Template defines a template that filters pull in with Use
+func (b *Builder) Template(name string, template *FilterBuilder) *Builder {
+	for _, err := range template.errs {
+		err.Filter = -1
+		err.Message = fmt.Sprintf("template '%s': %s", name, err.Message)
+		b.errs = append(b.errs, &err)
+	}
+	if b.config.Templates == nil {
+		b.config.Templates = map[string]Filter{}
+	}
+	b.config.Templates[name] = template.Filter()
+	return b
+}
+
+// Group appends a group of filters sharing defaults and a label prefix
+func (b *Builder) Group(group FilterGroup) *Builder {
+	b.config.Groups = append(b.config.Groups, group)
+	return b
+}
+
+// Add appends filters to the configuration
+func (b *Builder) Add(filters ...*FilterBuilder) *Builder {
+	for _, filter := range filters {
+		index := len(b.config.Filters)
+		for _, err := range filter.errs {
+			err.Filter = index
+			err.Message = fmt.Sprintf("filter %d: %s", index, err.Message)
+			b.errs = append(b.errs, &err)
+		}
+		b.config.Filters = append(b.config.Filters, filter.Filter())
+	}
+	return b
+}
+
+// Build validates and returns the configuration, reporting problems as
+// *ValidationError
+func (b *Builder) Build() (Config, error) {
+	config, err := rules.PrepareConfig(b.config)
+	if len(b.errs) > 0 {
+		return Config{}, errors.Join(append(b.errs, err)...)
+	}
+	return config, err
+}
+
+// FilterBuilder assembles a single filter. Criteria taking several terms
+// match any of them.
+type FilterBuilder struct {
+	filter Filter
+	errs   []ValidationError // criteria terms Gmail search cannot quote
+}
+
+// NewFilter starts an empty filter
+func NewFilter() *FilterBuilder {
+	return &FilterBuilder{}
+}
+
+// Filter returns the filter built so far
+func (f *FilterBuilder) Filter() Filter {
+	return f.filter
+}
+
+// From matches senders
+func (f *FilterBuilder) From(terms ...string) *FilterBuilder {
+	f.filter.From = f.criterion("from", terms)
+	return f
+}
+
+// To matches recipients
+func (f *FilterBuilder) To(terms ...string) *FilterBuilder {
+	f.filter.To = f.criterion("to", terms)
+	return f
+}
+
+// Subject matches words in the subject
+func (f *FilterBuilder) Subject(terms ...string) *FilterBuilder {
+	f.filter.Subject = f.criterion("subject", terms)
+	return f
+}
+
+// HasTheWord matches words anywhere in the message
+func (f *FilterBuilder) HasTheWord(terms ...string) *FilterBuilder {
+	f.filter.HasTheWord = f.criterion("hasTheWord", terms)
+	return f
+}
+
+// DoesNotHaveTheWord excludes messages containing the words
+func (f *FilterBuilder) DoesNotHaveTheWord(words string) *FilterBuilder {
+	f.filter.DoesNotHaveTheWord = words
+	return f
+}
+
+// List matches mailing lists
+func (f *FilterBuilder) List(terms ...string) *FilterBuilder {
+	f.filter.List = f.criterion("list", terms)
+	return f
+}
+
+// NotFrom excludes senders
+func (f *FilterBuilder) NotFrom(terms ...string) *FilterBuilder {
+	f.filter.NotFrom = f.criterion("notFrom", terms)
+	return f
+}
+
+// NotTo excludes recipients
+func (f *FilterBuilder) NotTo(terms ...string) *FilterBuilder {
+	f.filter.NotTo = f.criterion("notTo", terms)
+	return f
+}
+
+// NotSubject excludes words in the subject
+func (f *FilterBuilder) NotSubject(terms ...string) *FilterBuilder {
+	f.filter.NotSubject = f.criterion("notSubject", terms)
+	return f
+}
+
+// Query matches a raw Gmail search query
+func (f *FilterBuilder) Query(query string) *FilterBuilder {
+	f.filter.Query = query
+	return f
+}
+
+// HasAttachment matches messages with (true) or without (false) attachments
+func (f *FilterBuilder) HasAttachment(value bool) *FilterBuilder {
+	f.filter.HasAttachment = &value
+	return f
+}
+
+// ExcludeChats leaves chat messages out of the match
+func (f *FilterBuilder) ExcludeChats() *FilterBuilder {
+	f.filter.ExcludeChats = boolPtr(true)
+	return f
+}
+
+// Size matches the message size, e.g. ">5MB" or "<100KB"
+func (f *FilterBuilder) Size(size string) *FilterBuilder {
+	f.filter.Size = size
+	return f
+}
+
+// Use merges templates defined with Builder.Template into the filter; later
+// templates override earlier ones and fields set on the filter always win
+func (f *FilterBuilder) Use(templates ...string) *FilterBuilder {
+	f.filter.Use = append(f.filter.Use, templates...)
+	return f
+}
+
+// InheritDefaults set to false skips the global and group defaults
+func (f *FilterBuilder) InheritDefaults(inherit bool) *FilterBuilder {
+	f.filter.InheritDefaults = &inherit
+	return f
+}
+
+// Label applies a label
+func (f *FilterBuilder) Label(label string) *FilterBuilder {
+	f.filter.Label = label
+	return f
+}
+
+// SmartLabel applies a Gmail category such as "^smartlabel_promo"
+func (f *FilterBuilder) SmartLabel(category string) *FilterBuilder {
+	f.filter.SmartLabel = category
+	return f
+}
+
+// ForwardTo forwards matching messages
+func (f *FilterBuilder) ForwardTo(address string) *FilterBuilder {
+	f.filter.ForwardTo = address
+	return f
+}
+
+// Archive skips the inbox
+func (f *FilterBuilder) Archive() *FilterBuilder {
+	f.filter.ShouldArchive = boolPtr(true)
+	return f
+}
+
+// MarkAsRead marks matching messages as read
+func (f *FilterBuilder) MarkAsRead() *FilterBuilder {
+	f.filter.ShouldMarkAsRead = boolPtr(true)
+	return f
+}
+
+// Star stars matching messages
+func (f *FilterBuilder) Star() *FilterBuilder {
+	f.filter.ShouldStar = boolPtr(true)
+	return f
+}
+
+// NeverSpam never sends matching messages to spam
+func (f *FilterBuilder) NeverSpam() *FilterBuilder {
+	f.filter.ShouldNeverSpam = boolPtr(true)
+	return f
+}
+
+// AlwaysImportant always marks matching messages as important
+func (f *FilterBuilder) AlwaysImportant() *FilterBuilder {
+	f.filter.ShouldAlwaysMarkAsImportant = boolPtr(true)
+	return f
+}
+
+// NeverImportant never marks matching messages as important
+func (f *FilterBuilder) NeverImportant() *FilterBuilder {
+	f.filter.ShouldNeverMarkAsImportant = boolPtr(true)
+	return f
+}
+
+// Trash deletes matching messages
+func (f *FilterBuilder) Trash() *FilterBuilder {
+	f.filter.ShouldTrash = boolPtr(true)
+	return f
+}
+
+// criterion builds a criterion matching any of the terms, recording the
+// terms Gmail search cannot quote as a validation error of the field
+func (f *FilterBuilder) criterion(field string, terms []string) Criterion {
+	criterion, err := rules.AnyOfTerms(terms...)
+	if err != nil {
+		f.errs = append(f.errs, ValidationError{Field: field, Value: strings.Join(terms, ", "), Rule: RuleCriteria,
+			Message: fmt.Sprintf("field '%s': %v", field, err)})
+	}
+	return criterion
+}
+
+// boolPtr returns a pointer to a boolean value
+func boolPtr(value bool) *bool {
+	return &value
+}
@@ -0,0 +1,46 @@
+package grc_test
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/carlosrabelo/grc/core/pkg/grc"
+)
+
+func ExampleLoad() {
+	config, err := grc.Load(strings.NewReader(`author:
+  name: "Jane Doe"
+  email: "jane@example.com"
+filters:
+  - from: "@shop.com"
+    label: "Shopping"
+    shouldArchive: true
+`))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	feed, err := grc.Generate(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(len(feed.Entries), "entry")
+	// Output: 1 entry
+}
+
+func ExampleNewBuilder() {
+	contacts := map[string]string{"acme.com": "Acme"}
+
+	builder := grc.NewBuilder(grc.Author{Name: "CRM", Email: "crm@example.com"})
+	for domain, name := range contacts {
+		builder.Add(grc.NewFilter().From("@" + domain).Label("Customers/" + name).Star())
+	}
+
+	config, err := builder.Build()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(config.Filters[0].From, config.Filters[0].Label)
+	// Output: @acme.com Customers/Acme
+}
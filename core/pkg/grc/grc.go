@@ -0,0 +1,251 @@
+// Package grc builds Gmail filter exports from grc configurations.
+//
+// Configurations are read from YAML with Load or built in Go with
+// NewBuilder, turned into an Atom feed with Generate and written with
+// Encode as the XML document Gmail imports:
+//
+//	config, err := grc.Load(file)
+//	if err != nil {
+//		return err
+//	}
+//	feed, err := grc.Generate(config, grc.WithOptimize())
+//	if err != nil {
+//		return err
+//	}
+//	return grc.Encode(w, feed)
+package grc
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/carlosrabelo/grc/core/internal/rules"
+)
+
+// ============================================================================
+// Types
+// ============================================================================
+
+// Configuration types, shared with the grc command
+type (
+	Config           = rules.FiltersConfig
+	Author           = rules.Author
+	Defaults         = rules.Defaults
+	CriteriaDefaults = rules.CriteriaDefaults
+	Filter           = rules.Filter
+	FilterGroup      = rules.FilterGroup
+	Criterion        = rules.Criterion
+	Limits           = rules.Limits
+	Policy           = rules.Policy
+	OrgPolicy        = rules.OrgPolicy
+	PolicyRule       = rules.PolicyRule
+	Feed             = rules.Feed
+	Entry            = rules.Entry
+	Property         = rules.Property
+)
+
+// Sort keys accepted by WithSort
+const (
+	SortLabel  = rules.SortLabel
+	SortFrom   = rules.SortFrom
+	SortAction = rules.SortAction
+)
+
+// Output formats accepted by EncodeAs
+const (
+	FormatXML  = rules.FormatXML
+	FormatJSON = rules.FormatJSON
+	FormatYAML = rules.FormatYAML
+	FormatCSV  = rules.FormatCSV
+)
+
+// Encoder writes a feed in an output format
+type Encoder = rules.Encoder
+
+// RegisterEncoder makes an encoder available to EncodeAs under a format
+// name. It panics when the name is empty or already registered.
+func RegisterEncoder(name string, encoder Encoder) {
+	rules.RegisterEncoder(name, encoder)
+}
+
+// AnyOf builds a criterion matching any of the given terms
+func AnyOf(terms ...string) Criterion {
+	return rules.AnyOf(terms...)
+}
+
+// ============================================================================
+// Errors
+// ============================================================================
+
+// ParseError reports input that is not a well-formed configuration
+type ParseError = rules.ParseError
+
+// ValidationError describes one problem of a well-formed configuration,
+// with the filter index, field, offending value, rule code and source
+// position. Load, Validate, Generate and Builder.Build report every problem
+// found, joined with errors.Join; use ValidationErrors to list them.
+type ValidationError = rules.ValidationError
+
+// Rule codes of validation errors
+const (
+	RuleRequired  = rules.RuleRequired
+	RuleEmail     = rules.RuleEmail
+	RuleAddress   = rules.RuleAddress
+	RuleCondition = rules.RuleCondition
+	RuleAction    = rules.RuleAction
+	RuleSize      = rules.RuleSize
+	RuleCriteria  = rules.RuleCriteria
+	RuleEnum      = rules.RuleEnum
+	RuleTemplate  = rules.RuleTemplate
+	RuleVariable  = rules.RuleVariable
+	RulePolicy    = rules.RulePolicy
+)
+
+// ValidationErrors returns every ValidationError in err
+func ValidationErrors(err error) []*ValidationError {
+	return rules.ValidationErrors(err)
+}
+
+// LimitError reports a feed exceeding Gmail account limits
+type LimitError = rules.LimitError
+
+// ============================================================================
+// Options
+// ============================================================================
+
+// Option tunes Generate
+type Option func(*options)
+
+// options holds the settings selected by Option values
+type options struct {
+	now         func() time.Time
+	feed        rules.FeedOptions
+	checkLimits bool
+}
+
+// WithTime sets the updated timestamp of the feed, the current time by default
+func WithTime(t time.Time) Option {
+	return func(o *options) { o.now = func() time.Time { return t } }
+}
+
+// WithOptimize merges filters that share actions and differ only in one criterion
+func WithOptimize() Option {
+	return func(o *options) { o.feed.Optimize = true }
+}
+
+// WithSort orders entries by SortLabel, SortFrom or SortAction; group keeps
+// filters sharing a top-level label together
+func WithSort(by string, group bool) Option {
+	return func(o *options) { o.feed.Sort = rules.SortOptions{By: by, Group: group} }
+}
+
+// WithLimitCheck makes Generate fail with a *LimitError when the feed
+// exceeds the configured Gmail limits
+func WithLimitCheck() Option {
+	return func(o *options) { o.checkLimits = true }
+}
+
+// ============================================================================
+// Functions
+// ============================================================================
+
+// Load reads and validates a YAML, JSON or TOML configuration, recognizing
+// the format by its content. Syntax and decoding problems are reported as
+// *ParseError, invalid content as *ValidationError. Relative ${file:} and
+// secrets paths are resolved against the working directory.
+func Load(r io.Reader) (Config, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return Config{}, fmt.Errorf("reading configuration: %w", err)
+	}
+
+	return rules.ParseConfigFormat(content, rules.DetectConfigFormat(content))
+}
+
+// ImportCSV reads filters from a CSV spreadsheet, one filter per row under
+// a header row of filter keys, and validates them as a configuration
+// exported under the given author. Validation errors carry the CSV row as
+// their line.
+func ImportCSV(r io.Reader, author Author) (Config, error) {
+	config, err := rules.ImportCSV(r, author)
+	if err != nil {
+		return Config{}, err
+	}
+	return rules.PrepareConfig(config)
+}
+
+// ExportCSV writes the filters of a configuration, with groups and defaults
+// resolved, as CSV that ImportCSV reads back
+func ExportCSV(w io.Writer, config Config) error {
+	config, err := rules.PrepareConfig(config)
+	if err != nil {
+		return err
+	}
+	return rules.WriteCSV(w, rules.NormalizeFilters(config))
+}
+
+// Validate checks a configuration, reporting problems as *ValidationError
+func Validate(config Config) error {
+	_, err := rules.PrepareConfig(config)
+	return err
+}
+
+// CheckPolicy evaluates an organization policy against the normalized
+// filters of a configuration, reporting each broken rule as a
+// *ValidationError with RulePolicy. The policy changes no filter.
+func CheckPolicy(policy OrgPolicy, config Config) error {
+	return rules.CheckOrgPolicy(policy, config)
+}
+
+// Generate validates a configuration and builds its Gmail filters feed
+func Generate(config Config, opts ...Option) (Feed, error) {
+	settings := options{now: time.Now}
+	for _, opt := range opts {
+		opt(&settings)
+	}
+	if err := settings.feed.Sort.Validate(); err != nil {
+		return Feed{}, err
+	}
+
+	config, err := rules.PrepareConfig(config)
+	if err != nil {
+		return Feed{}, err
+	}
+
+	feed, _ := rules.BuildFeed(config, settings.now().UTC(), settings.feed)
+	if settings.checkLimits {
+		violations, err := rules.CheckLimits(feed, config.Limits)
+		if err != nil {
+			return Feed{}, err
+		}
+		if len(violations) > 0 {
+			return Feed{}, &LimitError{Violations: violations}
+		}
+	}
+	return feed, nil
+}
+
+// Encode writes a feed as the XML document Gmail imports
+func Encode(w io.Writer, feed Feed) error {
+	return EncodeAs(w, feed, FormatXML)
+}
+
+// EncodeAs writes a feed in a built-in or registered output format
+func EncodeAs(w io.Writer, feed Feed, format string) error {
+	encoder, err := rules.LookupEncoder(format)
+	if err != nil {
+		return err
+	}
+	return encoder.Encode(w, feed)
+}
+
+// Redactor hides email addresses and sensitive values in text shown to people
+type Redactor = rules.Redactor
+
+// NewRedactor returns a redactor hiding email addresses, the values a loaded
+// configuration read through ${env:}, ${file:} and ${secret:} references and
+// any extra values
+func NewRedactor(config Config, values ...string) *Redactor {
+	return rules.NewRedactor(append(rules.SensitiveValues(config), values...)...)
+}
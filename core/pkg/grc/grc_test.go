@@ -0,0 +1,279 @@
+package grc
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const config = `author:
+  name: "Test User"
+  email: "test@example.com"
+filters:
+  - from: ["b@example.com", "a@example.com"]
+    label: "Work"
+  - from: "c@example.com"
+    label: "Home"
+`
+
+func TestLoadGenerateEncode(t *testing.T) {
+	cfg, err := Load(strings.NewReader(config))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	feed, err := Generate(cfg, WithTime(time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)), WithSort(SortLabel, false))
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if feed.Updated != "2023-01-01T12:00:00Z" || len(feed.Entries) != 2 {
+		t.Fatalf("Unexpected feed: %+v", feed)
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, feed); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	output := buf.String()
+	if !strings.HasPrefix(output, `<?xml version="1.0" encoding="UTF-8"?>`) {
+		t.Errorf("Expected XML header, got: %s", output)
+	}
+	if strings.Index(output, `value="Home"`) > strings.Index(output, `value="Work"`) {
+		t.Errorf("Expected entries sorted by label, got: %s", output)
+	}
+	if !strings.Contains(output, `value="b@example.com OR a@example.com"`) {
+		t.Errorf("Expected structured criteria to be rendered, got: %s", output)
+	}
+}
+
+func TestLoad_TypedErrors(t *testing.T) {
+	_, err := Load(strings.NewReader("author: ["))
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Errorf("Expected *ParseError, got %T: %v", err, err)
+	}
+
+	_, err = Load(strings.NewReader(strings.Replace(config, "c@example.com", "not-an-address", 1)))
+	validationErrs := ValidationErrors(err)
+	if len(validationErrs) != 1 {
+		t.Fatalf("Expected one *ValidationError, got %T: %v", err, err)
+	}
+	got := *validationErrs[0]
+	if got.Filter != 1 || got.Field != "from" || got.Value != "not-an-address" || got.Rule != RuleAddress || got.Line != 7 || got.Column != 5 {
+		t.Errorf("Unexpected validation error: %+v", got)
+	}
+}
+
+func TestLoad_Formats(t *testing.T) {
+	inputs := map[string]string{
+		"json": `{"author": {"name": "A", "email": "a@example.com"}, "filters": [{"from": "b@example.com", "label": "B"}]}`,
+		"toml": "[author]\nname = \"A\"\nemail = \"a@example.com\"\n\n[[filters]]\nfrom = \"b@example.com\"\nlabel = \"B\"\n",
+	}
+	for name, input := range inputs {
+		cfg, err := Load(strings.NewReader(input))
+		if err != nil {
+			t.Errorf("%s: Load failed: %v", name, err)
+			continue
+		}
+		if len(cfg.Filters) != 1 || cfg.Filters[0].Label != "B" {
+			t.Errorf("%s: unexpected filters %+v", name, cfg.Filters)
+		}
+	}
+}
+
+func TestGenerate_Options(t *testing.T) {
+	cfg, err := Load(strings.NewReader(config))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	cfg.Limits = Limits{MaxFilters: 1}
+	_, err = Generate(cfg, WithLimitCheck())
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) {
+		t.Errorf("Expected *LimitError, got %T: %v", err, err)
+	}
+
+	if _, err := Generate(cfg, WithSort("size", false)); err == nil {
+		t.Errorf("Expected invalid sort key error")
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	builder := NewBuilder(Author{Name: "CRM", Email: "crm@example.com"}).
+		Defaults(Defaults{ShouldNeverSpam: boolPtr(true)})
+	for _, customer := range []struct{ name, domain string }{{"Acme", "acme.com"}, {"Globex", "globex.com"}} {
+		builder.Add(NewFilter().From(customer.domain, "@"+customer.domain).Label("Customers/" + customer.name).Star())
+	}
+
+	cfg, err := builder.Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	feed, err := Generate(cfg, WithOptimize())
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if len(feed.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(feed.Entries))
+	}
+
+	properties := map[string]string{}
+	for _, property := range feed.Entries[0].Properties {
+		properties[property.Name] = property.Value
+	}
+	expected := map[string]string{"from": "acme.com OR @acme.com", "label": "Customers/Acme", "shouldStar": "true", "shouldNeverSpam": "true"}
+	for name, value := range expected {
+		if properties[name] != value {
+			t.Errorf("Expected %s=%s, got %q", name, value, properties[name])
+		}
+	}
+}
+
+func TestBuilder_ConfigurationSections(t *testing.T) {
+	cfg, err := NewBuilder(Author{Name: "CRM", Email: "crm@example.com"}).
+		Defaults(Defaults{ShouldNeverSpam: boolPtr(true)}).
+		Var("team", "Sales").
+		Template("newsletter", NewFilter().Archive().Label("News")).
+		Add(NewFilter().From("news@shop.com").NotTo("me@example.com").NotSubject("Receipt").Use("newsletter")).
+		Add(NewFilter().From("boss@example.com").Label("${team}").InheritDefaults(false)).
+		Group(FilterGroup{Name: "deals", LabelPrefix: "Deals", Filters: []Filter{NewFilter().From("deals@shop.com").Label("Shop").Filter()}}).
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	feed, err := Generate(cfg)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	expected := []map[string]string{
+		{"label": "News", "shouldArchive": "true", "shouldNeverSpam": "true", "doesNotHaveTheWord": "to:(me@example.com) subject:(Receipt)"},
+		{"label": "Sales", "shouldNeverSpam": ""},
+		{"label": "Deals/Shop", "shouldNeverSpam": "true"},
+	}
+	if len(feed.Entries) != len(expected) {
+		t.Fatalf("Expected %d entries, got %d", len(expected), len(feed.Entries))
+	}
+	for i, want := range expected {
+		properties := map[string]string{}
+		for _, property := range feed.Entries[i].Properties {
+			properties[property.Name] = property.Value
+		}
+		for name, value := range want {
+			if properties[name] != value {
+				t.Errorf("Entry %d: expected %s=%q, got %q", i, name, value, properties[name])
+			}
+		}
+	}
+}
+
+func TestBuilder_ValidationError(t *testing.T) {
+	_, err := NewBuilder(Author{Name: "CRM", Email: "crm@example.com"}).
+		Add(NewFilter().From("acme.com")).
+		Build()
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || !strings.Contains(err.Error(), "filter 0 must define at least one action") {
+		t.Errorf("Expected missing action error, got: %v", err)
+	}
+}
+
+func TestBuilder_QuotedTerm(t *testing.T) {
+	_, err := NewBuilder(Author{Name: "CRM", Email: "crm@example.com"}).
+		Add(NewFilter().From("acme.com").Label("Acme")).
+		Add(NewFilter().Subject(`say "hi" now`).Label("Hi")).
+		Build()
+
+	errs := ValidationErrors(err)
+	if len(errs) != 1 || errs[0].Filter != 1 || errs[0].Field != "subject" || errs[0].Rule != RuleCriteria {
+		t.Fatalf("Expected a subject criteria error on filter 1, got: %v", err)
+	}
+	if !strings.Contains(errs[0].Message, `filter 1: field 'subject': term 'say "hi" now' contains a double quote`) {
+		t.Errorf("Unexpected message: %s", errs[0].Message)
+	}
+}
+
+func TestEncodeAs(t *testing.T) {
+	cfg, err := Load(strings.NewReader(config))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	feed, err := Generate(cfg)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := EncodeAs(&buf, feed, FormatYAML); err != nil {
+		t.Fatalf("EncodeAs failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "label: Home") {
+		t.Errorf("Expected YAML output, got: %s", buf.String())
+	}
+
+	if err := EncodeAs(&buf, feed, "sieve"); err == nil {
+		t.Errorf("Expected unknown format error")
+	}
+}
+
+func TestImportExportCSV(t *testing.T) {
+	cfg, err := ImportCSV(strings.NewReader("from,label,shouldStar\n@acme.com,Customers/Acme,yes\n"), Author{Name: "CRM", Email: "crm@example.com"})
+	if err != nil {
+		t.Fatalf("ImportCSV failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := ExportCSV(&buf, cfg); err != nil {
+		t.Fatalf("ExportCSV failed: %v", err)
+	}
+	if buf.String() != "from,shouldStar,label\n'@acme.com,true,Customers/Acme\n" {
+		t.Errorf("Unexpected CSV: %q", buf.String())
+	}
+}
+
+func TestNewRedactor(t *testing.T) {
+	t.Setenv("GRC_TEST_LABEL", "Private Project")
+	cfg, err := Load(strings.NewReader(strings.Replace(config, `"Home"`, `"${env:GRC_TEST_LABEL}"`, 1)))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	redacted := NewRedactor(cfg, "Project X").Redact("Private Project and Project X go to c@example.com")
+	for _, leaked := range []string{"Private Project", "Project X", "c@example.com"} {
+		if strings.Contains(redacted, leaked) {
+			t.Errorf("Expected %q to be redacted, got: %s", leaked, redacted)
+		}
+	}
+}
+
+func TestCheckPolicy(t *testing.T) {
+	cfg, err := Load(strings.NewReader(config))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	policy := OrgPolicy{Name: "corp", Rules: []PolicyRule{{Name: "star-example", From: []string{"example.com"}, Require: []string{"shouldStar"}}}}
+	errs := ValidationErrors(CheckPolicy(policy, cfg))
+	if len(errs) != 2 || errs[0].Rule != RulePolicy || errs[0].Field != "shouldStar" {
+		t.Errorf("Expected one violation per filter, got: %v", errs)
+	}
+}
+
+func TestValidate_EditedConfig(t *testing.T) {
+	cfg, err := Load(strings.NewReader(config))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	cfg.Filters = append(cfg.Filters, Filter{From: "not an email!!"})
+	if err := Validate(cfg); len(ValidationErrors(err)) == 0 {
+		t.Errorf("Expected validation errors for the added filter, got: %v", err)
+	}
+	if _, err := Generate(cfg); len(ValidationErrors(err)) == 0 {
+		t.Errorf("Expected Generate to reject the added filter, got: %v", err)
+	}
+}